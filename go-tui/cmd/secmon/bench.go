@@ -0,0 +1,92 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "runtime"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// benchResult is the `secmon bench --json` output.
+type benchResult struct {
+    File          string  `json:"file"`
+    Bytes         int64   `json:"bytes"`
+    Entries       int     `json:"entries"`
+    Malformed     int     `json:"malformed"`
+    Seconds       float64 `json:"seconds"`
+    EntriesPerSec float64 `json:"entries_per_sec"`
+    BytesPerSec   float64 `json:"bytes_per_sec"`
+    ErrorRate     float64 `json:"parse_error_rate"`
+    Allocs        uint64  `json:"allocs"`
+    AllocBytes    uint64  `json:"alloc_bytes"`
+    AllocsPerLine float64 `json:"allocs_per_line"`
+}
+
+// runBench implements `secmon bench --file <metrics.jsonl>`: one full pass of
+// the file through Aggregator.Update, the same read/parse/ingest path the UI
+// uses, timed and with allocation counts.
+func runBench(args []string) int {
+    fs := flag.NewFlagSet("bench", flag.ExitOnError)
+    file := fs.String("file", "", "Metrics JSONL file to ingest")
+    bucket := fs.Int("bucket", 10, "Timeline bucket seconds")
+    asJSON := fs.Bool("json", false, "Print the report as JSON")
+    fs.Usage = func() {
+        fmt.Fprintln(os.Stderr, "usage: secmon bench --file <metrics.jsonl> [--json]")
+        fs.PrintDefaults()
+    }
+    fs.Parse(args)
+    if *file == "" || fs.NArg() != 0 {
+        fs.Usage()
+        return 2
+    }
+    fi, err := os.Stat(*file)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error:", err)
+        return 1
+    }
+
+    agg := metrics.NewAggregator(*file, *bucket, 72)
+    var before, after runtime.MemStats
+    runtime.GC()
+    runtime.ReadMemStats(&before)
+    start := time.Now()
+    agg.Update()
+    elapsed := time.Since(start)
+    runtime.ReadMemStats(&after)
+
+    st := agg.Snapshot()
+    r := benchResult{
+        File:       *file,
+        Bytes:      fi.Size(),
+        Entries:    st.Success + st.Fail + st.DuplicatesSkipped,
+        Malformed:  st.Malformed,
+        Seconds:    elapsed.Seconds(),
+        Allocs:     after.Mallocs - before.Mallocs,
+        AllocBytes: after.TotalAlloc - before.TotalAlloc,
+    }
+    if s := elapsed.Seconds(); s > 0 {
+        r.EntriesPerSec = float64(r.Entries) / s
+        r.BytesPerSec = float64(r.Bytes) / s
+    }
+    if lines := r.Entries + r.Malformed; lines > 0 {
+        r.ErrorRate = float64(r.Malformed) / float64(lines)
+        r.AllocsPerLine = float64(r.Allocs) / float64(lines)
+    }
+
+    if *asJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        enc.Encode(r)
+        return 0
+    }
+    fmt.Printf("file       %s (%d bytes)\n", r.File, r.Bytes)
+    fmt.Printf("entries    %d in %s\n", r.Entries, elapsed.Round(time.Microsecond))
+    fmt.Printf("throughput %.0f entries/s, %.1f MB/s\n", r.EntriesPerSec, r.BytesPerSec/1e6)
+    fmt.Printf("malformed  %d (%.2f%% of lines)\n", r.Malformed, 100*r.ErrorRate)
+    fmt.Printf("allocs     %d (%.1f/line), %d bytes\n", r.Allocs, r.AllocsPerLine, r.AllocBytes)
+    return 0
+}
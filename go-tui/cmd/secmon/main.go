@@ -3,47 +3,531 @@ package main
 import (
     "flag"
     "fmt"
+    "net/http"
+    _ "net/http/pprof"
+    "os"
+    "regexp"
+    "runtime"
+    "runtime/pprof"
+    "strings"
     "time"
 
+    "secmon/internal/snapshot"
     "secmon/internal/ui"
 )
 
 func main() {
+    if len(os.Args) > 1 && os.Args[1] == "diff" {
+        os.Exit(runDiff(os.Args[2:]))
+    }
+    if len(os.Args) > 1 && os.Args[1] == "bench" {
+        os.Exit(runBench(os.Args[2:]))
+    }
+    // deferred first so it runs last, after the profiles are written
+    code := 0
+    defer func() {
+        if code != 0 {
+            os.Exit(code)
+        }
+    }()
+
     var logs, metrics string
-    var refresh float64
+    var refresh, refreshMin, refreshMax, refreshStep float64
     var bucket int
     var snapshot string
     var quitAfter float64
     var debug bool
     var headless bool
     var simulate bool
+    var interleave bool
+    var nameRegex string
+    var staleAfter float64
+    var minSamples int
+    var targets bool
+    var eventLog string
+    var legend bool
+    var retainEvents int
+    var rateColors bool
+    var tailOnly bool
+    var splitMax int
+    var healthWeights string
+    var replayDir string
+    var replaySpeed float64
+    var instanceNorm string
+    var maxInstances int
+    var alertRate, alertWindow, alertSlope, slopeWindow float64
+    var debounce int
+    var snapFormat, layout string
+    var dedup string
+    var dedupWindow int
+    var webhookURL, webhookTmpl string
+    var quiet bool
+    var restartMark string
+    var pinRegions, pinInstances string
+    var groupBy string
+    var confirmQuit bool
+    var checkpointPath string
+    var checkpointEvery float64
+    var expectedTotal int
+    var piaColors string
+    var activeWindow time.Duration
+    var strict bool
+    var seriesSpec string
+    var latestOnly bool
+    var maxRegions, maxReasons, maxTargets, maxLogLines int
+    var recordPath string
+    var remoteWrite string
+    var idleMax time.Duration
+    var idleAfter int
+    var incidentDir string
+    var incidentWin time.Duration
+    var okReasons bool
+    var strictJSON bool
+    var softFail string
+    var summaryExit bool
+    var unknownLabel string
+    var ansiLogs bool
+    var sampleRate float64
+    var preFill bool
+    var exitCodes bool
+    var runLabel string
+    var attemptReset bool
+    var maxLabels int
+    var prefixColors, validate, compactNums bool
+    var shrinkPolls, slowest, reasonTrends, maxFiles int
+    var watch, controlSock, promAddr, sqlitePath string
+    var retainDur time.Duration
+    var anomalyZ float64
+    var anomalyWin int
+    var chartStyle, successMark, failMark string
+    var pprofAddr, cpuProfile, memProfile string
 
     flag.StringVar(&logs, "logs", "instance_*.log", "Glob for instance logs")
     flag.StringVar(&metrics, "metrics", "metrics/*.jsonl", "Glob for metrics files")
     flag.Float64Var(&refresh, "refresh", 1.0, "Refresh interval seconds")
+    flag.Float64Var(&refreshMin, "refresh-min", 0.2, "Shortest refresh interval + can reach, seconds")
+    flag.Float64Var(&refreshMax, "refresh-max", 0, "Longest refresh interval - can reach, seconds (0 = no ceiling)")
+    flag.Float64Var(&refreshStep, "refresh-step", 0.1, "Seconds + and - change the refresh interval by")
     flag.IntVar(&bucket, "bucket", 10, "Timeline bucket size seconds")
     flag.StringVar(&snapshot, "snapshot-dir", "", "Write snapshots to this dir (optional)")
     flag.Float64Var(&quitAfter, "quit-after", 0, "Exit after N seconds (optional)")
     flag.BoolVar(&debug, "debug", false, "Enable debug logs (stderr)")
     flag.BoolVar(&headless, "headless", false, "Run in headless snapshot mode")
     flag.BoolVar(&simulate, "simulate", false, "Generate synthetic metrics for demo")
-    flag.Parse()
+    flag.BoolVar(&interleave, "interleave", false, "Order each tick's log lines across files by their leading timestamp")
+    flag.StringVar(&nameRegex, "name-regex", "", "Regex with a capture group deriving instance names from file paths, e.g. 'instance_(\\d+)' (optional)")
+    flag.Float64Var(&staleAfter, "stale-after", 30, "Seconds without new metrics before the footer shows STALE (0 disables)")
+    flag.IntVar(&minSamples, "min-samples", 20, "Events required before a success rate is shown (per window)")
+    flag.BoolVar(&targets, "targets", false, "Also count one final outcome per (instance, url) target, collapsing retries")
+    flag.StringVar(&eventLog, "event-log", "", "Append NDJSON events (alerts, VPN IP rotations, staleness, malformed bursts) to this file (optional)")
+    flag.BoolVar(&legend, "color-scale-legend", false, "Show the timeline's density legend at startup (toggle with l)")
+    flag.IntVar(&retainEvents, "retain-events", 0, "Keep the last N raw metrics entries in memory for drill-down views (0 = aggregates only)")
+    flag.BoolVar(&rateColors, "rate-colors", true, "Color timeline columns red->yellow->green by bucket success rate")
+    flag.BoolVar(&tailOnly, "tail-only", false, "Only tail logs (full-width pane); skip metrics aggregation")
+    flag.IntVar(&splitMax, "split-max", 6, "Per-instance log panes for the split view (s); further instances share an 'others' pane (0 disables)")
+    flag.StringVar(&healthWeights, "health-weights", "", "Health score overrides: rate,streak,stale,latency weights and streak_limit,latency_ms, e.g. 'rate=0.6,latency_ms=1500'")
+    flag.StringVar(&replayDir, "replay", "", "Replay captured metrics (*.jsonl in this dir, or one file) in ts order instead of tailing --metrics")
+    flag.Float64Var(&replaySpeed, "replay-speed", 1, "Replay speed multiplier")
+    flag.StringVar(&instanceNorm, "instance-normalize", "", "Regex whose first capture group replaces each instance_id, e.g. '^(worker-\\d+)-' (optional)")
+    flag.IntVar(&maxInstances, "max-instances", 500, "Distinct instances tracked before the rest are counted as (other) (0 = unbounded)")
+    flag.Float64Var(&alertRate, "alert-fail-rate", 0, "Level alert: fire when the failure percentage over --alert-window reaches this (0 disables)")
+    flag.Float64Var(&alertWindow, "alert-window", 60, "Level alert window seconds")
+    flag.Float64Var(&alertSlope, "alert-slope", 0, "Slope alert: fire when the failure percentage rises by this many points from one --alert-slope-window to the next (0 disables)")
+    flag.Float64Var(&slopeWindow, "alert-slope-window", 60, "Slope alert window seconds")
+    flag.IntVar(&debounce, "alert-debounce", 2, "Completed buckets an alert condition must hold before firing or clearing")
+    flag.StringVar(&snapFormat, "snapshot-format", "text", "Snapshot files besides snapshot.json: text (header/stats/timeline .txt) or markdown (snapshot.md)")
+    flag.StringVar(&layout, "layout", "default", "Panel layout: "+strings.Join(ui.Layouts, ", "))
+    flag.StringVar(&dedup, "dedup", "instance_id,ts,attempt", "Entry fields that identify a duplicate for --dedup-window")
+    flag.IntVar(&dedupWindow, "dedup-window", 0, "Skip entries whose --dedup key matches one of the last N entries (0 disables)")
+    flag.StringVar(&webhookURL, "webhook-url", "", "POST a JSON payload here when an alert fires or clears; Slack incoming webhooks work as-is (optional)")
+    flag.StringVar(&webhookTmpl, "webhook-template", "", "Go text/template for the webhook message; fields .Kind .State .Value .Threshold .Window .TopReason .ValueText .ThresholdText (optional)")
+    flag.BoolVar(&quiet, "quiet", false, "For scripts: hide the PIA field while piactl reports nothing and silence best-effort warnings (unless --debug)")
+    flag.StringVar(&restartMark, "restart-marker", "", "Regex; log lines matching it mark a producer restart of that instance on the timeline (optional)")
+    flag.BoolVar(&prefixColors, "prefix-colors", true, "Color each log line's [instance] prefix with a stable per-instance color")
+    flag.BoolVar(&validate, "validate", false, "Check each metrics line against the expected schema and count missing/wrong-typed fields (press v)")
+    flag.IntVar(&shrinkPolls, "shrink-polls", 2, "Polls a file must stay smaller than its read offset before it's treated as truncated (a replaced file is re-read at once)")
+    flag.IntVar(&slowest, "slowest", 20, "Keep the N slowest requests by elapsed_ms for the w view and snapshots (0 disables)")
+    flag.StringVar(&watch, "watch", "poll", "How the UI notices new lines: poll (every --refresh) or fsnotify (on write events; falls back to polling past 16 files)")
+    flag.DurationVar(&retainDur, "retain-duration", 0, "Timeline history as a duration, e.g. 2h; the bucket count follows the bucket size (default: 72 buckets)")
+    flag.StringVar(&chartStyle, "timeline-style", "density", "Timeline rendering: density (character ramp and failure row) or health (bars: height = volume, color = success rate)")
+    flag.IntVar(&reasonTrends, "reason-trends", 8, "Failure reasons (most failures first) given a per-bucket trend sparkline in the v view; the rest are folded into (other) (0 disables)")
+    flag.IntVar(&maxFiles, "max-files", 0, "Read only the N most recently modified files matching --logs / --metrics (each), ignoring older ones (0 = all)")
+    flag.StringVar(&successMark, "success-mark", "S", "Timeline character for success-only buckets")
+    flag.StringVar(&failMark, "fail-mark", "F", "Timeline failure-row character for fail-only buckets")
+    flag.BoolVar(&compactNums, "compact-numbers", false, "Abbreviate large counts on screen (1.3M, 284K); snapshots and copies keep every digit")
+    flag.StringVar(&pinRegions, "pin-region", "", "Comma-separated regions always listed (first, marked *) in the stats panel's region table (optional)")
+    flag.StringVar(&pinInstances, "pin-instance", "", "Comma-separated instances always listed in an Instances table in the stats panel; P in the i view pins/unpins one (optional)")
+    flag.StringVar(&groupBy, "group-by", "", "Also count success/fail by a custom key: '$.field' (or '$.outer.inner') from the metrics JSON, or a regex on the raw line whose first capture group is the key (optional)")
+    flag.IntVar(&maxLabels, "group-by-max", 100, "Distinct --group-by keys tracked before the rest are counted as (other) (0 = unbounded)")
+    flag.BoolVar(&confirmQuit, "confirm-quit", false, "Make q ask 'Quit? (y/n)' before exiting; Q and Ctrl-C still exit at once")
+    flag.StringVar(&checkpointPath, "checkpoint", "", "Save each log/metrics file's read offset here and resume from it on restart, unless the file was rotated (optional)")
+    flag.Float64Var(&checkpointEvery, "checkpoint-every", 30, "Seconds between --checkpoint saves (one is also saved on exit)")
+    flag.IntVar(&expectedTotal, "expected-total", 0, "Batch size for the progress panel, overriding any total_targets in metrics (implies --targets)")
+    flag.StringVar(&piaColors, "pia-colors", "", "Header colors for PIA connection states, State=color,... over the defaults (Connected=green, transitional yellow, Disconnected/na red); 'none' for plain")
+    flag.DurationVar(&activeWindow, "active-window", 5*time.Minute, "Instances and regions with an entry this recent count as active in the stats panel")
+    flag.BoolVar(&strict, "strict", false, "Refuse to start when --logs and --metrics match the same files (otherwise a warning)")
+    flag.StringVar(&seriesSpec, "series", "", "Timeline series stacked as labelled rows instead of the bars: comma-separated volume, fails, failrate, latency (toggle with 1-4)")
+    flag.BoolVar(&latestOnly, "latest-per-instance", false, "Read only the most recently modified file per instance (grouped by --name-regex), skipping rotated backups")
+    flag.IntVar(&maxRegions, "max-regions", 200, "Distinct regions tracked before the rest are counted as (other) (0 = unbounded)")
+    flag.IntVar(&maxReasons, "max-reasons", 200, "Distinct failure reasons tracked before the rest are counted as (other) (0 = unbounded)")
+    flag.IntVar(&maxTargets, "max-targets", 1000000, "Distinct --targets tracked; entries for further targets are only counted as untracked (0 = unbounded)")
+    flag.IntVar(&maxLogLines, "max-log-lines", 5000, "Lines kept in each log pane; older ones scroll away (0 = unbounded)")
+    flag.StringVar(&recordPath, "record", "", "Write every ingested metrics entry to this NDJSON file, replayable with --replay (optional)")
+    flag.StringVar(&runLabel, "label", "", "Label this run (e.g. the experiment) in the header, snapshots and event log; L edits it live (optional)")
+    flag.BoolVar(&attemptReset, "attempt-restarts", false, "Also count a producer restart when an instance's attempt number drops back to 1 after being higher")
+    flag.StringVar(&remoteWrite, "remote-write", "", "Push each completed timeline bucket, stamped with its start time, to this Prometheus remote-write URL (optional)")
+    flag.DurationVar(&idleMax, "idle-max", 0, "While ticks find no new data, back the poll interval off from --refresh up to this, e.g. 10s; the first new data snaps it back (0 = off)")
+    flag.IntVar(&idleAfter, "idle-after", 3, "Quiet ticks before --idle-max starts backing off")
+    flag.StringVar(&incidentDir, "snapshot-on-alert", "", "When an alert fires or clears, write a snapshot set into a timestamped incident-* subdirectory of this dir (optional)")
+    flag.DurationVar(&incidentWin, "incident-events", 0, "With --snapshot-on-alert, also save the retained raw entries from this long before the transition as events.jsonl (needs --retain-events)")
+    flag.BoolVar(&okReasons, "success-reasons", false, "Also tally the reason successful entries carry (e.g. cache_hit), shown by r in the stats panel and in snapshot.json")
+    flag.BoolVar(&strictJSON, "strict-json", false, "Reject (count, don't ingest) metrics entries with fields the entry schema doesn't have; --debug names the field")
+    flag.BoolVar(&exitCodes, "exit-codes", true, "Headless exit status: 1 error, 2 alert active at exit, 3 no data ingested, 4 a write failed (false: always 0; an invalid command line exits 1 regardless)")
+    flag.StringVar(&softFail, "soft-fail-reasons", "", "Comma-separated reasons (e.g. 429) that make a success a soft failure: counted and rated apart, still a success in the totals (optional)")
+    flag.BoolVar(&summaryExit, "summary-on-exit", false, "When the UI quits, print a final summary (run time, totals, rate, top regions, alert state) to stderr")
+    flag.StringVar(&unknownLabel, "unknown-label", "unknown", "Region/instance that metrics entries without batch_region or instance_id are counted under; the stats panel shows how many")
+    flag.BoolVar(&ansiLogs, "ansi-logs", false, "Show the ANSI colors (SGR codes) in log lines instead of the raw escape codes; other escape sequences are dropped")
+    flag.Float64Var(&sampleRate, "sample-rate", 1, "Fraction of log lines the logs panes show, picked at random (e.g. 0.01 for 1%), for very high log volume; metrics are still counted in full")
+    flag.BoolVar(&preFill, "prefill-buckets", true, "Once history has been read at startup, fill the gaps between its buckets with empty ones (up to the retained timeline) so sparse history isn't drawn collapsed")
+    flag.Float64Var(&anomalyZ, "anomaly-z", 0, "Mark completed buckets whose volume or failure count is this many standard deviations from the trailing mean (0 disables)")
+    flag.IntVar(&anomalyWin, "anomaly-window", 30, "Completed buckets the --anomaly-z mean and standard deviation are taken over")
+    flag.StringVar(&controlSock, "control-sock", "", "Serve a line-protocol control socket here (Unix domain socket): stats, regions, instances, reasons, reset, pause, resume, snapshot (optional)")
+    flag.StringVar(&sqlitePath, "sqlite", "", "Append each completed timeline bucket (counts and per-region breakdown) to this SQLite database (optional)")
+    flag.StringVar(&promAddr, "prom-addr", "", "Serve Prometheus metrics on this address at /metrics, e.g. localhost:9110; OpenMetrics with latency exemplars when the scraper asks for it (optional)")
+    flag.StringVar(&pprofAddr, "pprof-addr", "", "Serve net/http/pprof on this address, e.g. localhost:6060 (optional)")
+    flag.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile to this file (optional)")
+    flag.StringVar(&memProfile, "memprofile", "", "Write a heap profile to this file on exit (optional)")
+    // a bad flag exits 1 like any other setup error, not flag's 2 (ExitAlert)
+    flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+    if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+        if err != flag.ErrHelp {
+            code = 1
+        }
+        return
+    }
+
+    var nameRe *regexp.Regexp
+    if nameRegex != "" {
+        re, err := regexp.Compile(nameRegex)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "error: --name-regex:", err)
+            code = 1
+            return
+        }
+        nameRe = re
+    }
+    if latestOnly && nameRe == nil {
+        fmt.Fprintln(os.Stderr, "error: --latest-per-instance: needs --name-regex to group files by instance")
+        code = 1
+        return
+    }
+    var instanceRe *regexp.Regexp
+    if instanceNorm != "" {
+        re, err := regexp.Compile(instanceNorm)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "error: --instance-normalize:", err)
+            code = 1
+            return
+        }
+        instanceRe = re
+    }
+    var restartRe *regexp.Regexp
+    if restartMark != "" {
+        re, err := regexp.Compile(restartMark)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "error: --restart-marker:", err)
+            code = 1
+            return
+        }
+        restartRe = re
+    }
+    if snapFormat != "text" && snapFormat != "markdown" {
+        fmt.Fprintln(os.Stderr, "error: --snapshot-format: want text or markdown, got", snapFormat)
+        code = 1
+        return
+    }
+    if watch != "poll" && watch != "fsnotify" {
+        fmt.Fprintln(os.Stderr, "error: --watch: want poll or fsnotify, got", watch)
+        code = 1
+        return
+    }
+    if !ui.ValidLayout(layout) {
+        fmt.Fprintln(os.Stderr, "error: --layout: want one of", strings.Join(ui.Layouts, ", "))
+        code = 1
+        return
+    }
+    if !ui.ValidChartStyle(chartStyle) {
+        fmt.Fprintln(os.Stderr, "error: --timeline-style: want one of", strings.Join(ui.ChartStyles, ", "))
+        code = 1
+        return
+    }
+    okMark, err := ui.ParseMark(successMark)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error: --success-mark:", err)
+        code = 1
+        return
+    }
+    failRune, err := ui.ParseMark(failMark)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error: --fail-mark:", err)
+        code = 1
+        return
+    }
+    if anomalyZ < 0 || anomalyWin < 1 {
+        fmt.Fprintln(os.Stderr, "error: --anomaly-z must not be negative and --anomaly-window must be at least 1")
+        code = 1
+        return
+    }
+    if checkpointEvery <= 0 {
+        fmt.Fprintln(os.Stderr, "error: --checkpoint-every: must be positive")
+        code = 1
+        return
+    }
+    piaColorMap, err := ui.ParsePIAColors(piaColors)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error: --pia-colors:", err)
+        code = 1
+        return
+    }
+    series, err := ui.ParseSeries(seriesSpec)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error: --series:", err)
+        code = 1
+        return
+    }
+    if activeWindow <= 0 {
+        fmt.Fprintln(os.Stderr, "error: --active-window: must be positive")
+        code = 1
+        return
+    }
+    if expectedTotal < 0 {
+        fmt.Fprintln(os.Stderr, "error: --expected-total: must not be negative")
+        code = 1
+        return
+    }
+    if expectedTotal > 0 {
+        targets = true // progress counts collapsed targets
+    }
+    if retainDur < 0 {
+        fmt.Fprintln(os.Stderr, "error: --retain-duration: must not be negative")
+        code = 1
+        return
+    }
+    if sqlitePath != "" && tailOnly {
+        fmt.Fprintln(os.Stderr, "error: --sqlite needs metrics, not --tail-only")
+        code = 1
+        return
+    }
+    if remoteWrite != "" && !strings.HasPrefix(remoteWrite, "http://") && !strings.HasPrefix(remoteWrite, "https://") {
+        fmt.Fprintln(os.Stderr, "error: --remote-write: want an http:// or https:// URL")
+        code = 1
+        return
+    }
+    if refreshMin <= 0 || refreshStep <= 0 || refreshMax < 0 {
+        fmt.Fprintln(os.Stderr, "error: --refresh-min and --refresh-step must be positive and --refresh-max not negative")
+        code = 1
+        return
+    }
+    if refreshMax > 0 && refreshMin > refreshMax {
+        fmt.Fprintln(os.Stderr, "error: --refresh-min must not be above --refresh-max")
+        code = 1
+        return
+    }
+    if idleMax < 0 || idleAfter < 0 {
+        fmt.Fprintln(os.Stderr, "error: --idle-max and --idle-after must not be negative")
+        code = 1
+        return
+    }
+    if incidentWin < 0 {
+        fmt.Fprintln(os.Stderr, "error: --incident-events: must not be negative")
+        code = 1
+        return
+    }
+    if incidentWin > 0 && (incidentDir == "" || retainEvents <= 0) {
+        fmt.Fprintln(os.Stderr, "error: --incident-events: needs --snapshot-on-alert and --retain-events")
+        code = 1
+        return
+    }
+    if strings.TrimSpace(unknownLabel) == "" {
+        fmt.Fprintln(os.Stderr, "error: --unknown-label: must not be empty")
+        code = 1
+        return
+    }
+    if sampleRate <= 0 || sampleRate > 1 {
+        fmt.Fprintln(os.Stderr, "error: --sample-rate: must be above 0 and at most 1")
+        code = 1
+        return
+    }
+
+    if pprofAddr != "" {
+        go func() {
+            if err := http.ListenAndServe(pprofAddr, nil); err != nil {
+                fmt.Fprintln(os.Stderr, "pprof:", err)
+            }
+        }()
+    }
+    if cpuProfile != "" {
+        f, err := os.Create(cpuProfile)
+        if err != nil {
+            fmt.Fprintln(os.Stderr, "error:", err)
+            code = 1
+            return
+        }
+        defer f.Close()
+        if err := pprof.StartCPUProfile(f); err != nil {
+            fmt.Fprintln(os.Stderr, "error:", err)
+            code = 1
+            return
+        }
+        defer pprof.StopCPUProfile()
+    }
 
     cfg := ui.AppConfig{
-        LogsGlob:    logs,
-        MetricsGlob: metrics,
-        Refresh:     time.Duration(refresh*1000) * time.Millisecond,
-        Bucket:      bucket,
-        SnapshotDir: snapshot,
-        QuitAfter:   time.Duration(quitAfter*1000) * time.Millisecond,
-        Debug:       debug,
-        Headless:    headless,
-        Simulate:    simulate,
+        LogsGlob:     logs,
+        MetricsGlob:  metrics,
+        Refresh:      time.Duration(refresh*1000) * time.Millisecond,
+        Bucket:       bucket,
+        SnapshotDir:  snapshot,
+        QuitAfter:    time.Duration(quitAfter*1000) * time.Millisecond,
+        Debug:        debug,
+        Headless:     headless,
+        Simulate:     simulate,
+        Interleave:   interleave,
+        NameRegex:    nameRe,
+        StaleAfter:   time.Duration(staleAfter*1000) * time.Millisecond,
+        MinSamples:   minSamples,
+        Targets:      targets,
+        EventLog:     eventLog,
+        Legend:       legend,
+        RetainEvents: retainEvents,
+        RateColors:   rateColors,
+        TailOnly:     tailOnly,
+        SplitMax:     splitMax,
+        HealthSpec:   healthWeights,
+        Replay:       replayDir,
+        ReplaySpeed:  replaySpeed,
+        InstanceNorm: instanceRe,
+        MaxInstances: maxInstances,
+        AlertRate:    alertRate,
+        AlertWindow:  time.Duration(alertWindow*1000) * time.Millisecond,
+        AlertSlope:   alertSlope,
+        SlopeWindow:  time.Duration(slopeWindow*1000) * time.Millisecond,
+        Debounce:     debounce,
+        SnapFormat:   snapFormat,
+        Layout:       layout,
+        Dedup:        dedup,
+        DedupWindow:  dedupWindow,
+        WebhookURL:   webhookURL,
+        WebhookTmpl:  webhookTmpl,
+        Quiet:        quiet,
+        RestartMark:  restartRe,
+        PrefixColors: prefixColors,
+        Validate:     validate,
+        ShrinkPolls:  shrinkPolls,
+        Slowest:      slowest,
+        Watch:        watch,
+        ControlSock:  controlSock,
+        RetainDur:    retainDur,
+        ChartStyle:   chartStyle,
+        ReasonTrends: reasonTrends,
+        MaxFiles:     maxFiles,
+        SuccessMark:  okMark,
+        FailMark:     failRune,
+        PromAddr:     promAddr,
+        SQLite:       sqlitePath,
+        AnomalyZ:     anomalyZ,
+        AnomalyWin:   anomalyWin,
+        CompactNums:  compactNums,
+        PinRegions:   pinRegions,
+        PinInstances: pinInstances,
+        GroupBy:      groupBy,
+        MaxLabels:    maxLabels,
+        ConfirmQuit:  confirmQuit,
+        Checkpoint:   checkpointPath,
+        CkEvery:      time.Duration(checkpointEvery*1000) * time.Millisecond,
+        ExpectedTot:  expectedTotal,
+        PIAColors:    piaColorMap,
+        ActiveWin:    activeWindow,
+        Strict:       strict,
+        Series:       series,
+        LatestOnly:   latestOnly,
+        MaxRegions:   maxRegions,
+        MaxReasons:   maxReasons,
+        MaxTargets:   maxTargets,
+        MaxLogLines:  maxLogLines,
+        Record:       recordPath,
+        Label:        runLabel,
+        AttemptReset: attemptReset,
+        RemoteWrite:  remoteWrite,
+        IdleMax:      idleMax,
+        IdleAfter:    idleAfter,
+        IncidentDir:  incidentDir,
+        IncidentWin:  incidentWin,
+        OKReasons:    okReasons,
+        StrictJSON:   strictJSON,
+        RefreshMin:   time.Duration(refreshMin*1000) * time.Millisecond,
+        RefreshMax:   time.Duration(refreshMax*1000) * time.Millisecond,
+        RefreshStep:  time.Duration(refreshStep*1000) * time.Millisecond,
+        SoftFail:     softFail,
+        SummaryExit:  summaryExit,
+        UnknownLabel: unknownLabel,
+        ANSILogs:     ansiLogs,
+        SampleRate:   sampleRate,
+        PreFill:      preFill,
     }
 
     app := ui.NewApp(cfg)
     if err := app.Run(); err != nil {
-        fmt.Println("error:", err)
+        fmt.Fprintln(os.Stderr, "error:", err)
+        if exitCodes {
+            code = 1
+        }
+    } else if exitCodes {
+        code = app.ExitCode()
+    }
+
+    if memProfile != "" {
+        writeHeapProfile(memProfile)
+    }
+}
+
+// runDiff implements `secmon diff <snapshotA> <snapshotB>`.
+func runDiff(args []string) int {
+    fs := flag.NewFlagSet("diff", flag.ExitOnError)
+    color := fs.Bool("color", false, "Colorize increases/decreases")
+    fs.Usage = func() {
+        fmt.Fprintln(os.Stderr, "usage: secmon diff [--color] <snapshotA.json> <snapshotB.json>")
+        fs.PrintDefaults()
+    }
+    fs.Parse(args)
+    if fs.NArg() != 2 {
+        fs.Usage()
+        return 2
+    }
+    a, err := snapshot.Load(fs.Arg(0))
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error:", err)
+        return 1
+    }
+    b, err := snapshot.Load(fs.Arg(1))
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "error:", err)
+        return 1
+    }
+    snapshot.Diff(os.Stdout, a, b, *color)
+    return 0
+}
+
+func writeHeapProfile(path string) {
+    f, err := os.Create(path)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "memprofile:", err)
+        return
+    }
+    defer f.Close()
+    runtime.GC()
+    if err := pprof.WriteHeapProfile(f); err != nil {
+        fmt.Fprintln(os.Stderr, "memprofile:", err)
     }
 }
 
@@ -3,7 +3,10 @@ module secmon
 go 1.21
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/gdamore/tcell/v2 v2.7.4
+	github.com/mattn/go-runewidth v0.0.15
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/rivo/tview v0.0.0-20240530084007-30de98561a17
 )
 
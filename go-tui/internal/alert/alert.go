@@ -0,0 +1,152 @@
+package alert
+
+import (
+    "fmt"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// Kinds of alert.
+const (
+    Level = "level" // failure rate above a threshold
+    Slope = "slope" // failure rate rising faster than a threshold
+)
+
+// Monitor evaluates failure-rate alerts over the timeline. It only looks at
+// completed buckets (never the current partial one) and re-evaluates once per
+// newly completed bucket, so Debounce counts buckets, not refresh ticks.
+type Monitor struct {
+    FailRate    float64       // level: fire at this failure percentage (0 disables)
+    Window      time.Duration // level: trailing window the rate is measured over
+    SlopePP     float64       // slope: fire when the rate rises by this many percentage points (0 disables)
+    SlopeWindow time.Duration // slope: compare this window with the one before it
+    Debounce    int           // consecutive buckets a condition must hold to fire or clear
+    MinSamples  int           // events a window needs before it is judged
+
+    level, slope state
+    lastBucket   time.Time
+}
+
+type state struct {
+    Active bool
+    Since  time.Time
+    Value  float64 // last measured value (percent or pp)
+    streak int     // consecutive evaluations disagreeing with Active
+}
+
+// Transition reports an alert firing or clearing.
+type Transition struct {
+    Kind      string
+    Fired     bool
+    Value     float64
+    Threshold float64
+    Window    time.Duration
+}
+
+func (t Transition) String() string {
+    verb := "cleared"
+    if t.Fired { verb = "fired" }
+    if t.Kind == Slope {
+        return fmt.Sprintf("%s alert %s: %+.1fpp over %s (threshold %+.1fpp)", t.Kind, verb, t.Value, t.Window, t.Threshold)
+    }
+    return fmt.Sprintf("%s alert %s: fail rate %.1f%% over %s (threshold %.1f%%)", t.Kind, verb, t.Value, t.Window, t.Threshold)
+}
+
+// Status is the current state of both alerts.
+type Status struct {
+    Level, Slope bool
+    LevelValue   float64 // percent
+    SlopeValue   float64 // percentage points
+    LevelSince   time.Time
+    SlopeSince   time.Time
+}
+
+func (s Status) Any() bool { return s.Level || s.Slope }
+
+func (m *Monitor) Status() Status {
+    return Status{
+        Level: m.level.Active, LevelValue: m.level.Value, LevelSince: m.level.Since,
+        Slope: m.slope.Active, SlopeValue: m.slope.Value, SlopeSince: m.slope.Since,
+    }
+}
+
+// Evaluate checks the timeline and returns any transitions. The last bucket is
+// treated as the partial one.
+func (m *Monitor) Evaluate(timeline []metrics.Bucket, bucketSecs int, now time.Time) []Transition {
+    if len(timeline) < 2 || bucketSecs < 1 {
+        return nil
+    }
+    done := timeline[:len(timeline)-1]
+    newest := done[len(done)-1].Start
+    if newest.Equal(m.lastBucket) {
+        return nil
+    }
+    m.lastBucket = newest
+
+    var out []Transition
+    if m.FailRate > 0 {
+        n := buckets(m.Window, bucketSecs)
+        if rate, ok := failRate(tail(done, n, 0), m.MinSamples); ok {
+            if t, changed := m.level.step(rate >= m.FailRate, rate, m.Debounce, now); changed {
+                out = append(out, Transition{Kind: Level, Fired: t, Value: rate, Threshold: m.FailRate, Window: m.Window})
+            }
+        }
+    }
+    if m.SlopePP > 0 {
+        n := buckets(m.SlopeWindow, bucketSecs)
+        recent, ok1 := failRate(tail(done, n, 0), m.MinSamples)
+        prev, ok2 := failRate(tail(done, n, n), m.MinSamples)
+        if ok1 && ok2 {
+            d := recent - prev
+            if t, changed := m.slope.step(d >= m.SlopePP, d, m.Debounce, now); changed {
+                out = append(out, Transition{Kind: Slope, Fired: t, Value: d, Threshold: m.SlopePP, Window: m.SlopeWindow})
+            }
+        }
+    }
+    return out
+}
+
+// step records one evaluation and reports whether Active flipped.
+func (s *state) step(cond bool, v float64, debounce int, now time.Time) (bool, bool) {
+    s.Value = v
+    if cond == s.Active {
+        s.streak = 0
+        return s.Active, false
+    }
+    s.streak++
+    if s.streak < debounce {
+        return s.Active, false
+    }
+    s.Active, s.streak, s.Since = cond, 0, now
+    return s.Active, true
+}
+
+func buckets(d time.Duration, bucketSecs int) int {
+    n := int((d + time.Duration(bucketSecs)*time.Second - 1) / (time.Duration(bucketSecs) * time.Second))
+    if n < 1 { n = 1 }
+    return n
+}
+
+// tail returns n buckets ending skip buckets before the end of b.
+func tail(b []metrics.Bucket, n, skip int) []metrics.Bucket {
+    end := len(b) - skip
+    if end <= 0 {
+        return nil
+    }
+    start := end - n
+    if start < 0 { start = 0 }
+    return b[start:end]
+}
+
+func failRate(b []metrics.Bucket, minSamples int) (float64, bool) {
+    s, f := 0, 0
+    for _, p := range b {
+        s += p.Success
+        f += p.Fail
+    }
+    if s+f == 0 || s+f < minSamples {
+        return 0, false
+    }
+    return 100 * float64(f) / float64(s+f), true
+}
@@ -0,0 +1,90 @@
+package alert
+
+import (
+    "fmt"
+    "math"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// minAnomalyHistory is how many trailing buckets a bucket is needed against
+// before it can be judged; fewer make the deviation meaningless.
+const minAnomalyHistory = 5
+
+// Anomalies flags completed buckets whose volume or failure count is a
+// z-score outlier against the Window completed buckets before them. Volume
+// is flagged both ways (a spike or a drop); failures only when they spike.
+// Each bucket is judged once, as soon as it completes.
+type Anomalies struct {
+    Z      float64 // |z| at or above this is an outlier (0 disables)
+    Window int     // trailing buckets the mean and deviation are taken over
+
+    last time.Time // start of the newest bucket judged
+}
+
+// Anomaly is one flagged bucket.
+type Anomaly struct {
+    Start  time.Time
+    Series string // "volume" or "failures"
+    Value  int
+    Mean   float64
+    Std    float64
+    Z      float64
+}
+
+func (a Anomaly) String() string {
+    return fmt.Sprintf("%s anomaly at %s: %d vs mean %.1f (sd %.1f, z=%+.1f)", a.Series, a.Start.Format("15:04:05"), a.Value, a.Mean, a.Std, a.Z)
+}
+
+// Check judges the buckets (oldest first, as Aggregator.Buckets returns
+// them) that completed since the last call.
+func (d *Anomalies) Check(buckets []metrics.Bucket) []Anomaly {
+    if d.Z <= 0 || len(buckets) < 2 {
+        return nil
+    }
+    done := buckets[:len(buckets)-1] // the newest bucket is still filling
+    var out []Anomaly
+    for i, b := range done {
+        if !b.Start.After(d.last) {
+            continue
+        }
+        d.last = b.Start
+        lo := i - d.Window
+        if lo < 0 { lo = 0 }
+        hist := done[lo:i]
+        if len(hist) < minAnomalyHistory {
+            continue
+        }
+        vol := make([]float64, len(hist))
+        fail := make([]float64, len(hist))
+        for j, h := range hist {
+            vol[j], fail[j] = float64(h.Total()), float64(h.Fail)
+        }
+        if an, ok := outlier(b.Start, "volume", b.Total(), vol, d.Z, true); ok {
+            out = append(out, an)
+        }
+        if an, ok := outlier(b.Start, "failures", b.Fail, fail, d.Z, false); ok {
+            out = append(out, an)
+        }
+    }
+    return out
+}
+
+// outlier z-scores v against hist; a flat history (no deviation) never
+// flags. Drops count only when both is set.
+func outlier(start time.Time, series string, v int, hist []float64, z float64, both bool) (Anomaly, bool) {
+    var sum, sq float64
+    for _, x := range hist { sum += x }
+    mean := sum / float64(len(hist))
+    for _, x := range hist { sq += (x - mean) * (x - mean) }
+    std := math.Sqrt(sq / float64(len(hist)))
+    if std == 0 {
+        return Anomaly{}, false
+    }
+    score := (float64(v) - mean) / std
+    if score < z && !(both && -score >= z) {
+        return Anomaly{}, false
+    }
+    return Anomaly{Start: start, Series: series, Value: v, Mean: mean, Std: std, Z: score}, true
+}
@@ -0,0 +1,67 @@
+package checkpoint
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "time"
+
+    "secmon/internal/tail"
+)
+
+// State is what a checkpoint file holds: where reading stopped in each log
+// and metrics file, so a restart resumes there instead of re-reading (and
+// re-counting) everything from the start.
+type State struct {
+    Saved   time.Time              `json:"saved"`
+    Logs    map[string]tail.Offset `json:"logs"`
+    Metrics map[string]tail.Offset `json:"metrics"`
+}
+
+// Load reads the checkpoint at path. A missing file is an empty State, as on
+// a first run.
+func Load(path string) (*State, error) {
+    b, err := os.ReadFile(path)
+    if errors.Is(err, fs.ErrNotExist) {
+        return &State{}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var s State
+    if err := json.Unmarshal(b, &s); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return &s, nil
+}
+
+// Save writes s to path atomically: a crash mid-write leaves the previous
+// checkpoint, never a truncated one.
+func Save(path string, s *State) error {
+    b, err := json.MarshalIndent(s, "", "  ")
+    if err != nil {
+        return err
+    }
+    f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+    if err != nil {
+        return err
+    }
+    tmp := f.Name()
+    _, err = f.Write(b)
+    if err == nil {
+        err = f.Sync()
+    }
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err == nil {
+        err = os.Rename(tmp, path)
+    }
+    if err != nil {
+        os.Remove(tmp)
+    }
+    return err
+}
@@ -0,0 +1,90 @@
+package control
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "strings"
+    "sync"
+)
+
+// Handler answers one command line (already split into the command and its
+// arguments) with a single-line response.
+type Handler func(cmd string, args []string) string
+
+// Server serves a line protocol on a Unix domain socket: one command per
+// line, one response line back. Connections are handled concurrently; the
+// handler must be safe for that.
+type Server struct {
+    path string
+    ln   net.Listener
+    h    Handler
+    wg   sync.WaitGroup
+}
+
+// Listen creates the socket at path, replacing a stale one left by a
+// process that exited without cleaning up. It refuses to take over a socket
+// something is still listening on, or to remove anything but a socket.
+func Listen(path string, h Handler) (*Server, error) {
+    if fi, err := os.Lstat(path); err == nil {
+        if fi.Mode()&os.ModeSocket == 0 {
+            return nil, fmt.Errorf("%s: exists and is not a socket", path)
+        }
+        if c, err := net.Dial("unix", path); err == nil {
+            c.Close()
+            return nil, fmt.Errorf("%s: already in use", path)
+        }
+        os.Remove(path)
+    }
+    ln, err := net.Listen("unix", path)
+    if err != nil {
+        return nil, err
+    }
+    s := &Server{path: path, ln: ln, h: h}
+    s.wg.Add(1)
+    go s.accept()
+    return s, nil
+}
+
+func (s *Server) accept() {
+    defer s.wg.Done()
+    for {
+        c, err := s.ln.Accept()
+        if err != nil {
+            if errors.Is(err, net.ErrClosed) {
+                return
+            }
+            continue
+        }
+        go s.serve(c)
+    }
+}
+
+func (s *Server) serve(c net.Conn) {
+    defer c.Close()
+    sc := bufio.NewScanner(c)
+    for sc.Scan() {
+        fields := strings.Fields(sc.Text())
+        if len(fields) == 0 {
+            continue
+        }
+        if fields[0] == "quit" {
+            return
+        }
+        resp := strings.ReplaceAll(s.h(fields[0], fields[1:]), "\n", " ")
+        if _, err := fmt.Fprintln(c, resp); err != nil {
+            return
+        }
+    }
+}
+
+// Close stops accepting connections and removes the socket file. Open
+// connections finish their current command and are closed by the client.
+func (s *Server) Close() error {
+    err := s.ln.Close()
+    s.wg.Wait()
+    os.Remove(s.path)
+    return err
+}
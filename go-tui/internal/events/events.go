@@ -0,0 +1,86 @@
+package events
+
+import (
+    "bufio"
+    "encoding/json"
+    "os"
+    "sync"
+    "time"
+)
+
+// Log appends one JSON object per line to a file for SIEM ingestion. Every
+// event carries "type" and "ts", and "label" once SetLabel has set one; other
+// fields depend on the type. A nil *Log discards events, so callers needn't
+// check whether --event-log was given.
+type Log struct {
+    mu    sync.Mutex
+    f     *os.File
+    w     *bufio.Writer
+    label string
+}
+
+// Event types.
+const (
+    AlertFired     = "alert_fired"
+    AlertCleared   = "alert_cleared"
+    IPRotation     = "vpn_ip_rotation"
+    StaleOnset     = "stale_onset"
+    StaleRecovery  = "stale_recovery"
+    MalformedBurst = "malformed_burst"
+    Anomaly        = "bucket_anomaly"
+    LabelChanged   = "label_changed"
+    Restart        = "producer_restart"
+)
+
+func Open(path string) (*Log, error) {
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+    if err != nil {
+        return nil, err
+    }
+    return &Log{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Emit writes one event line and flushes it.
+func (l *Log) Emit(typ string, fields map[string]any) error {
+    if l == nil {
+        return nil
+    }
+    ev := make(map[string]any, len(fields)+2)
+    for k, v := range fields {
+        ev[k] = v
+    }
+    ev["type"] = typ
+    ev["ts"] = time.Now().UTC().Format(time.RFC3339Nano)
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.label != "" {
+        ev["label"] = l.label
+    }
+    b, err := json.Marshal(ev)
+    if err != nil {
+        return err
+    }
+    l.w.Write(b)
+    l.w.WriteByte('\n')
+    return l.w.Flush()
+}
+
+// SetLabel tags every later event with the run label ("" for none).
+func (l *Log) SetLabel(label string) {
+    if l == nil {
+        return
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.label = label
+}
+
+func (l *Log) Close() error {
+    if l == nil {
+        return nil
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.w.Flush()
+    return l.f.Close()
+}
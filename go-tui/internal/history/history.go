@@ -0,0 +1,127 @@
+package history
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    _ "github.com/mattn/go-sqlite3"
+)
+
+const schema = `CREATE TABLE IF NOT EXISTS buckets (
+    start       INTEGER NOT NULL, -- Unix seconds
+    bucket_secs INTEGER NOT NULL,
+    success     INTEGER NOT NULL,
+    fail        INTEGER NOT NULL,
+    regions     TEXT    NOT NULL, -- {"region": {"success": n, "fail": n}}
+    PRIMARY KEY (start, bucket_secs)
+)`
+
+// Row is one completed timeline bucket.
+type Row struct {
+    Start   time.Time
+    Secs    int
+    Success int
+    Fail    int
+    Regions map[string][2]int // [success, fail]
+}
+
+type regionCounts struct {
+    Success int `json:"success"`
+    Fail    int `json:"fail"`
+}
+
+// DB appends completed buckets to a SQLite database, one row per bucket.
+// Each is written once: rows already present (say, from a run that read the
+// same files before a restart) are left alone.
+type DB struct {
+    db   *sql.DB
+    last map[int]time.Time // bucket size -> start of the newest row
+}
+
+// Open opens (creating if needed) the database at path.
+func Open(path string) (*DB, error) {
+    db, err := sql.Open("sqlite3", path)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := db.Exec(schema); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    h := &DB{db: db, last: make(map[int]time.Time)}
+    rows, err := db.Query(`SELECT bucket_secs, MAX(start) FROM buckets GROUP BY bucket_secs`)
+    if err != nil {
+        db.Close()
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var secs int
+        var start int64
+        if err := rows.Scan(&secs, &start); err != nil {
+            db.Close()
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+        h.last[secs] = time.Unix(start, 0)
+    }
+    return h, rows.Err()
+}
+
+// Flushed returns the start of the newest bucket of size secs already
+// written (zero if none): only later buckets need flushing.
+func (h *DB) Flushed(secs int) time.Time {
+    return h.last[secs]
+}
+
+// Flush writes rows in one transaction, skipping any already present, and
+// returns how many were new.
+func (h *DB) Flush(rows []Row) (int, error) {
+    if len(rows) == 0 {
+        return 0, nil
+    }
+    tx, err := h.db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    stmt, err := tx.Prepare(`INSERT OR IGNORE INTO buckets (start, bucket_secs, success, fail, regions) VALUES (?, ?, ?, ?, ?)`)
+    if err != nil {
+        tx.Rollback()
+        return 0, err
+    }
+    defer stmt.Close()
+    n := 0
+    for _, r := range rows {
+        regions := make(map[string]regionCounts, len(r.Regions))
+        for k, v := range r.Regions {
+            regions[k] = regionCounts{Success: v[0], Fail: v[1]}
+        }
+        js, err := json.Marshal(regions)
+        if err != nil {
+            tx.Rollback()
+            return 0, err
+        }
+        res, err := stmt.Exec(r.Start.Unix(), r.Secs, r.Success, r.Fail, string(js))
+        if err != nil {
+            tx.Rollback()
+            return 0, err
+        }
+        if c, _ := res.RowsAffected(); c > 0 {
+            n++
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return 0, err
+    }
+    for _, r := range rows {
+        if r.Start.After(h.last[r.Secs]) {
+            h.last[r.Secs] = r.Start
+        }
+    }
+    return n, nil
+}
+
+func (h *DB) Close() error {
+    return h.db.Close()
+}
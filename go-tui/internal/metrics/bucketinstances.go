@@ -0,0 +1,48 @@
+package metrics
+
+// TrackBucketInstances keeps per-instance counts for every timeline bucket,
+// for RecentInstances. Off by default: it keeps a map per bucket.
+func (a *Aggregator) TrackBucketInstances(on bool) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.bucketInstances = nil
+    if on {
+        a.bucketInstances = make(map[int]map[string][2]int)
+    }
+}
+
+// recordBucketInstance counts an entry of instance in bucket bt; call with
+// mu held.
+func (a *Aggregator) recordBucketInstance(instance string, success bool, bt int) {
+    if a.bucketInstances != nil {
+        countKey(a.bucketInstances, bt, instance, success)
+    }
+}
+
+// RecentInstances returns [success, fail] per instance over the n buckets
+// ending with the newest (the current, partial one included); nil unless
+// TrackBucketInstances is on. Instances with no entries in them are left
+// out.
+func (a *Aggregator) RecentInstances(n int) map[string][2]int {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    if a.bucketInstances == nil {
+        return nil
+    }
+    out := make(map[string][2]int)
+    if len(a.Timeline) == 0 {
+        return out
+    }
+    // by start time rather than position: the timeline can have gaps
+    first := a.Timeline[len(a.Timeline)-1][0] - (n-1)*a.BucketSecs
+    for bt, m := range a.bucketInstances {
+        if bt < first {
+            continue
+        }
+        for k, v := range m {
+            c := out[k]
+            out[k] = [2]int{c[0] + v[0], c[1] + v[1]}
+        }
+    }
+    return out
+}
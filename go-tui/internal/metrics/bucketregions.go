@@ -0,0 +1,51 @@
+package metrics
+
+import "time"
+
+// TrackBucketRegions keeps per-region counts for every timeline bucket, for
+// BucketRegions. Off by default: it keeps a map per bucket.
+func (a *Aggregator) TrackBucketRegions(on bool) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.bucketRegions = nil
+    if on {
+        a.bucketRegions = make(map[int]map[string][2]int)
+    }
+}
+
+// recordBucketRegion counts e in bucket bt; call with mu held.
+func (a *Aggregator) recordBucketRegion(region string, success bool, bt int) {
+    if a.bucketRegions != nil {
+        countKey(a.bucketRegions, bt, region, success)
+    }
+}
+
+// countKey adds one entry for key to bucket bt of a per-bucket breakdown.
+func countKey(buckets map[int]map[string][2]int, bt int, key string, success bool) {
+    m := buckets[bt]
+    if m == nil {
+        m = make(map[string][2]int)
+        buckets[bt] = m
+    }
+    c := m[key]
+    if success {
+        c[0]++
+    } else {
+        c[1]++
+    }
+    m[key] = c
+}
+
+// BucketRegions returns [success, fail] per region for the bucket starting
+// at start; nil unless TrackBucketRegions is on and the bucket has entries.
+func (a *Aggregator) BucketRegions(start time.Time) map[string][2]int {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    m := a.bucketRegions[int(start.Unix())]
+    if m == nil {
+        return nil
+    }
+    out := make(map[string][2]int, len(m))
+    for k, v := range m { out[k] = v }
+    return out
+}
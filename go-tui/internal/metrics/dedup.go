@@ -0,0 +1,89 @@
+package metrics
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// DefaultDedupKey is the key ParseDedupKey uses for an empty spec.
+const DefaultDedupKey = "instance_id,ts,attempt"
+
+// DedupKey selects the entry fields that identify a logical event.
+type DedupKey struct {
+    fields []func(Entry) string
+}
+
+// dedupFields maps entry JSON names to the text that goes into a dedup key.
+var dedupFields = map[string]func(e Entry) string{
+    "ts":                 func(e Entry) string { return string(e.TS) },
+    "instance_id":        func(e Entry) string { return e.InstanceID },
+    "attempt":            func(e Entry) string { return strconv.Itoa(e.Attempt) },
+    "success":            func(e Entry) string { return strconv.FormatBool(e.Success) },
+    "reason":             func(e Entry) string { return e.Reason },
+    "elapsed_ms":         func(e Entry) string { return strconv.Itoa(e.ElapsedMS) },
+    "proxy":              func(e Entry) string { return strconv.FormatBool(e.Proxy) },
+    "rotated_on_failure": func(e Entry) string { return strconv.FormatBool(e.RotatedOnFailure) },
+    "url":                func(e Entry) string { return e.URL },
+    "batch_region":       func(e Entry) string { return e.BatchRegion },
+}
+
+// seenSet remembers the last len(ring) keys, evicting the oldest first.
+type seenSet struct {
+    key  DedupKey
+    keys map[string]struct{}
+    ring []string
+    next int
+}
+
+// seen reports whether e's key is already in the set, adding it if not.
+func (s *seenSet) seen(e Entry) bool {
+    b := &strings.Builder{}
+    for i, f := range s.key.fields {
+        if i > 0 { b.WriteByte(0) }
+        b.WriteString(f(e))
+    }
+    k := b.String()
+    if _, ok := s.keys[k]; ok {
+        return true
+    }
+    if old := s.ring[s.next]; old != "" {
+        delete(s.keys, old)
+    }
+    s.ring[s.next] = k
+    s.keys[k] = struct{}{}
+    s.next = (s.next + 1) % len(s.ring)
+    return false
+}
+
+// ParseDedupKey parses a comma-separated list of entry JSON field names, e.g.
+// "instance_id,ts,attempt". An empty spec gives DefaultDedupKey.
+func ParseDedupKey(spec string) (DedupKey, error) {
+    if strings.TrimSpace(spec) == "" {
+        spec = DefaultDedupKey
+    }
+    var k DedupKey
+    for _, name := range strings.Split(spec, ",") {
+        f, ok := dedupFields[strings.TrimSpace(name)]
+        if !ok {
+            return DedupKey{}, fmt.Errorf("unknown dedup field %q", strings.TrimSpace(name))
+        }
+        k.fields = append(k.fields, f)
+    }
+    return k, nil
+}
+
+// Dedup skips entries whose key matches one of the last window keys seen,
+// counting them in DuplicatesSkipped instead. window <= 0 turns deduplication
+// off (the default). Keys are taken before instance normalization, so
+// producers retrying the same write collapse even when their IDs would later
+// be merged.
+func (a *Aggregator) Dedup(key DedupKey, window int) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if window <= 0 || len(key.fields) == 0 {
+        a.dedup = nil
+        return
+    }
+    a.dedup = &seenSet{key: key, keys: make(map[string]struct{}, window), ring: make([]string, window)}
+}
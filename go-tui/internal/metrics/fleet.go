@@ -0,0 +1,37 @@
+package metrics
+
+import "time"
+
+// Fleet counts the distinct instances and regions that have reported, and
+// how many of them reported within an activity window.
+type Fleet struct {
+    Instances       int
+    ActiveInstances int
+    Regions         int
+    ActiveRegions   int
+}
+
+// recordSeen keeps the newest entry time per instance and region; call with
+// mu held, after the keys have been normalized.
+func (a *Aggregator) recordSeen(instance, region string, ts time.Time) {
+    if ts.After(a.instanceSeen[instance]) {
+        a.instanceSeen[instance] = ts
+    }
+    if ts.After(a.regionSeen[region]) {
+        a.regionSeen[region] = ts
+    }
+}
+
+// Fleet counts instances and regions in the snapshot, active being those
+// with an entry no older than window before now.
+func (s Snapshot) Fleet(now time.Time, window time.Duration) Fleet {
+    since := now.Add(-window)
+    f := Fleet{Instances: len(s.InstanceSeen), Regions: len(s.RegionSeen)}
+    for _, t := range s.InstanceSeen {
+        if !t.Before(since) { f.ActiveInstances++ }
+    }
+    for _, t := range s.RegionSeen {
+        if !t.Before(since) { f.ActiveRegions++ }
+    }
+    return f
+}
@@ -0,0 +1,293 @@
+package metrics
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "secmon/internal/tail"
+)
+
+// Metrics file formats. JSONL files are tailed line by line; JSON arrays and
+// YAML documents are re-parsed whole whenever the file's size or mtime
+// changes, skipping the records already ingested. Their pos counts records,
+// not bytes, so they are left out of checkpoints.
+type fileFormat int
+
+const (
+    formatJSONL fileFormat = iota
+    formatJSONArray
+    formatYAML
+    formatPending // too little written to tell; sniffed again next poll
+)
+
+// sniffBytes is how much of a file detectFormat looks at.
+const sniffBytes = 512
+
+// detectFormat picks a file's format by extension (.yaml/.yml, .jsonl/.ndjson),
+// else by its start, which must parse: a JSON array of objects, or a YAML
+// mapping holding a metrics field. A plain log ("INFO: ...", "[2026-...]")
+// is JSONL, read line by line, never a document read whole. head is the
+// whole file when shorter than sniffBytes (whole); an array not yet written
+// far enough to tell is formatPending.
+func detectFormat(path string, head []byte, whole bool) fileFormat {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".yaml", ".yml":
+        return formatYAML
+    case ".jsonl", ".ndjson":
+        return formatJSONL
+    }
+    head = bytes.TrimLeft(head, " \t\r\n")
+    if len(head) == 0 || head[0] == '{' {
+        return formatJSONL
+    }
+    if head[0] == '[' {
+        dec := json.NewDecoder(bytes.NewReader(head))
+        dec.Token() // the '['
+        tok, err := dec.Token()
+        switch {
+        case err == nil && (tok == json.Delim('{') || tok == json.Delim(']')):
+            return formatJSONArray
+        case err == io.EOF && whole:
+            return formatPending
+        }
+        return formatJSONL
+    }
+    i := bytes.LastIndexByte(head, '\n')
+    if i < 0 {
+        if whole {
+            return formatPending // the first line isn't finished
+        }
+        return formatJSONL
+    }
+    if recs := yamlRecords(head[:i+1]); len(recs) > 0 && isRecord(recs[0]) {
+        return formatYAML
+    }
+    return formatJSONL
+}
+
+// isRecord reports whether a JSON object has one of the fields metrics
+// entries are written with.
+func isRecord(rec []byte) bool {
+    var m map[string]json.RawMessage
+    if json.Unmarshal(rec, &m) != nil {
+        return false
+    }
+    for _, k := range []string{"ts", "instance_id", "success"} {
+        if _, ok := m[k]; ok {
+            return true
+        }
+    }
+    return false
+}
+
+// format returns path's format, sniffing it on first read; call with
+// updateMu held. An empty file, or one formatPending, isn't settled until
+// more is written.
+func (a *Aggregator) format(path string, fi os.FileInfo) fileFormat {
+    if f, ok := a.formats[path]; ok {
+        return f
+    }
+    if fi.Size() == 0 {
+        return formatJSONL
+    }
+    f, err := a.Source.Open(path)
+    if err != nil {
+        return formatJSONL
+    }
+    defer f.Close()
+    head := make([]byte, sniffBytes)
+    n, _ := io.ReadFull(f, head)
+    format := detectFormat(path, head[:n], n < sniffBytes)
+    if format != formatPending {
+        a.formats[path] = format
+    }
+    return format
+}
+
+// readDocs returns the records of a JSON array or YAML file past the pos
+// already ingested, as JSON objects, and how many couldn't be parsed; call
+// with updateMu held. The file is only re-read when its size or mtime has
+// changed since the last parse.
+func (a *Aggregator) readDocs(path string, fi os.FileInfo, format fileFormat) (records [][]byte, malformed int, read int64) {
+    prev, ok := a.parsed[path]
+    if ok && !tail.SameFile(prev, fi) {
+        a.pos[path] = 0
+    } else if ok && prev.Size() == fi.Size() && prev.ModTime().Equal(fi.ModTime()) {
+        return nil, 0, 0
+    }
+    f, err := a.Source.Open(path)
+    if err != nil {
+        return nil, 0, 0
+    }
+    data, err := io.ReadAll(f)
+    f.Close()
+    if err != nil {
+        return nil, 0, 0
+    }
+    a.parsed[path] = fi
+    a.info[path] = fi
+    all := yamlRecords(data)
+    if format == formatJSONArray {
+        all = jsonArrayRecords(data)
+    }
+    done := int(a.pos[path])
+    if done > len(all) {
+        done = 0 // rewritten with fewer records: start over
+    }
+    a.pos[path] = int64(len(all))
+    for _, rec := range all[done:] {
+        if rec == nil {
+            malformed++
+        } else {
+            records = append(records, rec)
+        }
+    }
+    return records, malformed, int64(len(data))
+}
+
+// jsonArrayRecords streams the elements of a top-level JSON array; a nil
+// record stands for one that couldn't be parsed. An array still being
+// written (no closing bracket yet) yields its complete elements; anything
+// else that stops the decoder ends it with one nil record.
+func jsonArrayRecords(data []byte) [][]byte {
+    dec := json.NewDecoder(bytes.NewReader(data))
+    if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+        return [][]byte{nil}
+    }
+    var records [][]byte
+    for dec.More() {
+        var raw json.RawMessage
+        if err := dec.Decode(&raw); err != nil {
+            var se *json.SyntaxError
+            atEnd := errors.As(err, &se) && se.Offset >= int64(len(data))
+            if !atEnd && err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) {
+                records = append(records, nil)
+            }
+            break
+        }
+        records = append(records, raw)
+    }
+    return records
+}
+
+// yamlRecords splits data into "---"-separated YAML documents and converts
+// each to a JSON object, nil for one that couldn't be. Only what the metrics schema needs is understood:
+// one flat mapping of scalar values per document (quoted or plain strings,
+// numbers, true/false, null), with # comments. Anything nested counts as
+// malformed. The last document is held back until the file ends in a
+// newline, like a partial JSONL line.
+func yamlRecords(data []byte) [][]byte {
+    if len(data) > 0 && data[len(data)-1] != '\n' {
+        i := bytes.LastIndexByte(data, '\n')
+        if i < 0 {
+            return nil
+        }
+        data = data[:i+1]
+    }
+    var records [][]byte
+    var doc []string
+    flush := func() {
+        if len(doc) == 0 {
+            return
+        }
+        if rec, err := yamlDoc(doc); err != nil {
+            records = append(records, nil)
+        } else if rec != nil {
+            records = append(records, rec)
+        }
+        doc = doc[:0]
+    }
+    for _, line := range strings.Split(string(data), "\n") {
+        line = strings.TrimRight(line, "\r")
+        if line == "---" || strings.HasPrefix(line, "--- ") || line == "..." {
+            flush()
+            continue
+        }
+        doc = append(doc, line)
+    }
+    flush()
+    return records
+}
+
+// yamlDoc converts one flat YAML mapping to a JSON object; a document with
+// only blank lines and comments yields nil.
+func yamlDoc(lines []string) ([]byte, error) {
+    obj := make(map[string]any)
+    for _, line := range lines {
+        trimmed := strings.TrimSpace(line)
+        if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+            continue
+        }
+        if line[0] == ' ' || line[0] == '\t' || strings.HasPrefix(line, "- ") {
+            return nil, fmt.Errorf("nested YAML isn't supported: %q", line)
+        }
+        key, val, ok := strings.Cut(line, ":")
+        if !ok {
+            return nil, fmt.Errorf("not a key: value line: %q", line)
+        }
+        key = strings.TrimSpace(key)
+        if uk, err := yamlScalar(key); err == nil {
+            if s, ok := uk.(string); ok {
+                key = s
+            }
+        }
+        v, err := yamlScalar(strings.TrimSpace(val))
+        if err != nil {
+            return nil, err
+        }
+        obj[key] = v
+    }
+    if len(obj) == 0 {
+        return nil, nil
+    }
+    return json.Marshal(obj)
+}
+
+// yamlScalar reads one plain or quoted YAML scalar.
+func yamlScalar(s string) (any, error) {
+    switch {
+    case strings.HasPrefix(s, `"`):
+        end := strings.LastIndexByte(s, '"')
+        if end == 0 {
+            return nil, fmt.Errorf("unterminated string %s", s)
+        }
+        return strconv.Unquote(s[:end+1])
+    case strings.HasPrefix(s, "'"):
+        end := strings.LastIndexByte(s, '\'')
+        if end == 0 {
+            return nil, fmt.Errorf("unterminated string %s", s)
+        }
+        return strings.ReplaceAll(s[1:end], "''", "'"), nil
+    case strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") || s == "|" || s == ">":
+        return nil, fmt.Errorf("nested YAML isn't supported: %s", s)
+    }
+    if i := strings.Index(s, " #"); i >= 0 {
+        s = strings.TrimSpace(s[:i])
+    }
+    switch s {
+    case "", "~", "null", "Null", "NULL":
+        return nil, nil
+    case "true", "True", "TRUE":
+        return true, nil
+    case "false", "False", "FALSE":
+        return false, nil
+    }
+    if _, err := strconv.ParseFloat(s, 64); err == nil && json.Valid([]byte(s)) {
+        return json.Number(s), nil
+    }
+    return s, nil
+}
+
+// forgetFormat drops what's known about path's format; call with updateMu
+// held.
+func (a *Aggregator) forgetFormat(path string) {
+    delete(a.formats, path)
+    delete(a.parsed, path)
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+    tests := []struct {
+        name, path, head string
+        whole            bool
+        want             fileFormat
+    }{
+        {"jsonl", "m.log", `{"success":true}` + "\n", true, formatJSONL},
+        {"json array", "m.json", `[{"success":true},`, false, formatJSONArray},
+        {"empty array", "m.json", "[]\n", true, formatJSONArray},
+        {"array not yet written", "m.json", "[\n", true, formatPending},
+        {"bracketed log", "m.log", "[2026-01-02 03:04:05] INFO start\n", true, formatJSONL},
+        {"yaml", "m.txt", "---\nts: 2026-01-02T03:04:05\nsuccess: true\n", true, formatYAML},
+        {"yaml by extension", "m.yml", "anything\n", true, formatYAML},
+        {"log with a colon", "m.log", "INFO: worker started\nINFO: ready\n", true, formatJSONL},
+        {"unfinished first line", "m.txt", "ts: 2026", true, formatPending},
+        {"long first line", "m.txt", strings.Repeat("x", sniffBytes), false, formatJSONL},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := detectFormat(tt.path, []byte(tt.head), tt.whole); got != tt.want {
+                t.Errorf("detectFormat(%q, %q) = %d, want %d", tt.path, tt.head, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestYAMLRecords(t *testing.T) {
+    data := `# run 7
+---
+ts: "2026-01-02T03:04:05"
+instance_id: 'i''1'   # quoted
+success: true
+elapsed_ms: 250
+reason: null
+---
+instance_id: i2
+nested:
+  key: value
+---
+instance_id: i3
+success: false
+---
+instance_id: i4
+`
+    recs := yamlRecords([]byte(data + "success: tr"))
+    want := []string{
+        `{"elapsed_ms":250,"instance_id":"i'1","reason":null,"success":true,"ts":"2026-01-02T03:04:05"}`,
+        "", // nested: malformed
+        `{"instance_id":"i3","success":false}`,
+        `{"instance_id":"i4"}`, // the unfinished line is held back
+    }
+    if len(recs) != len(want) {
+        t.Fatalf("got %d records %q, want %d", len(recs), recs, len(want))
+    }
+    for i, w := range want {
+        if string(recs[i]) != w {
+            t.Errorf("record %d = %s, want %s", i, recs[i], w)
+        }
+    }
+}
+
+func TestYAMLFileNotCheckpointed(t *testing.T) {
+    dir := t.TempDir()
+    write := func(name, data string) {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    write("a.yaml", "instance_id: i1\nsuccess: true\n---\ninstance_id: i2\nsuccess: true\n")
+    write("b.jsonl", entryLine("i3", true))
+    a := NewAggregator(filepath.Join(dir, "*"), 60, 72)
+    a.Update()
+    if st := a.Snapshot(); st.Success != 3 {
+        t.Fatalf("counted %d successes, want 3", st.Success)
+    }
+    offs := a.Offsets()
+    if _, ok := offs[filepath.Join(dir, "a.yaml")]; ok {
+        t.Error("a YAML file's record count was checkpointed as a byte offset")
+    }
+    if _, ok := offs[filepath.Join(dir, "b.jsonl")]; !ok {
+        t.Error("the JSONL file wasn't checkpointed")
+    }
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// NoLabel is the PerLabel key for entries GroupBy extracts nothing from.
+const NoLabel = "(none)"
+
+// GroupBy extracts a user-chosen grouping key from each raw metrics line,
+// for dimensions beyond region and instance.
+type GroupBy struct {
+    Spec string
+    path []string       // JSON object keys, outermost first
+    re   *regexp.Regexp // or: first capture group (whole match without one)
+}
+
+// ParseGroupBy parses a --group-by spec: "$.field" or "$.outer.inner" walks
+// the JSON object, so any field works, not just the known Entry ones;
+// anything else is a regexp matched against the raw line, e.g.
+// `[?&]tenant=([^&"]+)`.
+func ParseGroupBy(spec string) (*GroupBy, error) {
+    if strings.HasPrefix(spec, "$.") {
+        path := strings.Split(spec[2:], ".")
+        for _, k := range path {
+            if k == "" {
+                return nil, fmt.Errorf("empty field name in %q", spec)
+            }
+        }
+        return &GroupBy{Spec: spec, path: path}, nil
+    }
+    if spec == "" {
+        return nil, errors.New("empty spec")
+    }
+    re, err := regexp.Compile(spec)
+    if err != nil {
+        return nil, err
+    }
+    return &GroupBy{Spec: spec, re: re}, nil
+}
+
+// Key returns line's grouping key, NoLabel if it has none.
+func (g *GroupBy) Key(line []byte) string {
+    if g.re != nil {
+        m := g.re.FindSubmatch(line)
+        switch {
+        case m == nil:
+            return NoLabel
+        case len(m) > 1:
+            if len(m[1]) == 0 { return NoLabel }
+            return string(m[1])
+        }
+        return string(m[0])
+    }
+    v := json.RawMessage(line)
+    for _, k := range g.path {
+        var obj map[string]json.RawMessage
+        if json.Unmarshal(v, &obj) != nil {
+            return NoLabel
+        }
+        if v = obj[k]; v == nil {
+            return NoLabel
+        }
+    }
+    var s string
+    if json.Unmarshal(v, &s) == nil {
+        if s == "" { return NoLabel }
+        return s
+    }
+    if v = bytes.TrimSpace(v); len(v) == 0 || string(v) == "null" || v[0] == '{' || v[0] == '[' {
+        return NoLabel
+    }
+    return string(v) // number or bool, as written
+}
+
+// labelKey applies the MaxLabels cap; call with mu held.
+func (a *Aggregator) labelKey(k string) string {
+    if a.MaxLabels > 0 && len(a.PerLabel) >= a.MaxLabels {
+        if _, ok := a.PerLabel[k]; !ok {
+            return OtherKey
+        }
+    }
+    return k
+}
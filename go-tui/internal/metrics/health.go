@@ -0,0 +1,165 @@
+package metrics
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// HealthWeights configures HealthScore. Each component scores 0..1:
+//
+//   rate    = success / total
+//   streak  = 1 - failStreak/StreakLimit (floored at 0)
+//   stale   = 1 if an entry arrived within StaleAfter, else 0
+//   latency = 1 while p90 <= LatencyTargetMS, falling linearly to 0 at 4x
+//
+// and the score is round(100 * sum(weight*component) / sum(weights)).
+type HealthWeights struct {
+    Rate            float64
+    Streak          float64
+    Stale           float64
+    Latency         float64
+    StreakLimit     int
+    StaleAfter      time.Duration
+    LatencyTargetMS int
+}
+
+func DefaultHealthWeights() HealthWeights {
+    return HealthWeights{
+        Rate:            0.5,
+        Streak:          0.2,
+        Stale:           0.2,
+        Latency:         0.1,
+        StreakLimit:     10,
+        StaleAfter:      30 * time.Second,
+        LatencyTargetMS: 2000,
+    }
+}
+
+// ParseHealthWeights overrides defaults from "rate=0.6,streak=0.2,...".
+// Keys: rate, streak, stale, latency (weights); streak_limit, latency_ms.
+func ParseHealthWeights(s string, w HealthWeights) (HealthWeights, error) {
+    for _, kv := range strings.Split(s, ",") {
+        kv = strings.TrimSpace(kv)
+        if kv == "" {
+            continue
+        }
+        k, v, ok := strings.Cut(kv, "=")
+        if !ok {
+            return w, fmt.Errorf("health weights: %q is not key=value", kv)
+        }
+        f, err := strconv.ParseFloat(v, 64)
+        if err != nil || f < 0 {
+            return w, fmt.Errorf("health weights: bad value for %s: %q", k, v)
+        }
+        switch k {
+        case "rate":
+            w.Rate = f
+        case "streak":
+            w.Streak = f
+        case "stale":
+            w.Stale = f
+        case "latency":
+            w.Latency = f
+        case "streak_limit":
+            w.StreakLimit = int(f)
+        case "latency_ms":
+            w.LatencyTargetMS = int(f)
+        default:
+            return w, fmt.Errorf("health weights: unknown key %q", k)
+        }
+    }
+    if w.Rate+w.Streak+w.Stale+w.Latency == 0 {
+        return w, fmt.Errorf("health weights: all weights are zero")
+    }
+    return w, nil
+}
+
+// HealthInputs are the measurements a health score is computed from.
+type HealthInputs struct {
+    Success, Fail int
+    FailStreak    int
+    SinceIngest   time.Duration // time since the last entry arrived
+    LatencyP90MS  int           // 0 if unknown
+}
+
+// Health computes the score for in; -1 means no data yet.
+func Health(in HealthInputs, w HealthWeights) int {
+    total := in.Success + in.Fail
+    if total == 0 {
+        return -1
+    }
+    rate := float64(in.Success) / float64(total)
+    streak := 1.0
+    if w.StreakLimit > 0 {
+        streak = 1 - float64(in.FailStreak)/float64(w.StreakLimit)
+        if streak < 0 { streak = 0 }
+    }
+    stale := 1.0
+    if w.StaleAfter > 0 && in.SinceIngest > w.StaleAfter {
+        stale = 0
+    }
+    lat := 1.0
+    if t := w.LatencyTargetMS; t > 0 && in.LatencyP90MS > t {
+        lat = 1 - float64(in.LatencyP90MS-t)/float64(3*t)
+        if lat < 0 { lat = 0 }
+    }
+    sum := w.Rate + w.Streak + w.Stale + w.Latency
+    if sum == 0 {
+        return -1
+    }
+    score := (w.Rate*rate + w.Streak*streak + w.Stale*stale + w.Latency*lat) / sum
+    return int(score*100 + 0.5)
+}
+
+// HealthScore is Health over the aggregator's current state with its
+// HealthWeights; -1 means no entries yet.
+func (a *Aggregator) HealthScore() int {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    return a.healthLocked(time.Now())
+}
+
+func (a *Aggregator) healthLocked(now time.Time) int {
+    return Health(HealthInputs{
+        Success:      a.Success,
+        Fail:         a.Fail,
+        FailStreak:   a.FailStreak,
+        SinceIngest:  now.Sub(a.LastIngest),
+        LatencyP90MS: a.latencyPercentileLocked(90),
+    }, a.HealthWeights)
+}
+
+// latencySamples is how many recent elapsed_ms values are kept for
+// percentiles.
+const latencySamples = 1024
+
+// LatencyPercentile returns the p-th percentile (0-100) of recent
+// elapsed_ms values, or 0 with no samples.
+func (a *Aggregator) LatencyPercentile(p float64) int {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    return a.latencyPercentileLocked(p)
+}
+
+func (a *Aggregator) latencyPercentileLocked(p float64) int {
+    if len(a.latency) == 0 {
+        return 0
+    }
+    s := append([]int(nil), a.latency...)
+    sort.Ints(s)
+    i := int(p / 100 * float64(len(s)-1))
+    return s[i]
+}
+
+func (a *Aggregator) recordLatency(ms int) {
+    a.latHist.observe(ms)
+    if len(a.latency) < latencySamples {
+        a.latency = append(a.latency, ms)
+        return
+    }
+    a.latency[a.latencyNext] = ms
+    a.latencyNext = (a.latencyNext + 1) % latencySamples
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+    "testing"
+    "time"
+)
+
+func TestHealth(t *testing.T) {
+    w := DefaultHealthWeights() // rate .5, streak .2, stale .2, latency .1
+    tests := []struct {
+        name string
+        in   HealthInputs
+        want int
+    }{
+        {"no data", HealthInputs{}, -1},
+        {"all good", HealthInputs{Success: 10}, 100},
+        {"half failing", HealthInputs{Success: 5, Fail: 5}, 75},
+        // rate .8, streak 1-5/10: (.5*.8 + .2*.5 + .2 + .1) / 1
+        {"on a streak", HealthInputs{Success: 8, Fail: 2, FailStreak: 5}, 80},
+        {"streak past the limit", HealthInputs{Success: 8, Fail: 2, FailStreak: 20}, 70},
+        {"stale", HealthInputs{Success: 10, SinceIngest: time.Minute}, 80},
+        {"latency at target", HealthInputs{Success: 10, LatencyP90MS: 2000}, 100},
+        // 1 - (5000-2000)/6000 = .5
+        {"latency 2.5x target", HealthInputs{Success: 10, LatencyP90MS: 5000}, 95},
+        {"latency past 4x", HealthInputs{Success: 10, LatencyP90MS: 9000}, 90},
+        {"everything bad", HealthInputs{Fail: 10, FailStreak: 10, SinceIngest: time.Hour, LatencyP90MS: 8000}, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Health(tt.in, w); got != tt.want {
+                t.Errorf("Health(%+v) = %d, want %d", tt.in, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestHealthWeights(t *testing.T) {
+    w, err := ParseHealthWeights("rate=1,streak=0,stale=0,latency=0", DefaultHealthWeights())
+    if err != nil {
+        t.Fatal(err)
+    }
+    // only the success rate counts
+    if got := Health(HealthInputs{Success: 3, Fail: 1, FailStreak: 10, SinceIngest: time.Hour}, w); got != 75 {
+        t.Errorf("rate-only score = %d, want 75", got)
+    }
+    for _, bad := range []string{"rate", "rate=-1", "speed=1", "rate=0,streak=0,stale=0,latency=0"} {
+        if _, err := ParseHealthWeights(bad, DefaultHealthWeights()); err == nil {
+            t.Errorf("ParseHealthWeights(%q) accepted it", bad)
+        }
+    }
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import "sort"
+
+// LatencyBoundsMS are the upper bounds of the latency histogram buckets;
+// elapsed_ms above the last lands in the overflow bucket.
+var LatencyBoundsMS = []int{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// LatencyHistogram counts every elapsed_ms seen since start (or Reset), unlike
+// the recent-samples ring behind LatencyPercentile.
+type LatencyHistogram struct {
+    Counts []int // per bucket, not cumulative; len(LatencyBoundsMS)+1
+    SumMS  int64
+    Count  int
+}
+
+func (h *LatencyHistogram) observe(ms int) {
+    if h.Counts == nil {
+        h.Counts = make([]int, len(LatencyBoundsMS)+1)
+    }
+    h.Counts[sort.SearchInts(LatencyBoundsMS, ms)]++
+    h.SumMS += int64(ms)
+    h.Count++
+}
+
+// Latency returns a copy of the latency histogram.
+func (a *Aggregator) Latency() LatencyHistogram {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    h := a.latHist
+    h.Counts = make([]int, len(LatencyBoundsMS)+1)
+    copy(h.Counts, a.latHist.Counts)
+    return h
+}
@@ -5,154 +5,906 @@ import (
     "encoding/json"
     "io"
     "os"
-    "path/filepath"
+    "regexp"
     "sort"
+    "strconv"
+    "sync"
     "time"
+
+    "secmon/internal/tail"
 )
 
+// Timestamp holds an entry's "ts" as text. Producers write either a date
+// string or a bare Unix epoch number; both decode into the same form.
+type Timestamp string
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+    if len(b) > 0 && b[0] != '"' {
+        if string(b) == "null" {
+            *t = ""
+            return nil
+        }
+        var n json.Number
+        if err := json.Unmarshal(b, &n); err != nil {
+            return err
+        }
+        *t = Timestamp(n)
+        return nil
+    }
+    var s string
+    if err := json.Unmarshal(b, &s); err != nil {
+        return err
+    }
+    *t = Timestamp(s)
+    return nil
+}
+
 type Entry struct {
-    TS               string `json:"ts"`
-    InstanceID       string `json:"instance_id"`
-    Attempt          int    `json:"attempt"`
-    Success          bool   `json:"success"`
-    Reason           string `json:"reason"`
-    ElapsedMS        int    `json:"elapsed_ms"`
-    Proxy            bool   `json:"proxy"`
-    RotatedOnFailure bool   `json:"rotated_on_failure"`
-    URL              string `json:"url"`
-    BatchRegion      string `json:"batch_region"`
+    TS               Timestamp `json:"ts"`
+    InstanceID       string    `json:"instance_id"`
+    Attempt          int       `json:"attempt"`
+    Success          bool      `json:"success"`
+    Reason           string    `json:"reason"`
+    ElapsedMS        int       `json:"elapsed_ms"`
+    Proxy            bool      `json:"proxy"`
+    RotatedOnFailure bool      `json:"rotated_on_failure"`
+    URL              string    `json:"url"`
+    BatchRegion      string    `json:"batch_region"`
+    TotalTargets     int       `json:"total_targets"` // batch size announced by the producer (optional)
+
+    // Label is the GroupBy key, filled in by whoever decoded the line.
+    Label string `json:"-"`
+}
+
+// Time parses the entry's ts, falling back to now like ingest does.
+func (e Entry) Time() time.Time {
+    return parseTime(e.TS)
+}
+
+// TimeOK parses the entry's ts, reporting false (and now) if it can't.
+func (e Entry) TimeOK() (time.Time, bool) {
+    return parseTimeOK(e.TS)
 }
 
+// Aggregator tallies metrics entries read from files matching Pattern.
+//
+// Concurrency: all methods are safe for concurrent use. Update may run on one
+// goroutine while renderers call Snapshot on another; concurrent Update calls
+// are serialized. The exported count fields are guarded by the internal lock,
+// so code running alongside Update must read them through Snapshot.
 type Aggregator struct {
+    mu           sync.RWMutex
+    updateMu     sync.Mutex // serializes Update; guards pos, info, shrunk, pipes, formats, parsed
     Pattern      string
     pos          map[string]int64
+    info         map[string]os.FileInfo
+    shrunk       map[string]int // see tail.Reader.ShrinkPolls
+    pipes        map[string]*tail.Pipe // named pipes, streamed instead
+    formats      map[string]fileFormat  // per file, sniffed on first read
+    stats        tail.StatCache
+    read         []string // files the last Update read
+    parsed       map[string]os.FileInfo // JSON array / YAML files as last parsed whole
+    ShrinkPolls  int
+    Source       tail.Source // where files are globbed and read; tail.OS by default
     Success      int
     Fail         int
     PerRegion    map[string][2]int // [success, fail]
     PerInstance  map[string][2]int
+    PerReason    map[string]int // failure reasons
+    LastIngest   time.Time      // wall time the last entry was ingested
+    Malformed    int            // non-empty lines that failed to parse
+    FailStreak   int            // consecutive failures up to the latest entry
     BucketSecs   int
     MaxBuckets   int
     // timeline buckets: slice of (bucketStartEpoch, succ, fail)
     Timeline     [][3]int
     bucketIndex  map[int]int // map bucketStartEpoch -> index in Timeline
+
+    // MaxFiles, if set, limits Update to the MaxFiles most recently modified
+    // matches (offsets of the rest are dropped); IgnoredFiles is how many
+    // the last Update skipped.
+    MaxFiles     int
+    IgnoredFiles int
+
+    // Group, if set, reads only the newest file per key (see tail.Latest).
+    Group func(path string) string
+
+    // Record, if set, is handed every decoded entry (before dedup and
+    // normalization), outside the count lock.
+    Record func(e Entry)
+
+    // RetainDuration, if set, replaces MaxBuckets with however many buckets
+    // of the current size cover it, so the timeline spans the same wall-clock
+    // window whatever the bucket size.
+    RetainDuration time.Duration
+
+    // PreFill has EnsureBucketsTo, once entries have been counted, fill
+    // every gap between their buckets with empty ones (within the retained
+    // window), so sparse history read at startup shows its quiet stretches
+    // instead of a timeline collapsed onto the buckets that had entries.
+    // Later gaps are left to late entries as before.
+    PreFill   bool
+    preFilled bool
+
+    // TrackTargets enables per-(instance, url) outcome collapsing into
+    // Targets. Off by default since it keeps one map entry per target.
+    TrackTargets bool
+    Targets      TargetCounts
+    targets      map[string]targetState
+
+    store *eventRing // recent entries; nil unless RetainEvents was called
+
+    slow    slowHeap // slowest entries; nil unless TrackSlowest was called
+    slowMax int
+
+    trends   map[string]map[int]int // reason -> bucket -> failures; nil unless TrackReasonTrends was called
+    trendMax int
+
+    bucketRegions   map[int]map[string][2]int // bucket -> region -> [success, fail]; nil unless TrackBucketRegions
+    bucketInstances map[int]map[string][2]int // likewise per instance; nil unless TrackBucketInstances
+
+    HealthWeights HealthWeights
+    latency       []int // recent elapsed_ms, ring of latencySamples
+    latencyNext   int
+    latHist       LatencyHistogram
+    regionLatency map[string]*latencyRing // see RegionLatency
+
+    bucketsAdded int // ever; for UpdateStats
+    badTS        int // entries whose ts fell back to now; for UpdateStats
+
+    // MaxSkew is how far past the wall clock an entry's ts may be and still
+    // be bucketed. Entries before bucketFloor or beyond it count toward the
+    // totals but are tallied in Unbucketed instead of the timeline.
+    MaxSkew    time.Duration
+    Unbucketed int
+
+    // LateDropped counts entries that arrived after the timeline had moved
+    // past their bucket: older than the oldest retained bucket with the
+    // timeline full. They count toward the totals but not the timeline, so
+    // a late straggler can't displace (or inflate) the oldest bucket.
+    LateDropped int
+
+    // UnknownLabel is the region/instance an entry without one is counted
+    // under (DefaultUnknown if empty); MissingRegion and MissingInstance
+    // count how many entries needed it, so a producer that stopped sending
+    // the field doesn't hide behind a real region of the same name.
+    UnknownLabel    string
+    MissingRegion   int
+    MissingInstance int
+
+    // InstanceNormalize, if set, replaces an instance ID with its first
+    // capture group (e.g. dropping a per-run suffix). MaxInstances caps
+    // PerInstance; IDs beyond it are counted under OtherKey.
+    InstanceNormalize *regexp.Regexp
+    MaxInstances      int
+
+    // MaxRegions and MaxReasons cap PerRegion and PerReason the same way;
+    // MaxTargets caps the per-target map behind Targets, past which new
+    // targets are only counted in Targets.Untracked. 0 means unbounded.
+    MaxRegions int
+    MaxReasons int
+    MaxTargets int
+
+    dedup             *seenSet // nil unless Dedup was called
+    DuplicatesSkipped int      // entries dropped by Dedup
+
+    // Validate checks every line against the Entry schema and counts
+    // per-field violations in Violations (keyed by JSON field name, LineKey
+    // for non-objects). Off by default: it decodes each line twice.
+    Validate     bool
+    Violations   map[string]FieldIssues
+    InvalidLines int
+
+    // StrictJSON rejects entries carrying a field Entry doesn't have,
+    // counting them in UnknownFields instead of ingesting them; OnUnknown,
+    // if set, is told the file and the first such field of each.
+    StrictJSON    bool
+    UnknownFields int
+    OnUnknown     func(path, field string)
+
+    // InstanceName, if set, derives an instance ID from a file path for
+    // entries that don't carry instance_id.
+    InstanceName func(path string) string
+
+    // GroupBy, if set, tallies entries by a custom key in PerLabel, capped
+    // at MaxLabels keys like MaxInstances.
+    GroupBy   *GroupBy
+    PerLabel  map[string][2]int
+    MaxLabels int
+
+    instanceSeen map[string]time.Time // newest entry ts per instance, for Fleet
+    regionSeen   map[string]time.Time
+
+    // DetectRestarts watches each instance's attempt counter for a drop
+    // back to 1, counting it in Restarts (as does CountRestart) and queuing
+    // it for TakeRestarts.
+    DetectRestarts  bool
+    Restarts        map[string]int // per instance
+    lastAttempt     map[string]int
+    pendingRestarts []Restart
+
+    proxy      ProxyStats
+    lastFailed map[string]bool // instances whose latest entry failed, for ProxyStats
+
+    // SuccessReasons tallies the reason successful entries carry (e.g.
+    // cache_hit) in PerSuccessReason, apart from the failure reasons and
+    // capped at MaxReasons the same way.
+    SuccessReasons   bool
+    PerSuccessReason map[string]int
+
+    // SoftFailReasons (lowercased, see ReasonSet) marks successes that
+    // still count against the producer, e.g. a "429" that got through on
+    // retry. Matching successes are tallied in SoftFail as well: Success
+    // and Fail are unchanged.
+    SoftFailReasons map[string]bool
+    SoftFail        int
 }
 
 func NewAggregator(pattern string, bucketSecs, maxBuckets int) *Aggregator {
     return &Aggregator{
-        Pattern:     pattern,
-        pos:         make(map[string]int64),
-        PerRegion:   make(map[string][2]int),
-        PerInstance: make(map[string][2]int),
-        BucketSecs:  bucketSecs,
-        MaxBuckets:  maxBuckets,
-        Timeline:    make([][3]int, 0, maxBuckets),
-        bucketIndex: make(map[int]int),
+        Pattern:       pattern,
+        pos:           make(map[string]int64),
+        info:          make(map[string]os.FileInfo),
+        shrunk:        make(map[string]int),
+        pipes:         make(map[string]*tail.Pipe),
+        formats:       make(map[string]fileFormat),
+        parsed:        make(map[string]os.FileInfo),
+        ShrinkPolls:   2,
+        Source:        tail.OS,
+        PerRegion:     make(map[string][2]int),
+        PerInstance:   make(map[string][2]int),
+        PerReason:     make(map[string]int),
+        PerLabel:      make(map[string][2]int),
+        BucketSecs:    bucketSecs,
+        MaxBuckets:    maxBuckets,
+        Timeline:      make([][3]int, 0, maxBuckets),
+        bucketIndex:   make(map[int]int),
+        targets:       make(map[string]targetState),
+        Violations:    make(map[string]FieldIssues),
+        HealthWeights: DefaultHealthWeights(),
+        instanceSeen:  make(map[string]time.Time),
+        regionSeen:    make(map[string]time.Time),
+        regionLatency: make(map[string]*latencyRing),
+        lastAttempt:   make(map[string]int),
+        lastFailed:    make(map[string]bool),
+        MaxSkew:       DefaultMaxSkew,
+    }
+}
+
+// Snapshot is a consistent copy of the aggregator's counts.
+type Snapshot struct {
+    Success     int
+    Fail        int
+    PerRegion   map[string][2]int
+    PerInstance map[string][2]int
+    PerReason   map[string]int
+    LastIngest  time.Time
+    Malformed   int
+    FailStreak  int
+    Health      int // HealthScore at snapshot time; -1 with no data
+    Targets     TargetCounts // zero unless TrackTargets
+    BucketSecs  int
+    Timeline    [][3]int
+
+    DuplicatesSkipped int
+    Violations        map[string]FieldIssues // empty unless Validate
+    InvalidLines      int
+    IgnoredFiles      int // matches past MaxFiles in the last Update
+    Unbucketed        int // counted entries whose ts was too old or too far ahead to bucket
+    UnknownFields     int // entries rejected by StrictJSON
+    LateDropped       int // counted entries older than the retained timeline
+    MissingRegion     int // entries without batch_region, counted under UnknownLabel
+    MissingInstance   int // likewise instance_id
+
+    PerLabel map[string][2]int // empty unless GroupBy
+    Restarts map[string]int    // producer restarts per instance
+    Proxy    ProxyStats
+
+    PerSuccessReason map[string]int // empty unless SuccessReasons
+    SoftFail         int            // successes matching SoftFailReasons
+
+    InstanceSeen map[string]time.Time // newest entry ts per instance (see Fleet)
+    RegionSeen   map[string]time.Time
+}
+
+func (a *Aggregator) Snapshot() Snapshot {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    s := Snapshot{
+        Success:     a.Success,
+        Fail:        a.Fail,
+        PerRegion:   make(map[string][2]int, len(a.PerRegion)),
+        PerInstance: make(map[string][2]int, len(a.PerInstance)),
+        PerReason:   make(map[string]int, len(a.PerReason)),
+        LastIngest:  a.LastIngest,
+        Malformed:   a.Malformed,
+        FailStreak:  a.FailStreak,
+        Health:      a.healthLocked(time.Now()),
+        Targets:     a.Targets,
+        BucketSecs:  a.BucketSecs,
+        Timeline:    append([][3]int(nil), a.Timeline...),
+
+        DuplicatesSkipped: a.DuplicatesSkipped,
+        Violations:        make(map[string]FieldIssues, len(a.Violations)),
+        InvalidLines:      a.InvalidLines,
+        IgnoredFiles:      a.IgnoredFiles,
+        Unbucketed:        a.Unbucketed,
+        UnknownFields:     a.UnknownFields,
+        LateDropped:       a.LateDropped,
+        MissingRegion:     a.MissingRegion,
+        MissingInstance:   a.MissingInstance,
+
+        PerLabel: make(map[string][2]int, len(a.PerLabel)),
+        Restarts: make(map[string]int, len(a.Restarts)),
+        Proxy:    a.proxy,
+
+        PerSuccessReason: make(map[string]int, len(a.PerSuccessReason)),
+        SoftFail:         a.SoftFail,
+
+        InstanceSeen: make(map[string]time.Time, len(a.instanceSeen)),
+        RegionSeen:   make(map[string]time.Time, len(a.regionSeen)),
     }
+    for k, v := range a.Violations { s.Violations[k] = v }
+    for k, v := range a.PerRegion { s.PerRegion[k] = v }
+    for k, v := range a.PerInstance { s.PerInstance[k] = v }
+    for k, v := range a.PerReason { s.PerReason[k] = v }
+    for k, v := range a.PerSuccessReason { s.PerSuccessReason[k] = v }
+    for k, v := range a.PerLabel { s.PerLabel[k] = v }
+    for k, v := range a.Restarts { s.Restarts[k] = v }
+    for k, v := range a.instanceSeen { s.InstanceSeen[k] = v }
+    for k, v := range a.regionSeen { s.RegionSeen[k] = v }
+    return s
+}
+
+// Bucket is one timeline bucket.
+type Bucket struct {
+    Start   time.Time
+    Success int
+    Fail    int
+}
+
+func (b Bucket) Total() int { return b.Success + b.Fail }
+
+// Buckets returns the timeline, oldest first.
+func (a *Aggregator) Buckets() []Bucket {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    return buckets(a.Timeline)
 }
 
-func (a *Aggregator) bucketStart(ts time.Time) int {
+// Buckets returns the snapshot's timeline, oldest first.
+func (s Snapshot) Buckets() []Bucket {
+    return buckets(s.Timeline)
+}
+
+func buckets(tl [][3]int) []Bucket {
+    out := make([]Bucket, len(tl))
+    for i, p := range tl {
+        out[i] = Bucket{Start: time.Unix(int64(p[0]), 0), Success: p[1], Fail: p[2]}
+    }
+    return out
+}
+
+// DefaultMaxSkew is the MaxSkew a new Aggregator starts with.
+const DefaultMaxSkew = 10 * time.Minute
+
+// bucketFloor is the earliest ts bucketed; anything older is a zero or
+// garbage time rather than a real event.
+var bucketFloor = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// bucketStart returns the start of the bucket holding ts, reporting false
+// when ts is before bucketFloor or more than MaxSkew past the wall clock.
+func (a *Aggregator) bucketStart(ts time.Time) (int, bool) {
+    if ts.Before(bucketFloor) || (a.MaxSkew > 0 && ts.After(time.Now().Add(a.MaxSkew))) {
+        return 0, false
+    }
     sec := ts.Unix()
     b := int(sec - (sec % int64(a.BucketSecs)))
-    return b
+    return b, true
 }
 
-func (a *Aggregator) ensureBucket(b int) {
+// ensureBucket adds bucket b unless it's already there (or before
+// bucketFloor, which would make EnsureBucketsTo fill in decades), keeping
+// the timeline sorted so a late entry's bucket goes where it belongs. It
+// reports false when b isn't on the timeline: before bucketFloor, or older
+// than the oldest bucket of a full timeline.
+func (a *Aggregator) ensureBucket(b int) bool {
+    if int64(b) < bucketFloor.Unix() {
+        return false
+    }
     if _, ok := a.bucketIndex[b]; ok {
-        return
+        return true
     }
-    a.Timeline = append(a.Timeline, [3]int{b, 0, 0})
-    a.bucketIndex[b] = len(a.Timeline) - 1
-    if len(a.Timeline) > a.MaxBuckets {
+    n := len(a.Timeline)
+    if n > 0 && b < a.Timeline[n-1][0] {
+        if b < a.Timeline[0][0] && n >= a.maxBuckets() {
+            return false // would be trimmed straight away
+        }
+        i := sort.Search(n, func(i int) bool { return a.Timeline[i][0] > b })
+        a.Timeline = append(a.Timeline, [3]int{})
+        copy(a.Timeline[i+1:], a.Timeline[i:])
+        a.Timeline[i] = [3]int{b, 0, 0}
+        for j := i; j < len(a.Timeline); j++ {
+            a.bucketIndex[a.Timeline[j][0]] = j
+        }
+    } else {
+        a.Timeline = append(a.Timeline, [3]int{b, 0, 0})
+        a.bucketIndex[b] = len(a.Timeline) - 1
+    }
+    a.bucketsAdded++
+    a.trimTimeline()
+    _, ok := a.bucketIndex[b]
+    return ok
+}
+
+// trimTimeline drops the oldest buckets past maxBuckets.
+func (a *Aggregator) trimTimeline() {
+    if n := len(a.Timeline) - a.maxBuckets(); n > 0 {
         // drop oldest
-        oldest := a.Timeline[0][0]
-        a.Timeline = a.Timeline[1:]
+        a.Timeline = a.Timeline[n:]
         // rebuild index
         a.bucketIndex = make(map[int]int, len(a.Timeline))
         for i, it := range a.Timeline {
             a.bucketIndex[it[0]] = i
         }
-        // remove oldest key
-        delete(a.bucketIndex, oldest)
+        a.pruneBuckets()
     }
 }
 
-func (a *Aggregator) EnsureBucketsTo(now time.Time) {
+// fillGaps adds an empty bucket wherever one is missing between the oldest
+// bucket (or the start of the retained window before target, if later) and
+// target, then trims to maxBuckets.
+func (a *Aggregator) fillGaps(target int) {
+    step := a.BucketSecs
+    first := max(a.Timeline[0][0], target-(a.maxBuckets()-1)*step)
+    filled := make([][3]int, 0, len(a.Timeline))
+    i := 0
+    for ; i < len(a.Timeline) && a.Timeline[i][0] < first; i++ {
+        filled = append(filled, a.Timeline[i])
+    }
+    for b := first; b <= target; b += step {
+        if i < len(a.Timeline) && a.Timeline[i][0] == b {
+            filled = append(filled, a.Timeline[i])
+            i++
+        } else {
+            filled = append(filled, [3]int{b, 0, 0})
+            a.bucketsAdded++
+        }
+    }
+    a.Timeline = append(filled, a.Timeline[i:]...)
+    a.bucketIndex = make(map[int]int, len(a.Timeline))
+    for j, it := range a.Timeline {
+        a.bucketIndex[it[0]] = j
+    }
+    a.trimTimeline()
+}
+
+// maxBuckets is the timeline length bound: RetainDuration in buckets of the
+// current size (rounded up) when set, else MaxBuckets.
+func (a *Aggregator) maxBuckets() int {
+    if a.RetainDuration <= 0 {
+        return a.MaxBuckets
+    }
+    step := time.Duration(a.BucketSecs) * time.Second
+    n := int((a.RetainDuration + step - 1) / step)
+    if n < 1 {
+        n = 1
+    }
+    return n
+}
+
+// EffectiveMaxBuckets reports the current timeline length bound.
+func (a *Aggregator) EffectiveMaxBuckets() int {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    return a.maxBuckets()
+}
+
+// EnsureBucketsTo adds empty buckets up to the one holding now, so quiet
+// periods show on the timeline, and returns how many it added. With
+// PreFill, the first call after entries were counted also fills the gaps
+// between their buckets.
+func (a *Aggregator) EnsureBucketsTo(now time.Time) int {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    before := a.bucketsAdded
+    target, ok := a.bucketStart(now)
+    if !ok {
+        return 0
+    }
     if len(a.Timeline) == 0 {
-        a.ensureBucket(a.bucketStart(now))
-        return
+        a.ensureBucket(target)
+        return a.bucketsAdded - before
     }
     last := a.Timeline[len(a.Timeline)-1][0]
-    target := a.bucketStart(now)
+    // after a long gap only the buckets still retained are worth adding
+    if first := target - (a.maxBuckets()-1)*a.BucketSecs; last < first {
+        last = first - a.BucketSecs
+    }
     for b := last + a.BucketSecs; b <= target; b += a.BucketSecs {
         a.ensureBucket(b)
     }
+    if a.PreFill && !a.preFilled && a.Success+a.Fail > 0 {
+        a.fillGaps(target)
+        a.preFilled = true
+    }
+    return a.bucketsAdded - before
 }
 
-func parseTime(ts string) time.Time {
-    // Expect: 2006-01-02T15:04:05 (UTC)
+func parseTime(ts Timestamp) time.Time {
+    t, _ := parseTimeOK(ts)
+    return t
+}
+
+// parseTimeOK is parseTime, reporting false when it fell back to now.
+func parseTimeOK(ts Timestamp) (time.Time, bool) {
+    // Expect: 2006-01-02T15:04:05 (UTC), or a Unix epoch number
     // Fallback: now
-    if t, err := time.Parse("2006-01-02T15:04:05", ts); err == nil {
-        return t
+    if t, err := time.Parse("2006-01-02T15:04:05", string(ts)); err == nil {
+        return t, true
+    }
+    if t, ok := parseEpoch(string(ts)); ok {
+        return t, true
+    }
+    return time.Now(), false
+}
+
+// parseEpoch reads a Unix timestamp, picking the unit by magnitude: seconds,
+// milliseconds, microseconds or nanoseconds (values from 2001 onward).
+func parseEpoch(s string) (time.Time, bool) {
+    f, err := strconv.ParseFloat(s, 64)
+    if err != nil || f <= 0 {
+        return time.Time{}, false
+    }
+    switch {
+    case f >= 1e17:
+        if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+            return time.Unix(0, n).UTC(), true
+        }
+        return time.Unix(0, int64(f)).UTC(), true
+    case f >= 1e14:
+        return time.UnixMicro(int64(f)).UTC(), true
+    case f >= 1e11:
+        return time.UnixMilli(int64(f)).UTC(), true
+    default:
+        sec := int64(f)
+        return time.Unix(sec, int64((f-float64(sec))*1e9)).UTC(), true
     }
-    return time.Now()
 }
 
-func (a *Aggregator) Update() {
-    matches, _ := filepath.Glob(a.Pattern)
-    sort.Strings(matches)
+// Update reads lines appended to matching files since the last call and
+// ingests them, returning what it did. File reads happen outside the count
+// lock; parsed entries are applied under it in one batch per file.
+func (a *Aggregator) Update() UpdateStats {
+    a.updateMu.Lock()
+    defer a.updateMu.Unlock()
+    matches, _ := a.Source.Glob(a.Pattern)
+    a.stats.Begin(a.Source, time.Now(), a.read)
+    a.stats.Prune(matches)
+    all := matches
+    if a.Group != nil {
+        matches, _ = tail.Latest(&a.stats, matches, a.Group)
+    }
+    matches, ignored := tail.Newest(&a.stats, matches, a.MaxFiles)
+    a.forget(all, matches)
+    a.read = matches
+    a.mu.Lock()
+    a.IgnoredFiles = ignored
+    before := a.counters()
+    a.mu.Unlock()
+    us := UpdateStats{Files: len(matches)}
     for _, path := range matches {
-        fi, err := os.Stat(path)
+        fi, err := a.stats.Fresh(path)
         if err != nil {
             delete(a.pos, path)
+            delete(a.info, path)
+            delete(a.shrunk, path)
+            a.forgetFormat(path)
+            a.closePipe(path)
             continue
         }
-        size := fi.Size()
-        cur := a.pos[path]
-        if size < cur {
-            cur = 0
+        var lines [][]byte
+        malformed, invalid, unknown := 0, 0, 0
+        if tail.IsPipe(fi) {
+            lines = a.readPipe(path)
+        } else if format := a.format(path, fi); format == formatPending {
+            // not enough written to tell its format: next poll
+        } else if format != formatJSONL {
+            var read int64
+            lines, malformed, read = a.readDocs(path, fi, format)
+            us.Bytes += read
+        } else {
+            lines = a.readFile(path, fi)
         }
-        if size == cur {
-            a.pos[path] = size
+        var batch []Entry
+        var issues map[string]FieldIssues
+        if a.Validate {
+            issues = make(map[string]FieldIssues)
+        }
+        for _, line := range lines {
+            if a.formats[path] == formatJSONL {
+                us.Bytes += int64(len(line))
+            }
+            us.Lines++
+            if line = trimNewlineBytes(line); len(line) > 0 {
+                if issues != nil && !validateLine(line, issues) {
+                    invalid++
+                }
+                var e Entry
+                if err := json.Unmarshal(line, &e); err == nil {
+                    if a.StrictJSON {
+                        if f := unknownField(line); f != "" {
+                            unknown++
+                            if a.OnUnknown != nil {
+                                a.OnUnknown(path, f)
+                            }
+                            continue
+                        }
+                    }
+                    if e.InstanceID == "" && a.InstanceName != nil {
+                        e.InstanceID = a.InstanceName(path)
+                    }
+                    if a.GroupBy != nil {
+                        e.Label = a.GroupBy.Key(line)
+                    }
+                    if a.Record != nil {
+                        a.Record(e)
+                    }
+                    batch = append(batch, e)
+                } else {
+                    malformed++
+                }
+            }
+        }
+        us.Malformed += malformed
+        us.Invalid += invalid
+        us.Unknown += unknown
+        if len(batch) == 0 && malformed == 0 && invalid == 0 && unknown == 0 {
             continue
         }
-        f, err := os.Open(path)
+        a.mu.Lock()
+        for _, e := range batch {
+            a.ingest(e)
+        }
+        if len(batch) > 0 {
+            a.LastIngest = time.Now()
+        }
+        a.Malformed += malformed
+        a.InvalidLines += invalid
+        a.UnknownFields += unknown
+        for k, v := range issues {
+            fi := a.Violations[k]
+            fi.Missing += v.Missing
+            fi.WrongType += v.WrongType
+            a.Violations[k] = fi
+        }
+        a.mu.Unlock()
+    }
+    a.mu.RLock()
+    us.since(before, a.counters())
+    a.mu.RUnlock()
+    return us
+}
+
+// readFile returns the complete lines (newline included) appended to path
+// since the last Update, handling rotation and truncation; call with
+// updateMu held.
+func (a *Aggregator) readFile(path string, fi os.FileInfo) [][]byte {
+    size := fi.Size()
+    cur := a.pos[path]
+    if tail.Rotated(a.info, a.shrunk, a.ShrinkPolls, path, fi, cur) {
+        cur = 0
+    } else if size < cur {
+        return nil
+    }
+    if size == cur {
+        a.pos[path] = size
+        return nil
+    }
+    f, err := a.Source.Open(path)
+    if err != nil {
+        return nil
+    }
+    defer f.Close()
+    if _, err := f.Seek(cur, io.SeekStart); err != nil {
+        return nil
+    }
+    // As in tail.ReadNew, pos only advances past complete lines; a trailing
+    // partial line is left for the next Update.
+    br := bufio.NewReader(f)
+    pos := cur
+    var lines [][]byte
+    for {
+        line, err := br.ReadBytes('\n')
         if err != nil {
-            continue
+            break
         }
-        if _, err := f.Seek(cur, io.SeekStart); err != nil {
-            f.Close()
-            continue
+        pos += int64(len(line))
+        lines = append(lines, line)
+    }
+    a.pos[path] = pos
+    return lines
+}
+
+// readPipe returns the complete lines a named pipe has ready (see
+// tail.Pipe); call with updateMu held.
+func (a *Aggregator) readPipe(path string) [][]byte {
+    p := a.pipes[path]
+    if p == nil {
+        p = &tail.Pipe{Path: path}
+        a.pipes[path] = p
+    }
+    lines, _ := p.ReadLines()
+    return lines
+}
+
+func (a *Aggregator) closePipe(path string) {
+    if p := a.pipes[path]; p != nil {
+        p.Close()
+        delete(a.pipes, path)
+    }
+}
+
+// Offsets returns the read position of every metrics file read so far, for
+// checkpoints.
+func (a *Aggregator) Offsets() map[string]tail.Offset {
+    a.updateMu.Lock()
+    defer a.updateMu.Unlock()
+    pos := make(map[string]int64, len(a.pos))
+    for p, n := range a.pos {
+        if a.formats[p] == formatJSONL {
+            pos[p] = n
         }
-        br := bufio.NewReader(f)
-        for {
-            line, err := br.ReadBytes('\n')
-            if len(line) > 0 {
-                var e Entry
-                if err := json.Unmarshal(trimNewlineBytes(line), &e); err == nil {
-                    a.ingest(e)
-                }
-            }
-            if err != nil {
-                if err == io.EOF {
-                    break
-                }
-                break
-            }
+    }
+    return tail.Offsets(a.Source, pos, a.info)
+}
+
+// Resume starts each file in saved from its checkpointed offset if it is
+// still the same file (see tail.Resume) and returns how many did. Call
+// before the first Update.
+func (a *Aggregator) Resume(saved map[string]tail.Offset) int {
+    a.updateMu.Lock()
+    defer a.updateMu.Unlock()
+    lines := make(map[string]tail.Offset, len(saved))
+    for p, o := range saved {
+        if fi, err := a.Source.Stat(p); err == nil && !tail.IsPipe(fi) && a.format(p, fi) == formatJSONL {
+            lines[p] = o
+        }
+    }
+    return tail.Resume(a.Source, lines, a.pos, a.info)
+}
+
+// forget drops the state of files the glob no longer matches. Files still
+// matched but outside keep (MaxFiles, Group) keep their offsets, so one
+// admitted again resumes instead of being re-read and counted twice (see
+// tail.Reader.forget); call with updateMu held.
+func (a *Aggregator) forget(all, keep []string) {
+    matched := make(map[string]bool, len(all))
+    for _, p := range all { matched[p] = true }
+    kept := make(map[string]bool, len(keep))
+    for _, p := range keep { kept[p] = true }
+    for p := range a.pos {
+        if !matched[p] {
+            delete(a.pos, p)
+            delete(a.info, p)
+            delete(a.shrunk, p)
+            a.forgetFormat(p)
+        }
+    }
+    for p := range a.pipes {
+        if !kept[p] {
+            a.closePipe(p)
         }
-        pos, _ := f.Seek(0, io.SeekCurrent)
-        a.pos[path] = pos
-        f.Close()
     }
 }
 
+// Ingest applies one entry from outside the file reader (e.g. replay).
+func (a *Aggregator) Ingest(e Entry) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.ingest(e)
+    a.LastIngest = time.Now()
+}
+
+// OtherKey collects entries whose key fell past a cardinality cap.
+const OtherKey = "(other)"
+
+// DefaultUnknown is the region/instance for entries without one, unless
+// UnknownLabel says otherwise.
+const DefaultUnknown = "unknown"
+
+func (a *Aggregator) unknownLabel() string {
+    if a.UnknownLabel == "" {
+        return DefaultUnknown
+    }
+    return a.UnknownLabel
+}
+
+// NormalizeInstance applies InstanceNormalize to id, for naming an
+// instance found outside the metrics (a log file) the way PerInstance does.
+// Unlike the tables it isn't capped by MaxInstances.
+func (a *Aggregator) NormalizeInstance(id string) string {
+    if re := a.InstanceNormalize; re != nil {
+        if m := re.FindStringSubmatch(id); len(m) > 1 && m[1] != "" {
+            id = m[1]
+        }
+    }
+    return id
+}
+
+func (a *Aggregator) instanceKey(id string) string {
+    id = a.NormalizeInstance(id)
+    if a.MaxInstances > 0 && len(a.PerInstance) >= a.MaxInstances {
+        if _, ok := a.PerInstance[id]; !ok {
+            return OtherKey
+        }
+    }
+    return id
+}
+
+// regionKey applies the MaxRegions cap; call with mu held.
+func (a *Aggregator) regionKey(r string) string {
+    if a.MaxRegions > 0 && len(a.PerRegion) >= a.MaxRegions {
+        if _, ok := a.PerRegion[r]; !ok {
+            return OtherKey
+        }
+    }
+    return r
+}
+
+// reasonKey applies the MaxReasons cap; call with mu held.
+func (a *Aggregator) reasonKey(r string) string {
+    if a.MaxReasons > 0 && len(a.PerReason) >= a.MaxReasons {
+        if _, ok := a.PerReason[r]; !ok {
+            return OtherKey
+        }
+    }
+    return r
+}
+
+// successReasonKey applies the MaxReasons cap to PerSuccessReason; call
+// with mu held.
+func (a *Aggregator) successReasonKey(r string) string {
+    if a.MaxReasons > 0 && len(a.PerSuccessReason) >= a.MaxReasons {
+        if _, ok := a.PerSuccessReason[r]; !ok {
+            return OtherKey
+        }
+    }
+    return r
+}
+
+// ingest applies one entry; callers must hold a.mu.
 func (a *Aggregator) ingest(e Entry) {
+    if a.dedup != nil && a.dedup.seen(e) {
+        a.DuplicatesSkipped++
+        return
+    }
     if e.Success {
         a.Success++
+        a.FailStreak = 0
+        if a.softFail(e) {
+            a.SoftFail++
+        }
     } else {
         a.Fail++
+        a.FailStreak++
+    }
+    if e.ElapsedMS > 0 {
+        a.recordLatency(e.ElapsedMS)
+    }
+    if e.BatchRegion == "" {
+        e.BatchRegion = a.unknownLabel()
+        a.MissingRegion++
+    }
+    if e.InstanceID == "" {
+        e.InstanceID = a.unknownLabel()
+        a.MissingInstance++
+    }
+    e.InstanceID = a.instanceKey(e.InstanceID)
+    e.BatchRegion = a.regionKey(e.BatchRegion)
+    if e.ElapsedMS > 0 {
+        a.recordRegionLatency(e.BatchRegion, e.ElapsedMS)
     }
-    if e.BatchRegion == "" { e.BatchRegion = "unknown" }
-    if e.InstanceID == "" { e.InstanceID = "unknown" }
     pr := a.PerRegion[e.BatchRegion]
     pi := a.PerInstance[e.InstanceID]
     if e.Success {
@@ -164,22 +916,151 @@ func (a *Aggregator) ingest(e Entry) {
     }
     a.PerRegion[e.BatchRegion] = pr
     a.PerInstance[e.InstanceID] = pi
+    a.recordProxy(e)
+    if a.GroupBy != nil {
+        if e.Label == "" { e.Label = NoLabel }
+        e.Label = a.labelKey(e.Label)
+        pl := a.PerLabel[e.Label]
+        if e.Success {
+            pl[0]++
+        } else {
+            pl[1]++
+        }
+        a.PerLabel[e.Label] = pl
+    }
+    if !e.Success {
+        if e.Reason == "" { e.Reason = "unknown" }
+        e.Reason = a.reasonKey(e.Reason)
+        a.PerReason[e.Reason]++
+    } else if a.SuccessReasons {
+        r := e.Reason
+        if r == "" { r = "unknown" }
+        if a.PerSuccessReason == nil { a.PerSuccessReason = make(map[string]int) }
+        a.PerSuccessReason[a.successReasonKey(r)]++
+    }
+    if a.TrackTargets {
+        if e.TotalTargets > 0 {
+            a.Targets.Expected = e.TotalTargets
+        }
+        a.trackTarget(e)
+    }
 
-    bt := a.bucketStart(parseTime(e.TS))
-    a.ensureBucket(bt)
+    ts, ok := parseTimeOK(e.TS)
+    if !ok {
+        a.badTS++
+    }
+    if a.store != nil {
+        a.store.push(Event{Entry: e, Time: ts})
+    }
+    a.recordSlow(Event{Entry: e, Time: ts})
+
+    bt, ok := a.bucketStart(ts)
+    if !ok {
+        a.Unbucketed++ // counted above, kept off the timeline
+        return
+    }
+    a.recordSeen(e.InstanceID, e.BatchRegion, ts)
+    a.noteAttempt(e.InstanceID, e.Attempt, ts)
+    if !a.countBucket(bt, e) {
+        a.LateDropped++ // counted above, older than the retained timeline
+    }
+}
+
+// countBucket adds an ingested entry to bucket bt, reporting false if bt is
+// no longer on the timeline; call with mu held.
+func (a *Aggregator) countBucket(bt int, e Entry) bool {
+    if !a.ensureBucket(bt) {
+        return false
+    }
     idx := a.bucketIndex[bt]
+    a.recordBucketRegion(e.BatchRegion, e.Success, bt)
+    a.recordBucketInstance(e.InstanceID, e.Success, bt)
     if e.Success {
         a.Timeline[idx][1]++
     } else {
         a.Timeline[idx][2]++
+        a.recordTrend(e.Reason, bt)
     }
+    return true
 }
 
+// SetBucketSeconds changes the bucket size. The timeline is rebuilt from
+// the retained events when RetainEvents is on (as far back as they go),
+// else it starts over empty.
 func (a *Aggregator) SetBucketSeconds(sec int) {
     if sec < 1 { sec = 1 }
+    a.mu.Lock()
+    defer a.mu.Unlock()
     a.BucketSecs = sec
     a.Timeline = a.Timeline[:0]
     a.bucketIndex = make(map[int]int)
+    a.preFilled = false
+    if a.trends != nil {
+        a.trends = make(map[string]map[int]int)
+    }
+    if a.bucketRegions != nil {
+        a.bucketRegions = make(map[int]map[string][2]int)
+    }
+    if a.bucketInstances != nil {
+        a.bucketInstances = make(map[int]map[string][2]int)
+    }
+    if a.store != nil {
+        a.store.each(func(ev Event) {
+            if bt, ok := a.bucketStart(ev.Time); ok {
+                a.countBucket(bt, ev.Entry)
+            }
+        })
+    }
+}
+
+// Reset zeroes every count, the timeline and the retained/slowest entries,
+// as if nothing had been ingested. File offsets, settings and the dedup
+// window are kept, so already-read lines are not counted again.
+func (a *Aggregator) Reset() {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.Success, a.Fail, a.Malformed, a.FailStreak = 0, 0, 0, 0
+    a.PerRegion = make(map[string][2]int)
+    a.PerInstance = make(map[string][2]int)
+    a.PerReason = make(map[string]int)
+    a.PerSuccessReason = nil
+    a.SoftFail = 0
+    a.PerLabel = make(map[string][2]int)
+    a.instanceSeen = make(map[string]time.Time)
+    a.regionSeen = make(map[string]time.Time)
+    a.Restarts, a.pendingRestarts = nil, nil
+    a.lastAttempt = make(map[string]int)
+    a.proxy, a.lastFailed = ProxyStats{}, make(map[string]bool)
+    a.Timeline = a.Timeline[:0]
+    a.bucketIndex = make(map[int]int)
+    a.preFilled = false
+    a.Targets = TargetCounts{Expected: a.Targets.Expected} // the announced batch size isn't a count
+    a.targets = make(map[string]targetState)
+    if a.store != nil {
+        a.store = &eventRing{buf: make([]Event, len(a.store.buf))}
+    }
+    a.latency, a.latencyNext = nil, 0
+    a.latHist = LatencyHistogram{}
+    a.regionLatency = make(map[string]*latencyRing)
+    if a.slow != nil {
+        a.slow = a.slow[:0]
+    }
+    if a.trends != nil {
+        a.trends = make(map[string]map[int]int)
+    }
+    if a.bucketRegions != nil {
+        a.bucketRegions = make(map[int]map[string][2]int)
+    }
+    if a.bucketInstances != nil {
+        a.bucketInstances = make(map[int]map[string][2]int)
+    }
+    a.DuplicatesSkipped = 0
+    a.Unbucketed = 0
+    a.LateDropped = 0
+    a.MissingRegion, a.MissingInstance = 0, 0
+    a.UnknownFields = 0
+    a.Violations = make(map[string]FieldIssues)
+    a.InvalidLines = 0
 }
 
 func trimNewlineBytes(b []byte) []byte {
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+    "fmt"
+    "testing"
+    "time"
+
+    "secmon/internal/tail"
+)
+
+// entryLine is a metrics line for instance id at a fixed time.
+func entryLine(id string, ok bool) string {
+    return fmt.Sprintf(`{"ts":"2026-01-02T03:04:05","instance_id":%q,"success":%t,"batch_region":"eu"}`+"\n", id, ok)
+}
+
+func newMemAggregator(src *tail.MemSource, pattern string) *Aggregator {
+    a := NewAggregator(pattern, 60, 72)
+    a.Source = src
+    return a
+}
+
+func TestMaxFilesReadmitResumes(t *testing.T) {
+    src := tail.NewMemSource()
+    a := newMemAggregator(src, "m/*.jsonl")
+    a.MaxFiles = 1
+    a.stats.Cold = -1 // see a's writes at once, not after coldStat
+    src.Append("m/a.jsonl", entryLine("a", true))
+    src.Append("m/b.jsonl", entryLine("b", true)) // newest: a is left out
+    a.Update()
+    src.Append("m/a.jsonl", entryLine("a", true)) // a is newest again
+    a.Update()
+    src.Append("m/b.jsonl", entryLine("b", true))
+    a.Update()
+    src.Append("m/a.jsonl", entryLine("a", true))
+    a.Update()
+    got := a.Snapshot().PerInstance
+    // every line once: re-admitted files resume at their offsets
+    if got["a"][0] != 3 || got["b"][0] != 2 {
+        t.Fatalf("PerInstance = %v, want a 3, b 2", got)
+    }
+}
+
+// TestUpdateSnapshotConcurrent hammers the reader (Update, under updateMu)
+// against the readers of counts (Snapshot and friends, under mu); run it
+// with -race.
+func TestUpdateSnapshotConcurrent(t *testing.T) {
+    src := tail.NewMemSource()
+    a := newMemAggregator(src, "m/*.jsonl")
+    const n = 500
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        for i := 0; i < n; i++ {
+            src.Append(fmt.Sprintf("m/%d.jsonl", i%4), entryLine(fmt.Sprint(i%7), i%3 != 0))
+            a.Update()
+        }
+    }()
+    for running := true; running; {
+        select {
+        case <-done:
+            running = false
+        default:
+        }
+        st := a.Snapshot()
+        if st.Success+st.Fail > n {
+            t.Fatalf("counted %d entries of %d written", st.Success+st.Fail, n)
+        }
+        a.EnsureBucketsTo(time.Now())
+        a.Offsets()
+    }
+    a.Update()
+    if st := a.Snapshot(); st.Success+st.Fail != n {
+        t.Fatalf("counted %d entries, want %d", st.Success+st.Fail, n)
+    }
+}
+
+func TestUpdateSplitLine(t *testing.T) {
+    src := tail.NewMemSource()
+    a := newMemAggregator(src, "m/*.jsonl")
+    line := entryLine("i1", false)
+    half := len(line) / 2
+    src.Append("m/a.jsonl", line[:half])
+    if us := a.Update(); us.Lines != 0 {
+        t.Fatalf("first half: read %d lines, want none", us.Lines)
+    }
+    src.Append("m/a.jsonl", line[half:])
+    a.Update()
+    a.Update()
+    st := a.Snapshot()
+    if st.PerInstance["i1"] != [2]int{0, 1} || st.Malformed != 0 {
+        t.Fatalf("got PerInstance %v, %d malformed; want one failure for i1 and nothing malformed", st.PerInstance, st.Malformed)
+    }
+}
+
+func TestUpdateBriefShrink(t *testing.T) {
+    src := tail.NewMemSource()
+    a := newMemAggregator(src, "m/*.jsonl")
+    first := entryLine("i1", true)
+    src.Create("m/a.jsonl", first+entryLine("i1", true))
+    a.Update()
+    src.Truncate("m/a.jsonl", len(first)) // a brief false shrink
+    a.Update()
+    src.Append("m/a.jsonl", entryLine("i1", true)+entryLine("i1", true)) // recovered, plus one more
+    a.Update()
+    if got := a.Snapshot().PerInstance["i1"][0]; got != 3 {
+        t.Fatalf("counted %d successes, want 3: the shrink must not cause a re-read", got)
+    }
+}
+
+func TestReasonTrendsRankByCount(t *testing.T) {
+    src := tail.NewMemSource()
+    a := newMemAggregator(src, "m/*.jsonl")
+    a.TrackReasonTrends(2)
+    fail := func(reason string, n int) {
+        for i := 0; i < n; i++ {
+            src.Append("m/a.jsonl", fmt.Sprintf(`{"ts":"2026-01-02T03:04:05","instance_id":"i1","success":false,"reason":%q}`+"\n", reason))
+        }
+    }
+    fail("early", 1) // seen first, but rare
+    fail("dns", 3)
+    fail("timeout", 5)
+    a.Update()
+    got := a.TrendReasons()
+    want := []string{"timeout", "dns", OtherKey}
+    if fmt.Sprint(got) != fmt.Sprint(want) {
+        t.Fatalf("TrendReasons = %q, want %q", got, want)
+    }
+    if tr := a.ReasonTrend(OtherKey); len(tr) == 0 || tr[len(tr)-1] != 1 {
+        t.Errorf("ReasonTrend(other) = %v, want the one early failure", tr)
+    }
+    fail("early", 6) // now climbing: it takes dns's place
+    a.Update()
+    got = a.TrendReasons()
+    want = []string{"early", "timeout", OtherKey}
+    if fmt.Sprint(got) != fmt.Sprint(want) {
+        t.Fatalf("after early climbs: TrendReasons = %q, want %q", got, want)
+    }
+}
@@ -0,0 +1,45 @@
+package metrics
+
+// ProxyStats answers "is the proxy and rotation strategy working?": how
+// attempts split by proxy use, how often a failure was followed by an
+// attempt with rotated_on_failure, and how those rotated attempts fared.
+type ProxyStats struct {
+    Proxied    int // attempts with proxy set
+    ProxyFail  int
+    Direct     int // attempts without
+    DirectFail int
+
+    // FollowedFails counts failures the same instance has made another
+    // attempt after; RotatedAfter those whose next attempt had rotated.
+    FollowedFails int
+    RotatedAfter  int
+
+    Rotated   int // attempts with rotated_on_failure
+    RotatedOK int // ...that succeeded
+}
+
+// recordProxy tallies e into ProxyStats; call with mu held, with the
+// normalized instance ID.
+func (a *Aggregator) recordProxy(e Entry) {
+    p := &a.proxy
+    if e.Proxy {
+        p.Proxied++
+        if !e.Success { p.ProxyFail++ }
+    } else {
+        p.Direct++
+        if !e.Success { p.DirectFail++ }
+    }
+    if e.RotatedOnFailure {
+        p.Rotated++
+        if e.Success { p.RotatedOK++ }
+    }
+    if a.lastFailed[e.InstanceID] {
+        p.FollowedFails++
+        if e.RotatedOnFailure { p.RotatedAfter++ }
+    }
+    if e.Success {
+        delete(a.lastFailed, e.InstanceID)
+    } else {
+        a.lastFailed[e.InstanceID] = true
+    }
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import "sort"
+
+// Per-region latency is kept as a bounded ring of recent elapsed_ms values
+// per region, for at most regionLatencyMax regions; later ones share
+// OtherKey's ring, as past MaxRegions (which is unbounded by default).
+const (
+    regionLatencySamples = 256
+    regionLatencyMax     = 64
+)
+
+// LatencyPercentiles summarizes a set of elapsed_ms samples.
+type LatencyPercentiles struct {
+    P50, P90, P99 int
+    N             int // samples they're taken over
+}
+
+type latencyRing struct {
+    buf  []int
+    next int
+}
+
+func (r *latencyRing) add(ms int) {
+    if len(r.buf) < regionLatencySamples {
+        r.buf = append(r.buf, ms)
+        return
+    }
+    r.buf[r.next] = ms
+    r.next = (r.next + 1) % regionLatencySamples
+}
+
+func (r *latencyRing) percentiles() LatencyPercentiles {
+    s := append([]int(nil), r.buf...)
+    sort.Ints(s)
+    at := func(p float64) int { return s[int(p/100*float64(len(s)-1))] }
+    return LatencyPercentiles{P50: at(50), P90: at(90), P99: at(99), N: len(s)}
+}
+
+// recordRegionLatency adds ms to region's ring; call with mu held.
+func (a *Aggregator) recordRegionLatency(region string, ms int) {
+    r, ok := a.regionLatency[region]
+    if !ok {
+        if len(a.regionLatency) >= regionLatencyMax {
+            region = OtherKey
+            r = a.regionLatency[region]
+        }
+        if r == nil {
+            r = &latencyRing{}
+            a.regionLatency[region] = r
+        }
+    }
+    r.add(ms)
+}
+
+// RegionLatency returns the p50/p90/p99 of region's recent elapsed_ms
+// values, reporting false when it has none.
+func (a *Aggregator) RegionLatency(region string) (LatencyPercentiles, bool) {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    r, ok := a.regionLatency[region]
+    if !ok || len(r.buf) == 0 {
+        return LatencyPercentiles{}, false
+    }
+    return r.percentiles(), true
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import "time"
+
+// maxPendingRestarts bounds the restarts queued for TakeRestarts; older ones
+// still count in Restarts.
+const maxPendingRestarts = 256
+
+// Restart is a detected producer restart.
+type Restart struct {
+    Instance string
+    At       time.Time
+    Attempt  int // the attempt the instance dropped back to
+    Prev     int // its highest attempt before
+}
+
+// noteAttempt spots an instance's attempt counter dropping back to 1 (or 0)
+// after being higher, the sign of a restarted runner; call with mu held,
+// with the normalized instance ID.
+func (a *Aggregator) noteAttempt(instance string, attempt int, at time.Time) {
+    if !a.DetectRestarts {
+        return
+    }
+    prev, seen := a.lastAttempt[instance]
+    a.lastAttempt[instance] = attempt
+    if !seen || attempt > 1 || prev <= 1 {
+        return
+    }
+    a.countRestart(instance)
+    a.pendingRestarts = append(a.pendingRestarts, Restart{Instance: instance, At: at, Attempt: attempt, Prev: prev})
+    if n := len(a.pendingRestarts) - maxPendingRestarts; n > 0 {
+        a.pendingRestarts = a.pendingRestarts[n:]
+    }
+}
+
+func (a *Aggregator) countRestart(instance string) {
+    if a.Restarts == nil {
+        a.Restarts = make(map[string]int)
+    }
+    a.Restarts[instance]++
+}
+
+// CountRestart counts a restart of instance detected elsewhere (e.g. a log
+// marker line), keyed like PerInstance.
+func (a *Aggregator) CountRestart(instance string) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.countRestart(a.instanceKey(instance))
+}
+
+// TakeRestarts returns the restarts detected from attempt counters since the
+// last call, oldest first.
+func (a *Aggregator) TakeRestarts() []Restart {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    out := a.pendingRestarts
+    a.pendingRestarts = nil
+    return out
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+    "container/heap"
+    "sort"
+)
+
+// slowHeap is a min-heap on ElapsedMS holding the slowest events seen, so the
+// fastest of them is the one evicted.
+type slowHeap []Event
+
+func (h slowHeap) Len() int           { return len(h) }
+func (h slowHeap) Less(i, j int) bool { return h[i].ElapsedMS < h[j].ElapsedMS }
+func (h slowHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *slowHeap) Push(x any)        { *h = append(*h, x.(Event)) }
+func (h *slowHeap) Pop() any {
+    old := *h
+    ev := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return ev
+}
+
+// TrackSlowest keeps the n entries with the highest elapsed_ms for
+// SlowestEvents. n <= 0 disables tracking (the default); changing n drops
+// anything already tracked.
+func (a *Aggregator) TrackSlowest(n int) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.slowMax = n
+    a.slow = nil
+    if n > 0 {
+        a.slow = make(slowHeap, 0, n)
+    }
+}
+
+// recordSlow offers ev to the slowest set; call with mu held.
+func (a *Aggregator) recordSlow(ev Event) {
+    if a.slowMax <= 0 || ev.ElapsedMS <= 0 {
+        return
+    }
+    if len(a.slow) < a.slowMax {
+        heap.Push(&a.slow, ev)
+        return
+    }
+    if ev.ElapsedMS > a.slow[0].ElapsedMS {
+        a.slow[0] = ev
+        heap.Fix(&a.slow, 0)
+    }
+}
+
+// SlowestEvents returns up to n of the slowest tracked events, slowest first
+// (all of them for n <= 0). Nil unless TrackSlowest was called.
+func (a *Aggregator) SlowestEvents(n int) []Event {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    if a.slow == nil {
+        return nil
+    }
+    out := append([]Event(nil), a.slow...)
+    sort.Slice(out, func(i, j int) bool { return out[i].ElapsedMS > out[j].ElapsedMS })
+    if n > 0 && len(out) > n {
+        out = out[:n]
+    }
+    return out
+}
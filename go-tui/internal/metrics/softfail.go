@@ -0,0 +1,22 @@
+package metrics
+
+import "strings"
+
+// ReasonSet parses a comma-separated reason list (e.g. --soft-fail-reasons)
+// into the lowercased set softFail matches against; nil if it names none.
+func ReasonSet(spec string) map[string]bool {
+    var set map[string]bool
+    for _, r := range strings.Split(spec, ",") {
+        if r = strings.ToLower(strings.TrimSpace(r)); r != "" {
+            if set == nil { set = make(map[string]bool) }
+            set[r] = true
+        }
+    }
+    return set
+}
+
+// softFail reports whether e is a success whose reason (trimmed, any case)
+// is one of SoftFailReasons.
+func (a *Aggregator) softFail(e Entry) bool {
+    return e.Success && a.SoftFailReasons[strings.ToLower(strings.TrimSpace(e.Reason))]
+}
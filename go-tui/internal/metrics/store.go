@@ -0,0 +1,89 @@
+package metrics
+
+import "time"
+
+// Event is a retained entry with its parsed timestamp. InstanceID and
+// BatchRegion are normalized as they were counted.
+type Event struct {
+    Entry
+    Time time.Time
+}
+
+// eventRing keeps the last len(buf) events, oldest first from head.
+type eventRing struct {
+    buf  []Event
+    head int
+    full bool
+}
+
+func (r *eventRing) push(ev Event) {
+    r.buf[r.head] = ev
+    r.head++
+    if r.head == len(r.buf) {
+        r.head = 0
+        r.full = true
+    }
+}
+
+// each calls fn for every retained event from oldest to newest.
+func (r *eventRing) each(fn func(Event)) {
+    if r.full {
+        for _, ev := range r.buf[r.head:] {
+            fn(ev)
+        }
+    }
+    for _, ev := range r.buf[:r.head] {
+        fn(ev)
+    }
+}
+
+// RetainEvents keeps the last n ingested entries for the query methods below.
+// n <= 0 disables retention (the default: aggregates only). Changing n drops
+// anything already retained.
+func (a *Aggregator) RetainEvents(n int) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if n <= 0 {
+        a.store = nil
+        return
+    }
+    a.store = &eventRing{buf: make([]Event, n)}
+}
+
+func (a *Aggregator) filterEvents(keep func(Event) bool) []Event {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    if a.store == nil {
+        return nil
+    }
+    var out []Event
+    a.store.each(func(ev Event) {
+        if keep(ev) {
+            out = append(out, ev)
+        }
+    })
+    return out
+}
+
+// Events returns all retained events, oldest first.
+func (a *Aggregator) Events() []Event {
+    return a.filterEvents(func(Event) bool { return true })
+}
+
+// EventsSince returns retained events timestamped at or after t.
+func (a *Aggregator) EventsSince(t time.Time) []Event {
+    return a.filterEvents(func(ev Event) bool { return !ev.Time.Before(t) })
+}
+
+func (a *Aggregator) EventsForRegion(region string) []Event {
+    return a.filterEvents(func(ev Event) bool { return ev.BatchRegion == region })
+}
+
+func (a *Aggregator) EventsForInstance(instance string) []Event {
+    return a.filterEvents(func(ev Event) bool { return ev.InstanceID == instance })
+}
+
+// EventsWhere returns the retained events keep accepts, oldest first.
+func (a *Aggregator) EventsWhere(keep func(Event) bool) []Event {
+    return a.filterEvents(keep)
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+    "bytes"
+    "encoding/json"
+    "strconv"
+    "strings"
+)
+
+// unknownField returns the first field of a JSON object line that Entry has
+// no field for, "" if there is none. It decodes the line a second time, so
+// it only runs under StrictJSON.
+func unknownField(line []byte) string {
+    dec := json.NewDecoder(bytes.NewReader(line))
+    dec.DisallowUnknownFields()
+    var e Entry
+    err := dec.Decode(&e)
+    if err == nil {
+        return ""
+    }
+    // encoding/json has no typed error for this one
+    q, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+    if !ok {
+        return ""
+    }
+    if f, err := strconv.Unquote(q); err == nil {
+        return f
+    }
+    return q
+}
@@ -0,0 +1,75 @@
+package metrics
+
+// TargetCounts collapses attempts into one final outcome per (instance, url)
+// target, so retries don't double count.
+type TargetCounts struct {
+    Total     int `json:"total"`     // distinct targets seen
+    Succeeded int `json:"succeeded"` // final attempt succeeded (includes Recovered)
+    Recovered int `json:"recovered"` // succeeded after at least one failed attempt
+    Failed    int `json:"failed"`    // final attempt failed so far
+    Expected  int `json:"expected,omitempty"` // latest total_targets seen (0: unknown)
+    Untracked int `json:"untracked,omitempty"` // entries for new targets past MaxTargets, not counted above
+}
+
+type targetState struct {
+    attempt int
+    success bool
+    sawFail bool
+}
+
+func targetKey(e Entry) string {
+    return e.InstanceID + "\x00" + e.URL
+}
+
+// trackTarget folds e into the per-target outcome. The highest attempt number
+// decides the outcome; a lower attempt arriving late only records a failure.
+// Entries without a URL can't be grouped and are skipped.
+func (a *Aggregator) trackTarget(e Entry) {
+    if e.URL == "" {
+        return
+    }
+    key := targetKey(e)
+    t, seen := a.targets[key]
+    if !seen && a.MaxTargets > 0 && len(a.targets) >= a.MaxTargets {
+        a.Targets.Untracked++
+        return
+    }
+    if seen {
+        a.Targets.remove(t)
+    } else {
+        a.Targets.Total++
+    }
+    if !e.Success {
+        t.sawFail = true
+    }
+    if !seen || e.Attempt >= t.attempt {
+        t.attempt = e.Attempt
+        t.success = e.Success
+    }
+    a.targets[key] = t
+    a.Targets.add(t)
+}
+
+func (c *TargetCounts) add(t targetState) {
+    switch {
+    case t.success && t.sawFail:
+        c.Succeeded++
+        c.Recovered++
+    case t.success:
+        c.Succeeded++
+    default:
+        c.Failed++
+    }
+}
+
+func (c *TargetCounts) remove(t targetState) {
+    switch {
+    case t.success && t.sawFail:
+        c.Succeeded--
+        c.Recovered--
+    case t.success:
+        c.Succeeded--
+    default:
+        c.Failed--
+    }
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+    "encoding/json"
+    "testing"
+    "time"
+)
+
+func TestParseTimestamps(t *testing.T) {
+    want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+    tests := []struct {
+        name, json string
+        want       time.Time
+    }{
+        {"iso", `"2026-01-02T03:04:05"`, want},
+        {"epoch seconds", `1767323045`, want},
+        {"epoch seconds, quoted", `"1767323045"`, want},
+        {"epoch seconds, fractional", `1767323045.25`, want.Add(250 * time.Millisecond)},
+        {"epoch millis", `1767323045123`, want.Add(123 * time.Millisecond)},
+        {"epoch millis, quoted", `"1767323045123"`, want.Add(123 * time.Millisecond)},
+        {"epoch micros", `1767323045123456`, want.Add(123456 * time.Microsecond)},
+        {"epoch nanos", `1767323045123456789`, want.Add(123456789)},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var e Entry
+            if err := json.Unmarshal([]byte(`{"ts":`+tt.json+`}`), &e); err != nil {
+                t.Fatal(err)
+            }
+            got, ok := parseTimeOK(e.TS)
+            if !ok || !got.Equal(tt.want) {
+                t.Errorf("ts %s: got %v (ok %t), want %v", tt.json, got, ok, tt.want)
+            }
+        })
+    }
+}
+
+func TestParseTimestampFallback(t *testing.T) {
+    for _, ts := range []string{`""`, `null`, `"yesterday"`, `0`, `-5`} {
+        var e Entry
+        if err := json.Unmarshal([]byte(`{"ts":`+ts+`}`), &e); err != nil {
+            t.Fatalf("ts %s: %v", ts, err)
+        }
+        before := time.Now()
+        got, ok := parseTimeOK(e.TS)
+        if ok || got.Before(before) {
+            t.Errorf("ts %s: got %v (ok %t), want a fallback to now", ts, got, ok)
+        }
+    }
+}
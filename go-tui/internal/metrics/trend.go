@@ -0,0 +1,135 @@
+package metrics
+
+import "sort"
+
+// TrackReasonTrends keeps per-bucket failure counts per reason (up to
+// MaxReasons, like PerReason), for ReasonTrend; the k reasons with the most
+// failures on the timeline are shown on their own and the rest folded into
+// OtherKey. The ranking is redone on every call, so a reason that starts
+// climbing displaces one whose failures have aged off the timeline. k <= 0
+// disables tracking (the default); changing k drops anything already
+// tracked.
+func (a *Aggregator) TrackReasonTrends(k int) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    a.trendMax = k
+    a.trends = nil
+    if k > 0 {
+        a.trends = make(map[string]map[int]int)
+    }
+}
+
+// recordTrend counts a failure with reason in bucket bt; call with mu held.
+func (a *Aggregator) recordTrend(reason string, bt int) {
+    if a.trends == nil {
+        return
+    }
+    t, ok := a.trends[reason]
+    if !ok {
+        if a.MaxReasons > 0 && len(a.trends) >= a.MaxReasons {
+            reason = OtherKey
+            t = a.trends[reason]
+        }
+        if t == nil {
+            t = make(map[int]int)
+            a.trends[reason] = t
+        }
+    }
+    t[bt]++
+}
+
+// rankTrends splits the tracked reasons into the trendMax with the most
+// failures, most first, and the rest; call with mu held.
+func (a *Aggregator) rankTrends() (top, rest []string) {
+    total := make(map[string]int, len(a.trends))
+    for r, t := range a.trends {
+        if r == OtherKey {
+            rest = append(rest, r)
+            continue
+        }
+        for _, n := range t {
+            total[r] += n
+        }
+        top = append(top, r)
+    }
+    sort.Slice(top, func(i, j int) bool {
+        if total[top[i]] != total[top[j]] {
+            return total[top[i]] > total[top[j]]
+        }
+        return top[i] < top[j]
+    })
+    if len(top) > a.trendMax {
+        top, rest = top[:a.trendMax], append(rest, top[a.trendMax:]...)
+    }
+    return top, rest
+}
+
+// pruneBuckets drops trend and per-bucket region and instance counts for
+// buckets older than the timeline's first; call with mu held after the
+// timeline shrinks.
+func (a *Aggregator) pruneBuckets() {
+    if len(a.Timeline) == 0 {
+        return
+    }
+    first := a.Timeline[0][0]
+    for r, t := range a.trends {
+        for bt := range t {
+            if bt < first {
+                delete(t, bt)
+            }
+        }
+        if len(t) == 0 {
+            delete(a.trends, r) // off the timeline: free its slot
+        }
+    }
+    for bt := range a.bucketRegions {
+        if bt < first {
+            delete(a.bucketRegions, bt)
+        }
+    }
+    for bt := range a.bucketInstances {
+        if bt < first {
+            delete(a.bucketInstances, bt)
+        }
+    }
+}
+
+// ReasonTrend returns reason's failure count in each timeline bucket, aligned
+// with Buckets; OtherKey sums the reasons TrendReasons folds. Nil unless the
+// reason is tracked.
+func (a *Aggregator) ReasonTrend(reason string) []int {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    var ts []map[int]int
+    if reason == OtherKey {
+        _, rest := a.rankTrends()
+        for _, r := range rest {
+            ts = append(ts, a.trends[r])
+        }
+    } else if t, ok := a.trends[reason]; ok {
+        ts = append(ts, t)
+    }
+    if len(ts) == 0 {
+        return nil
+    }
+    out := make([]int, len(a.Timeline))
+    for i, p := range a.Timeline {
+        for _, t := range ts {
+            out[i] += t[p[0]]
+        }
+    }
+    return out
+}
+
+// TrendReasons lists the reasons with the most failures on the timeline,
+// most first, up to the k given to TrackReasonTrends, then OtherKey if any
+// were folded.
+func (a *Aggregator) TrendReasons() []string {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    top, rest := a.rankTrends()
+    if len(rest) > 0 {
+        top = append(top, OtherKey)
+    }
+    return top
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+    "fmt"
+    "strings"
+)
+
+// UpdateStats is what one Update did, for the per-tick --debug line.
+type UpdateStats struct {
+    Files      int   // files looked at
+    Bytes      int64 // bytes consumed (complete lines)
+    Lines      int   // complete lines read, blank ones included
+    Ingested   int   // entries counted (after dedup)
+    Malformed  int   // lines that weren't valid JSON entries
+    BadTS      int   // entries whose ts couldn't be parsed (counted at now)
+    Invalid    int   // lines violating the schema, under Validate
+    Unknown    int   // entries rejected for unknown fields, under StrictJSON
+    Duplicates int   // entries dropped by Dedup
+    Buckets    int   // timeline buckets added
+    Success    int
+    Fail       int
+}
+
+// counts is the cumulative state UpdateStats are diffed from.
+type counts struct {
+    success, fail, dups, buckets, badTS int
+}
+
+// counters returns the cumulative counts; call with mu held.
+func (a *Aggregator) counters() counts {
+    return counts{a.Success, a.Fail, a.DuplicatesSkipped, a.bucketsAdded, a.badTS}
+}
+
+// since fills the count deltas between two counters readings. Counters that
+// went down (Reset meanwhile) count from zero.
+func (u *UpdateStats) since(before, after counts) {
+    d := func(b, a int) int {
+        if a < b {
+            return a
+        }
+        return a - b
+    }
+    u.Success = d(before.success, after.success)
+    u.Fail = d(before.fail, after.fail)
+    u.Ingested = u.Success + u.Fail
+    u.Duplicates = d(before.dups, after.dups)
+    u.Buckets = d(before.buckets, after.buckets)
+    u.BadTS = d(before.badTS, after.badTS)
+}
+
+// String is the one-line form, e.g. "files=12 read=3.4KB new_lines=87
+// ingested=80 malformed=2 parse_err=1 buckets+=1 success+=70 fail+=10";
+// dups=, invalid= and unknown_fields= are added when non-zero.
+func (u UpdateStats) String() string {
+    b := &strings.Builder{}
+    fmt.Fprintf(b, "files=%d read=%s new_lines=%d ingested=%d malformed=%d parse_err=%d", u.Files, byteSize(u.Bytes), u.Lines, u.Ingested, u.Malformed, u.BadTS)
+    if u.Duplicates > 0 {
+        fmt.Fprintf(b, " dups=%d", u.Duplicates)
+    }
+    if u.Invalid > 0 {
+        fmt.Fprintf(b, " invalid=%d", u.Invalid)
+    }
+    if u.Unknown > 0 {
+        fmt.Fprintf(b, " unknown_fields=%d", u.Unknown)
+    }
+    fmt.Fprintf(b, " buckets+=%d success+=%d fail+=%d", u.Buckets, u.Success, u.Fail)
+    return b.String()
+}
+
+func byteSize(n int64) string {
+    switch {
+    case n >= 1<<20:
+        return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+    case n >= 1<<10:
+        return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+    }
+    return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+    "bytes"
+    "encoding/json"
+)
+
+// LineKey is the Violations key for lines that are not a JSON object at all.
+const LineKey = "(line)"
+
+// FieldIssues counts schema violations for one entry field.
+type FieldIssues struct {
+    Missing   int // required field absent or null
+    WrongType int // present with a JSON type the field can't hold
+}
+
+func (f FieldIssues) Total() int { return f.Missing + f.WrongType }
+
+// jsonKind is the first byte class of a JSON value.
+type jsonKind int
+
+const (
+    kindString jsonKind = iota
+    kindNumber
+    kindBool
+    kindOther
+)
+
+// entrySchema lists every Entry field with the JSON kinds it accepts and
+// whether it must be present.
+var entrySchema = []struct {
+    name     string
+    kinds    []jsonKind
+    required bool
+}{
+    {"ts", []jsonKind{kindString, kindNumber}, true},
+    {"success", []jsonKind{kindBool}, true},
+    {"instance_id", []jsonKind{kindString}, false},
+    {"attempt", []jsonKind{kindNumber}, false},
+    {"reason", []jsonKind{kindString}, false},
+    {"elapsed_ms", []jsonKind{kindNumber}, false},
+    {"proxy", []jsonKind{kindBool}, false},
+    {"rotated_on_failure", []jsonKind{kindBool}, false},
+    {"url", []jsonKind{kindString}, false},
+    {"batch_region", []jsonKind{kindString}, false},
+    {"total_targets", []jsonKind{kindNumber}, false},
+}
+
+func kindOf(v json.RawMessage) jsonKind {
+    v = bytes.TrimSpace(v)
+    if len(v) == 0 {
+        return kindOther
+    }
+    switch c := v[0]; {
+    case c == '"':
+        return kindString
+    case c == '-' || (c >= '0' && c <= '9'):
+        return kindNumber
+    case c == 't' || c == 'f':
+        return kindBool
+    }
+    return kindOther
+}
+
+// validateLine checks one metrics line against the Entry schema and adds any
+// violations to issues. It reports whether the line was clean.
+func validateLine(line []byte, issues map[string]FieldIssues) bool {
+    var obj map[string]json.RawMessage
+    if err := json.Unmarshal(line, &obj); err != nil {
+        fi := issues[LineKey]
+        fi.WrongType++
+        issues[LineKey] = fi
+        return false
+    }
+    ok := true
+    for _, f := range entrySchema {
+        v, present := obj[f.name]
+        if !present || string(bytes.TrimSpace(v)) == "null" {
+            if f.required {
+                fi := issues[f.name]
+                fi.Missing++
+                issues[f.name] = fi
+                ok = false
+            }
+            continue
+        }
+        k, match := kindOf(v), false
+        for _, want := range f.kinds {
+            if k == want {
+                match = true
+            }
+        }
+        if !match {
+            fi := issues[f.name]
+            fi.WrongType++
+            issues[f.name] = fi
+            ok = false
+        }
+    }
+    return ok
+}
@@ -0,0 +1,127 @@
+package prom
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+
+    "secmon/internal/metrics"
+)
+
+// Content types for the two exposition formats.
+const (
+    TextType        = "text/plain; version=0.0.4; charset=utf-8"
+    OpenMetricsType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// maxExemplarLabels is the OpenMetrics limit on an exemplar's label set, in
+// characters of names and values combined.
+const maxExemplarLabels = 128
+
+// Handler serves agg's counters, health and latency histogram. Scrapers that
+// accept application/openmetrics-text get OpenMetrics, with the slowest
+// tracked requests (see Aggregator.TrackSlowest) as exemplars on their
+// latency buckets; everyone else gets the plain Prometheus text format.
+func Handler(agg *metrics.Aggregator) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        om := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+        if om {
+            w.Header().Set("Content-Type", OpenMetricsType)
+        } else {
+            w.Header().Set("Content-Type", TextType)
+        }
+        Write(w, agg, om)
+    })
+}
+
+// Write renders agg in the Prometheus text format, or OpenMetrics (with
+// exemplars and the closing # EOF) when om is set.
+func Write(w io.Writer, agg *metrics.Aggregator, om bool) {
+    st := agg.Snapshot()
+    counter := func(name, help string) {
+        if om {
+            // OpenMetrics names the family without _total; samples keep it.
+            name = strings.TrimSuffix(name, "_total")
+        }
+        fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+    }
+    counter("secmon_requests_total", "Metrics entries ingested, by outcome.")
+    fmt.Fprintf(w, "secmon_requests_total{result=\"success\"} %d\n", st.Success)
+    fmt.Fprintf(w, "secmon_requests_total{result=\"fail\"} %d\n", st.Fail)
+    if agg.SoftFailReasons != nil {
+        counter("secmon_soft_fail_total", "Successes whose reason is in --soft-fail-reasons (also counted as successes).")
+        fmt.Fprintf(w, "secmon_soft_fail_total %d\n", st.SoftFail)
+    }
+    counter("secmon_malformed_lines_total", "Non-empty metrics lines that failed to parse.")
+    fmt.Fprintf(w, "secmon_malformed_lines_total %d\n", st.Malformed)
+    fmt.Fprintf(w, "# HELP secmon_health Health score 0-100; -1 before any data.\n# TYPE secmon_health gauge\nsecmon_health %d\n", st.Health)
+
+    h := agg.Latency()
+    var exemplars []metrics.Event
+    if om {
+        exemplars = agg.SlowestEvents(0)
+    }
+    const name = "secmon_request_latency_seconds"
+    fmt.Fprintf(w, "# HELP %s Request elapsed_ms, in seconds.\n# TYPE %s histogram\n", name, name)
+    cum := 0
+    lo := 0
+    for i, n := range h.Counts {
+        cum += n
+        le := "+Inf"
+        hi := -1
+        if i < len(metrics.LatencyBoundsMS) {
+            hi = metrics.LatencyBoundsMS[i]
+            le = seconds(float64(hi))
+        }
+        fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d", name, le, cum)
+        if ev, ok := slowestIn(exemplars, lo, hi); ok {
+            fmt.Fprintf(w, " # %s %s %s", exemplarLabels(ev), seconds(float64(ev.ElapsedMS)), strconv.FormatFloat(float64(ev.Time.UnixMilli())/1000, 'f', 3, 64))
+        }
+        fmt.Fprintln(w)
+        lo = hi
+    }
+    fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", name, seconds(float64(h.SumMS)), name, h.Count)
+    if om {
+        fmt.Fprintln(w, "# EOF")
+    }
+}
+
+// slowestIn returns the slowest of evs (sorted slowest first) in the bucket
+// (lo, hi]; hi < 0 is unbounded.
+func slowestIn(evs []metrics.Event, lo, hi int) (metrics.Event, bool) {
+    for _, ev := range evs {
+        if ev.ElapsedMS > lo && (hi < 0 || ev.ElapsedMS <= hi) {
+            return ev, true
+        }
+    }
+    return metrics.Event{}, false
+}
+
+// exemplarLabels links an exemplar to its request: instance and url, the url
+// cut to fit the OpenMetrics label size limit.
+func exemplarLabels(ev metrics.Event) string {
+    room := maxExemplarLabels - len("instance") - len("url")
+    inst := cut(ev.InstanceID, room)
+    url := cut(ev.URL, room-utf8.RuneCountInString(inst))
+    return fmt.Sprintf("{instance=%s,url=%s}", quote(inst), quote(url))
+}
+
+// cut truncates s to at most n characters.
+func cut(s string, n int) string {
+    if utf8.RuneCountInString(s) <= n {
+        return s
+    }
+    return string([]rune(s)[:n])
+}
+
+func quote(s string) string {
+    r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+    return `"` + r.Replace(s) + `"`
+}
+
+func seconds(ms float64) string {
+    return strconv.FormatFloat(ms/1000, 'g', -1, 64)
+}
@@ -0,0 +1,115 @@
+package record
+
+import (
+    "bufio"
+    "encoding/json"
+    "os"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// queueLen is how many entries may wait for the writer before new ones are
+// dropped (and counted) rather than slowing ingestion down.
+const queueLen = 8192
+
+// flushEvery bounds how long a recorded entry can sit in the write buffer.
+const flushEvery = time.Second
+
+// line is one recorded entry: the entry as ingested (instance_id filled in
+// from the file name if it was missing, ts set to the arrival time if it
+// couldn't be parsed) plus when secmon read it. --replay reads it as a plain
+// metrics line and ignores recv_ts.
+type line struct {
+    metrics.Entry
+    RecvTS string `json:"recv_ts"`
+}
+
+type item struct {
+    e  metrics.Entry
+    at time.Time
+}
+
+// Recorder appends ingested entries to a capture file from a background
+// goroutine, so a slow disk can't stall the tick.
+type Recorder struct {
+    mu      sync.RWMutex // guards closed against Record racing Close
+    closed  bool
+    f       *os.File
+    q       chan item
+    done    chan struct{}
+    dropped atomic.Int64
+    err     error // first write error; read after Close
+}
+
+// Create truncates (or creates) path and starts the writer.
+func Create(path string) (*Recorder, error) {
+    f, err := os.Create(path)
+    if err != nil {
+        return nil, err
+    }
+    r := &Recorder{f: f, q: make(chan item, queueLen), done: make(chan struct{})}
+    go r.run()
+    return r, nil
+}
+
+// Record queues e; it never blocks, and is a no-op after Close.
+func (r *Recorder) Record(e metrics.Entry) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    if r.closed {
+        return
+    }
+    select {
+    case r.q <- item{e, time.Now()}:
+    default:
+        r.dropped.Add(1)
+    }
+}
+
+// Dropped is how many entries were lost to a full queue.
+func (r *Recorder) Dropped() int64 { return r.dropped.Load() }
+
+func (r *Recorder) run() {
+    defer close(r.done)
+    w := bufio.NewWriterSize(r.f, 64*1024)
+    enc := json.NewEncoder(w)
+    tick := time.NewTicker(flushEvery)
+    defer tick.Stop()
+    for {
+        select {
+        case it, ok := <-r.q:
+            if !ok {
+                if err := w.Flush(); err != nil && r.err == nil {
+                    r.err = err
+                }
+                return
+            }
+            if _, ok := it.e.TimeOK(); !ok {
+                it.e.TS = metrics.Timestamp(it.at.UTC().Format("2006-01-02T15:04:05"))
+            }
+            if err := enc.Encode(line{it.e, it.at.UTC().Format(time.RFC3339Nano)}); err != nil && r.err == nil {
+                r.err = err
+            }
+        case <-tick.C:
+            if err := w.Flush(); err != nil && r.err == nil {
+                r.err = err
+            }
+        }
+    }
+}
+
+// Close writes out everything queued and closes the file.
+func (r *Recorder) Close() error {
+    r.mu.Lock()
+    r.closed = true
+    close(r.q)
+    r.mu.Unlock()
+    <-r.done
+    if err := r.f.Close(); err != nil && r.err == nil {
+        r.err = err
+    }
+    return r.err
+}
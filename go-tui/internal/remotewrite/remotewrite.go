@@ -0,0 +1,266 @@
+// Package remotewrite pushes completed timeline buckets to a Prometheus
+// remote-write endpoint, each sample stamped with its bucket's start time.
+package remotewrite
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "sort"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// Bucket is one completed timeline bucket.
+type Bucket struct {
+    Start   time.Time
+    Secs    int
+    Success int
+    Fail    int
+    Regions map[string][2]int // [success, fail]; nil if not tracked
+}
+
+// Pusher sends buckets from a background goroutine, one request at a time,
+// and remembers what it delivered per bucket size, so each bucket is pushed
+// once, and again if late entries change it within Lookback. A push that
+// fails is retried with the next Push, unless the receiver refused it.
+type Pusher struct {
+    URL    string
+    Job    string // job label on every series
+    // Lookback is how far behind the newest delivered bucket a changed
+    // bucket is still re-sent; older late entries are not pushed.
+    Lookback time.Duration
+    client *http.Client
+    errf   func(error)
+
+    mu     sync.Mutex
+    sent   map[int]time.Time          // bucket size -> newest start delivered
+    counts map[int]map[int64]Bucket   // bucket size -> start (ms) -> as delivered, within Lookback
+    busy   bool
+    wg     sync.WaitGroup
+}
+
+// New returns a Pusher for url. errf, if set, is called from the sender
+// goroutine with each failed push.
+func New(url string, errf func(error)) *Pusher {
+    return &Pusher{
+        URL:      url,
+        Job:      "secmon",
+        Lookback: 10 * time.Minute,
+        client:   &http.Client{Timeout: 10 * time.Second},
+        errf:     errf,
+        sent:     make(map[int]time.Time),
+        counts:   make(map[int]map[int64]Bucket),
+    }
+}
+
+// Pending returns the buckets of bs that need pushing: those newer than
+// any delivered, and those within Lookback of the newest delivered whose
+// counts differ from what was (late entries).
+func (p *Pusher) Pending(bs []Bucket) []Bucket {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    var out []Bucket
+    for _, b := range bs {
+        done := p.sent[b.Secs]
+        if b.Start.After(done) {
+            out = append(out, b)
+            continue
+        }
+        if b.Start.Before(done.Add(-p.Lookback)) {
+            continue
+        }
+        if prev, ok := p.counts[b.Secs][b.Start.UnixMilli()]; !ok || !sameCounts(prev, b) {
+            out = append(out, b)
+        }
+    }
+    return out
+}
+
+func sameCounts(a, b Bucket) bool {
+    if a.Success != b.Success || a.Fail != b.Fail || len(a.Regions) != len(b.Regions) {
+        return false
+    }
+    for r, n := range a.Regions {
+        if m, ok := b.Regions[r]; !ok || m != n {
+            return false
+        }
+    }
+    return true
+}
+
+// delivered records bs as pushed and drops what has fallen out of Lookback;
+// call with mu held.
+func (p *Pusher) delivered(bs []Bucket) {
+    for _, b := range bs {
+        if b.Start.After(p.sent[b.Secs]) {
+            p.sent[b.Secs] = b.Start
+        }
+        if p.counts[b.Secs] == nil {
+            p.counts[b.Secs] = make(map[int64]Bucket)
+        }
+        p.counts[b.Secs][b.Start.UnixMilli()] = b
+    }
+    for secs, m := range p.counts {
+        oldest := p.sent[secs].Add(-p.Lookback).UnixMilli()
+        for ms := range m {
+            if ms < oldest {
+                delete(m, ms)
+            }
+        }
+    }
+}
+
+// Push sends bs (completed buckets of one size, any order) in the
+// background. It never blocks: while an earlier push is still in flight it
+// does nothing and reports false, and the caller offers the same buckets
+// again later. New buckets and re-sent ones go in separate requests, new
+// first, so a receiver refusing a changed sample (Prometheus answers 400 to
+// a second value at a timestamp) can't hold back the buckets after it. A
+// request refused with a 4xx other than 429 isn't retried: it is reported
+// and its buckets count as delivered.
+func (p *Pusher) Push(bs []Bucket) bool {
+    if len(bs) == 0 {
+        return true
+    }
+    p.mu.Lock()
+    if p.busy {
+        p.mu.Unlock()
+        return false
+    }
+    p.busy = true
+    var fresh, again []Bucket
+    for _, b := range bs {
+        if b.Start.After(p.sent[b.Secs]) {
+            fresh = append(fresh, b)
+        } else {
+            again = append(again, b)
+        }
+    }
+    p.mu.Unlock()
+    p.wg.Add(1)
+    go func() {
+        defer p.wg.Done()
+        for _, batch := range [][]Bucket{fresh, again} {
+            if len(batch) == 0 {
+                continue
+            }
+            sort.Slice(batch, func(i, j int) bool { return batch[i].Start.Before(batch[j].Start) })
+            err := p.send(Encode(p.Job, batch))
+            var re *rejectedError
+            if err == nil || errors.As(err, &re) {
+                p.mu.Lock()
+                p.delivered(batch)
+                p.mu.Unlock()
+            }
+            if err != nil && p.errf != nil {
+                p.errf(err)
+            }
+        }
+        p.mu.Lock()
+        p.busy = false
+        p.mu.Unlock()
+    }()
+    return true
+}
+
+// Close waits for a push in flight.
+func (p *Pusher) Close() {
+    p.wg.Wait()
+}
+
+// rejectedError is a request the receiver refused for good (a 4xx other
+// than 429): sending it again would only be refused again.
+type rejectedError struct {
+    status, msg string
+}
+
+func (e *rejectedError) Error() string {
+    return fmt.Sprintf("%s: %s (dropped, not retried)", e.status, e.msg)
+}
+
+func (p *Pusher) send(body []byte) error {
+    req, err := http.NewRequest(http.MethodPost, p.URL, bytes.NewReader(Snappy(body)))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Encoding", "snappy")
+    req.Header.Set("Content-Type", "application/x-protobuf")
+    req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+        if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+            return &rejectedError{resp.Status, string(bytes.TrimSpace(msg))}
+        }
+        return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+    }
+    return nil
+}
+
+// Encode builds a remote-write WriteRequest (uncompressed protobuf) for bs,
+// sorted oldest first: secmon_bucket_requests{result} per bucket and, where
+// regions are tracked, secmon_bucket_region_requests{region,result}, each
+// with a bucket_secs label and the bucket start as the sample timestamp.
+func Encode(job string, bs []Bucket) []byte {
+    type key struct{ region, result string }
+    series := make(map[key][]sample)
+    var keys []key
+    add := func(k key, ts time.Time, v int) {
+        if _, ok := series[k]; !ok {
+            keys = append(keys, k)
+        }
+        series[k] = append(series[k], sample{float64(v), ts.UnixMilli()})
+    }
+    for _, b := range bs {
+        add(key{"", "success"}, b.Start, b.Success)
+        add(key{"", "fail"}, b.Start, b.Fail)
+        regions := make([]string, 0, len(b.Regions))
+        for r := range b.Regions { regions = append(regions, r) }
+        sort.Strings(regions)
+        for _, r := range regions {
+            add(key{r, "success"}, b.Start, b.Regions[r][0])
+            add(key{r, "fail"}, b.Start, b.Regions[r][1])
+        }
+    }
+    secs := ""
+    if len(bs) > 0 {
+        secs = strconv.Itoa(bs[0].Secs)
+    }
+    var req []byte
+    for _, k := range keys {
+        // labels sorted by name, as receivers require
+        labels := [][2]string{{"__name__", "secmon_bucket_requests"}, {"bucket_secs", secs}, {"job", job}}
+        if k.region != "" {
+            labels = [][2]string{{"__name__", "secmon_bucket_region_requests"}, {"bucket_secs", secs}, {"job", job}, {"region", k.region}}
+        }
+        labels = append(labels, [2]string{"result", k.result})
+        var ts []byte
+        for _, l := range labels {
+            var lb []byte
+            lb = appendString(lb, 1, l[0])
+            lb = appendString(lb, 2, l[1])
+            ts = appendBytes(ts, 1, lb)
+        }
+        for _, s := range series[k] {
+            var sb []byte
+            sb = appendFixed64(sb, 1, s.value)
+            sb = appendVarintField(sb, 2, uint64(s.ts))
+            ts = appendBytes(ts, 2, sb)
+        }
+        req = appendBytes(req, 1, ts)
+    }
+    return req
+}
+
+type sample struct {
+    value float64
+    ts    int64 // Unix milliseconds
+}
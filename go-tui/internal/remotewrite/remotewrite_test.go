@@ -0,0 +1,241 @@
+package remotewrite
+
+import (
+    "encoding/binary"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "net/http/httptest"
+    "sort"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+// unsnappy decodes a Snappy block: literals and copies, whichever the
+// encoder chose.
+func unsnappy(src []byte) ([]byte, error) {
+    n, k := binary.Uvarint(src)
+    if k <= 0 {
+        return nil, fmt.Errorf("bad length")
+    }
+    src = src[k:]
+    out := make([]byte, 0, n)
+    for len(src) > 0 {
+        tag := src[0]
+        switch tag & 3 {
+        case 0:
+            l := int(tag>>2) + 1
+            src = src[1:]
+            if l > 60 {
+                nb := l - 60
+                l = 0
+                for i := 0; i < nb; i++ {
+                    l |= int(src[i]) << (8 * i)
+                }
+                l++
+                src = src[nb:]
+            }
+            out = append(out, src[:l]...)
+            src = src[l:]
+        default:
+            var l, off int
+            switch tag & 3 {
+            case 1:
+                l, off = int(tag>>2&7)+4, int(tag>>5)<<8|int(src[1])
+                src = src[2:]
+            case 2:
+                l, off = int(tag>>2)+1, int(binary.LittleEndian.Uint16(src[1:]))
+                src = src[3:]
+            case 3:
+                l, off = int(tag>>2)+1, int(binary.LittleEndian.Uint32(src[1:]))
+                src = src[5:]
+            }
+            if off == 0 || off > len(out) {
+                return nil, fmt.Errorf("bad copy offset %d", off)
+            }
+            for i := 0; i < l; i++ {
+                out = append(out, out[len(out)-off])
+            }
+        }
+    }
+    if uint64(len(out)) != n {
+        return nil, fmt.Errorf("decoded %d bytes, header says %d", len(out), n)
+    }
+    return out, nil
+}
+
+// fields splits a protobuf message into (field number, payload) pairs:
+// length-delimited payloads as is, fixed64 and varints as 8 little-endian
+// bytes.
+func fields(t *testing.T, b []byte) [][2]any {
+    var out [][2]any
+    for len(b) > 0 {
+        key, k := binary.Uvarint(b)
+        b = b[k:]
+        var v []byte
+        switch key & 7 {
+        case 0:
+            x, k := binary.Uvarint(b)
+            v, b = binary.LittleEndian.AppendUint64(nil, x), b[k:]
+        case 1:
+            v, b = b[:8], b[8:]
+        case 2:
+            l, k := binary.Uvarint(b)
+            v, b = b[k:k+int(l)], b[k+int(l):]
+        default:
+            t.Fatalf("unexpected wire type %d", key&7)
+        }
+        out = append(out, [2]any{int(key >> 3), v})
+    }
+    return out
+}
+
+// decode reads a WriteRequest into "name{labels} ts=value" strings.
+func decode(t *testing.T, req []byte) []string {
+    var out []string
+    for _, f := range fields(t, req) {
+        var labels []string
+        var samples []string
+        for _, tf := range fields(t, f[1].([]byte)) {
+            body := tf[1].([]byte)
+            switch tf[0].(int) {
+            case 1:
+                l := fields(t, body)
+                labels = append(labels, fmt.Sprintf("%s=%s", l[0][1], l[1][1]))
+            case 2:
+                s := fields(t, body)
+                v := math.Float64frombits(binary.LittleEndian.Uint64(s[0][1].([]byte)))
+                ts := int64(binary.LittleEndian.Uint64(s[1][1].([]byte)))
+                samples = append(samples, fmt.Sprintf("%d=%g", ts, v))
+            }
+        }
+        for _, s := range samples {
+            out = append(out, "{"+strings.Join(labels, ",")+"} "+s)
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+// receiver is a remote-write endpoint recording each decoded request it
+// accepts; reject, if set, picks requests to answer 400 instead.
+func receiver(t *testing.T, reject func([]string) bool) (*httptest.Server, func() [][]string) {
+    var mu sync.Mutex
+    var got [][]string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Header.Get("Content-Encoding") != "snappy" {
+            t.Errorf("Content-Encoding %q", r.Header.Get("Content-Encoding"))
+        }
+        body, _ := io.ReadAll(r.Body)
+        req, err := unsnappy(body)
+        if err != nil {
+            t.Errorf("snappy: %v", err)
+        }
+        samples := decode(t, req)
+        mu.Lock()
+        defer mu.Unlock()
+        if reject != nil && reject(samples) {
+            http.Error(w, "duplicate sample for timestamp", http.StatusBadRequest)
+            return
+        }
+        got = append(got, samples)
+    }))
+    return srv, func() [][]string {
+        mu.Lock()
+        defer mu.Unlock()
+        return got
+    }
+}
+
+func TestPushResendsLateEntries(t *testing.T) {
+    srv, got := receiver(t, nil)
+    defer srv.Close()
+    p := New(srv.URL, func(err error) { t.Error(err) })
+    t0 := time.Unix(1767323040, 0)
+    b1 := Bucket{Start: t0, Secs: 60, Success: 2, Fail: 1}
+    b2 := Bucket{Start: t0.Add(time.Minute), Secs: 60, Success: 1}
+    push := func(bs ...Bucket) {
+        p.Push(p.Pending(bs))
+        p.Close()
+    }
+
+    push(b1, b2)
+    if g := got(); len(g) != 1 || len(g[0]) != 4 {
+        t.Fatalf("first push: got %q, want one request with 4 samples", g)
+    }
+    push(b1, b2) // unchanged: nothing to send
+    if g := got(); len(g) != 1 {
+        t.Fatalf("unchanged buckets were pushed again: %q", g[1:])
+    }
+    b1.Fail++ // a late entry
+    push(b1, b2)
+    g := got()
+    if len(g) != 2 {
+        t.Fatalf("late entry: got %d requests, want 2", len(g))
+    }
+    want := []string{
+        "{__name__=secmon_bucket_requests,bucket_secs=60,job=secmon,result=fail} 1767323040000=2",
+        "{__name__=secmon_bucket_requests,bucket_secs=60,job=secmon,result=success} 1767323040000=2",
+    }
+    if strings.Join(g[1], "\n") != strings.Join(want, "\n") {
+        t.Errorf("late entry: pushed\n%s\nwant\n%s", strings.Join(g[1], "\n"), strings.Join(want, "\n"))
+    }
+
+    // past Lookback a change is no longer sent
+    p.Lookback = 0
+    b1.Fail++
+    push(b1, b2)
+    if g := got(); len(g) != 2 {
+        t.Errorf("a bucket older than Lookback was re-sent: %q", g[2:])
+    }
+}
+
+func TestPushRejectedResendDoesNotBlock(t *testing.T) {
+    // like Prometheus: a second value for a series at a timestamp is a 400
+    seen := map[string]bool{}
+    srv, got := receiver(t, func(samples []string) bool {
+        key := func(s string) string { return s[:strings.LastIndex(s, "=")] } // series and timestamp
+        for _, s := range samples {
+            if seen[key(s)] {
+                return true
+            }
+        }
+        for _, s := range samples {
+            seen[key(s)] = true
+        }
+        return false
+    })
+    defer srv.Close()
+    var errs []error
+    p := New(srv.URL, func(err error) { errs = append(errs, err) })
+    t0 := time.Unix(1767323040, 0)
+    b := func(i, fail int) Bucket {
+        return Bucket{Start: t0.Add(time.Duration(i) * time.Minute), Secs: 60, Success: 1, Fail: fail}
+    }
+    push := func(bs ...Bucket) {
+        p.Push(p.Pending(bs))
+        p.Close()
+    }
+
+    push(b(0, 0))
+    push(b(0, 1), b(1, 0)) // a late entry in bucket 0, and a new bucket 1
+    push(b(0, 1), b(1, 0), b(2, 0))
+    var starts []string
+    for _, req := range got() {
+        for _, s := range req {
+            if strings.Contains(s, "result=success") {
+                starts = append(starts, s[strings.LastIndex(s, " ")+1:])
+            }
+        }
+    }
+    want := []string{"1767323040000=1", "1767323100000=1", "1767323160000=1"}
+    if strings.Join(starts, " ") != strings.Join(want, " ") {
+        t.Errorf("delivered %q, want %q: the refused re-send held back newer buckets", starts, want)
+    }
+    if len(errs) != 1 {
+        t.Errorf("got errors %v, want the one refused re-send reported", errs)
+    }
+}
@@ -0,0 +1,65 @@
+package remotewrite
+
+import (
+    "encoding/binary"
+    "math"
+)
+
+// The remote-write messages are small and fixed, so they are encoded by
+// hand rather than pulling in protobuf and snappy libraries.
+
+// Protobuf wire types.
+const (
+    wireVarint  = 0
+    wireFixed64 = 1
+    wireBytes   = 2
+)
+
+func appendTag(b []byte, field, wire int) []byte {
+    return binary.AppendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+    b = appendTag(b, field, wireVarint)
+    return binary.AppendUvarint(b, v)
+}
+
+func appendFixed64(b []byte, field int, v float64) []byte {
+    b = appendTag(b, field, wireFixed64)
+    return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+    b = appendTag(b, field, wireBytes)
+    b = binary.AppendUvarint(b, uint64(len(v)))
+    return append(b, v...)
+}
+
+func appendString(b []byte, field int, s string) []byte {
+    return appendBytes(b, field, []byte(s))
+}
+
+// snappyChunk is the longest literal Snappy writes with a two-byte length.
+const snappyChunk = 1 << 16
+
+// Snappy frames src as a Snappy block (the format remote write expects)
+// made only of literals: valid for any decoder, just not smaller. The
+// payloads here are a few KB per push.
+func Snappy(src []byte) []byte {
+    out := binary.AppendUvarint(make([]byte, 0, len(src)+len(src)/snappyChunk*3+8), uint64(len(src)))
+    for len(src) > 0 {
+        n := len(src)
+        if n > snappyChunk { n = snappyChunk }
+        switch {
+        case n <= 60:
+            out = append(out, byte(n-1)<<2)
+        case n <= 256:
+            out = append(out, 60<<2, byte(n-1))
+        default:
+            out = append(out, 61<<2, byte(n-1), byte((n-1)>>8))
+        }
+        out = append(out, src[:n]...)
+        src = src[n:]
+    }
+    return out
+}
@@ -0,0 +1,93 @@
+package replay
+
+import (
+    "bufio"
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "sort"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// maxGap caps the real-time wait between two replayed entries so long quiet
+// stretches in a capture don't stall the replay.
+const maxGap = 5 * time.Second
+
+// Player feeds captured metrics entries back in timestamp order.
+type Player struct {
+    Speed   float64 // 1 = real time, 10 = ten times faster
+    entries []metrics.Entry
+    times   []time.Time
+}
+
+// Load reads every *.jsonl file in dir (or dir itself if it is a file) and
+// orders the entries by ts. Unparseable lines are skipped. A non-nil group
+// sets each entry's Label from its line.
+func Load(dir string, speed float64, group *metrics.GroupBy) (*Player, error) {
+    paths := []string{dir}
+    if fi, err := os.Stat(dir); err != nil {
+        return nil, err
+    } else if fi.IsDir() {
+        paths, _ = filepath.Glob(filepath.Join(dir, "*.jsonl"))
+        sort.Strings(paths)
+    }
+    p := &Player{Speed: speed}
+    for _, path := range paths {
+        f, err := os.Open(path)
+        if err != nil {
+            return nil, err
+        }
+        sc := bufio.NewScanner(f)
+        sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+        for sc.Scan() {
+            var e metrics.Entry
+            if json.Unmarshal(sc.Bytes(), &e) == nil {
+                if group != nil {
+                    e.Label = group.Key(sc.Bytes())
+                }
+                p.entries = append(p.entries, e)
+                p.times = append(p.times, e.Time())
+            }
+        }
+        err = sc.Err()
+        f.Close()
+        if err != nil {
+            return nil, err
+        }
+    }
+    idx := make([]int, len(p.entries))
+    for i := range idx { idx[i] = i }
+    sort.SliceStable(idx, func(i, j int) bool { return p.times[idx[i]].Before(p.times[idx[j]]) })
+    entries := make([]metrics.Entry, len(idx))
+    times := make([]time.Time, len(idx))
+    for i, k := range idx {
+        entries[i], times[i] = p.entries[k], p.times[k]
+    }
+    p.entries, p.times = entries, times
+    return p, nil
+}
+
+func (p *Player) Len() int { return len(p.entries) }
+
+// Run calls fn for each entry, sleeping the scaled ts delta between entries.
+// It returns when the capture is exhausted or stop is closed.
+func (p *Player) Run(stop <-chan struct{}, fn func(e metrics.Entry, ts time.Time)) {
+    speed := p.Speed
+    if speed <= 0 { speed = 1 }
+    for i, e := range p.entries {
+        if i > 0 {
+            gap := time.Duration(float64(p.times[i].Sub(p.times[i-1])) / speed)
+            if gap > maxGap { gap = maxGap }
+            if gap > 0 {
+                select {
+                case <-stop:
+                    return
+                case <-time.After(gap):
+                }
+            }
+        }
+        fn(e, p.times[i])
+    }
+}
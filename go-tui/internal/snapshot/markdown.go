@@ -0,0 +1,113 @@
+package snapshot
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+    "time"
+)
+
+// maxReasons bounds the reasons table in Markdown snapshots.
+const maxReasons = 10
+
+// Markdown renders s as a ticket-ready Markdown document: totals, region and
+// instance tables, top fail reasons and the timeline as a fenced code block.
+func Markdown(w io.Writer, s *Snapshot, timeline string) {
+    fmt.Fprintf(w, "## secmon snapshot %s\n\n", s.Time.Format(time.RFC3339))
+    if s.Label != "" {
+        fmt.Fprintf(w, "**Label** %s\n\n", s.Label)
+    }
+    health := "--"
+    if s.Health >= 0 {
+        health = fmt.Sprintf("%d/100", s.Health)
+    }
+    fmt.Fprintf(w, "**Total** %d · **Success** %d · **Fail** %d · **Rate** %.1f%% · **Health** %s\n", s.Total, s.Success, s.Fail, s.Rate(), health)
+    if t := s.Targets; t != nil {
+        fmt.Fprintf(w, "\n**Targets** %d · **OK** %d (%d after retry) · **Failing** %d", t.Total, t.Succeeded, t.Recovered, t.Failed)
+        if t.Expected > 0 {
+            fmt.Fprintf(w, " · **Progress** %d/%d (%.1f%%)", t.Total, t.Expected, 100*float64(t.Total)/float64(t.Expected))
+        }
+        fmt.Fprintln(w)
+    }
+
+    countsTable(w, "Regions", "Region", s.Regions)
+    countsTable(w, "Instances", "Instance", s.Instances)
+    countsTable(w, "By "+s.GroupBy, "Label", s.Labels)
+
+    if len(s.Reasons) > 0 {
+        type kv struct {
+            key string
+            n   int
+        }
+        reasons := make([]kv, 0, len(s.Reasons))
+        for k, n := range s.Reasons {
+            reasons = append(reasons, kv{k, n})
+        }
+        sort.Slice(reasons, func(i, j int) bool {
+            if reasons[i].n != reasons[j].n {
+                return reasons[i].n > reasons[j].n
+            }
+            return reasons[i].key < reasons[j].key
+        })
+        if len(reasons) > maxReasons { reasons = reasons[:maxReasons] }
+        fmt.Fprintf(w, "\n### Top fail reasons\n\n| Reason | Count |\n| --- | ---: |\n")
+        for _, r := range reasons {
+            fmt.Fprintf(w, "| %s | %d |\n", cell(r.key), r.n)
+        }
+    }
+
+    if len(s.Slowest) > 0 {
+        fmt.Fprintf(w, "\n### Slowest requests\n\n| ms | Time | Instance | Region | URL | Result |\n| ---: | --- | --- | --- | --- | --- |\n")
+        for _, sl := range s.Slowest {
+            result := "ok"
+            if !sl.Success {
+                result = "fail"
+                if sl.Reason != "" { result += ": " + sl.Reason }
+            }
+            fmt.Fprintf(w, "| %d | %s | %s | %s | %s | %s |\n", sl.ElapsedMS, sl.Time.Format("15:04:05"), cell(sl.Instance), cell(sl.Region), cell(sl.URL), cell(result))
+        }
+    }
+
+    if timeline != "" {
+        fmt.Fprintf(w, "\n### Timeline (%ds buckets)\n\n```text\n%s\n```\n", s.Bucket, strings.TrimRight(timeline, "\n"))
+    }
+}
+
+// countsTable writes one success/fail table, worst (most failures) first.
+func countsTable(w io.Writer, title, col string, m map[string]Counts) {
+    if len(m) == 0 {
+        return
+    }
+    keys := make([]string, 0, len(m))
+    for k := range m { keys = append(keys, k) }
+    sort.Slice(keys, func(i, j int) bool {
+        if m[keys[i]].Fail != m[keys[j]].Fail {
+            return m[keys[i]].Fail > m[keys[j]].Fail
+        }
+        return keys[i] < keys[j]
+    })
+    fmt.Fprintf(w, "\n### %s\n\n| %s | Success | Fail | Rate |\n| --- | ---: | ---: | ---: |\n", title, col)
+    for _, k := range keys {
+        c := m[k]
+        rate := "--"
+        if t := c.Success + c.Fail; t > 0 {
+            rate = fmt.Sprintf("%.1f%%", 100*float64(c.Success)/float64(t))
+        }
+        fmt.Fprintf(w, "| %s | %d | %d | %s |\n", cell(k), c.Success, c.Fail, rate)
+    }
+}
+
+// cell escapes text for use inside a Markdown table cell.
+func cell(s string) string {
+    s = strings.ReplaceAll(s, "|", `\|`)
+    return strings.ReplaceAll(s, "\n", " ")
+}
+
+// WriteMarkdown writes Markdown(s, timeline) to path.
+func WriteMarkdown(path string, s *Snapshot, timeline string) error {
+    b := &strings.Builder{}
+    Markdown(b, s, timeline)
+    return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,165 @@
+package snapshot
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// Snapshot is the JSON form of the stats written to snapshot.json each tick.
+type Snapshot struct {
+    Time      time.Time             `json:"time"`
+    Bucket    int                   `json:"bucket_secs"`
+    Total     int                   `json:"total"`
+    Success   int                   `json:"success"`
+    Fail      int                   `json:"fail"`
+    Regions   map[string]Counts     `json:"regions"`
+    Instances map[string]Counts     `json:"instances"`
+    Reasons   map[string]int        `json:"reasons"`
+    Targets   *metrics.TargetCounts `json:"targets,omitempty"`
+    Health    int                   `json:"health"` // 0-100, -1 with no data
+    Slowest   []Slow                `json:"slowest,omitempty"`
+    GroupBy   string                `json:"group_by,omitempty"` // --group-by spec
+    Labels    map[string]Counts     `json:"labels,omitempty"`   // counts per GroupBy key
+    Label     string                `json:"label,omitempty"`    // --label run label
+    Restarts  map[string]int        `json:"restarts,omitempty"` // producer restarts per instance
+
+    SuccessReasons map[string]int `json:"success_reasons,omitempty"` // with --success-reasons
+    SoftFail       *int           `json:"soft_fail,omitempty"`       // successes matching --soft-fail-reasons
+}
+
+// Slow is one of the slowest requests, slowest first in Snapshot.Slowest.
+type Slow struct {
+    Time      time.Time `json:"ts"`
+    ElapsedMS int       `json:"elapsed_ms"`
+    Instance  string    `json:"instance"`
+    Region    string    `json:"region"`
+    URL       string    `json:"url,omitempty"`
+    Success   bool      `json:"success"`
+    Reason    string    `json:"reason,omitempty"`
+}
+
+type Counts struct {
+    Success int `json:"success"`
+    Fail    int `json:"fail"`
+}
+
+func (s *Snapshot) Rate() float64 {
+    if s.Total == 0 {
+        return 0
+    }
+    return 100 * float64(s.Success) / float64(s.Total)
+}
+
+func Load(path string) (*Snapshot, error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var s Snapshot
+    if err := json.Unmarshal(b, &s); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return &s, nil
+}
+
+// Marshal encodes s as written to snapshot.json.
+func Marshal(s *Snapshot) ([]byte, error) {
+    b, err := json.MarshalIndent(s, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return append(b, '\n'), nil
+}
+
+func Write(path string, s *Snapshot) error {
+    b, err := Marshal(s)
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, b, 0o644)
+}
+
+const (
+    ansiRed   = "\x1b[31m"
+    ansiGreen = "\x1b[32m"
+    ansiReset = "\x1b[0m"
+)
+
+// Diff prints the changes from a to b, one fact per line so the output can be
+// grepped. With color, increases in failures are red and improvements green.
+func Diff(w io.Writer, a, b *Snapshot, color bool) {
+    paint := func(s string, good bool) string {
+        if !color {
+            return s
+        }
+        if good {
+            return ansiGreen + s + ansiReset
+        }
+        return ansiRed + s + ansiReset
+    }
+
+    if !a.Time.IsZero() && !b.Time.IsZero() {
+        fmt.Fprintf(w, "interval %s -> %s (%s)\n", a.Time.Format(time.RFC3339), b.Time.Format(time.RFC3339), b.Time.Sub(a.Time).Round(time.Second))
+    }
+    if a.Label != b.Label {
+        fmt.Fprintf(w, "label %q -> %q\n", a.Label, b.Label)
+    }
+    fmt.Fprintf(w, "total %+d (%d -> %d)\n", b.Total-a.Total, a.Total, b.Total)
+    fmt.Fprintf(w, "success %s (%d -> %d)\n", paint(fmt.Sprintf("%+d", b.Success-a.Success), true), a.Success, b.Success)
+    fmt.Fprintf(w, "fail %s (%d -> %d)\n", paint(fmt.Sprintf("%+d", b.Fail-a.Fail), b.Fail <= a.Fail), a.Fail, b.Fail)
+    if a.Health != b.Health {
+        fmt.Fprintf(w, "health %s (%d -> %d)\n", paint(fmt.Sprintf("%+d", b.Health-a.Health), b.Health > a.Health), a.Health, b.Health)
+    }
+    dr := b.Rate() - a.Rate()
+    fmt.Fprintf(w, "rate %s (%.1f%% -> %.1f%%)\n", paint(fmt.Sprintf("%+.1fpp", dr), dr >= 0), a.Rate(), b.Rate())
+
+    for _, k := range unionKeys(a.Regions, b.Regions) {
+        ca, cb := a.Regions[k], b.Regions[k]
+        if df := cb.Fail - ca.Fail; df != 0 {
+            fmt.Fprintf(w, "region %s fail %s (%d -> %d)\n", k, paint(fmt.Sprintf("%+d", df), df < 0), ca.Fail, cb.Fail)
+        }
+        if ds := cb.Success - ca.Success; ds != 0 {
+            fmt.Fprintf(w, "region %s success %+d (%d -> %d)\n", k, ds, ca.Success, cb.Success)
+        }
+    }
+
+    type kv struct {
+        key string
+        n   int
+    }
+    var reasons []kv
+    for k, n := range b.Reasons {
+        if d := n - a.Reasons[k]; d > 0 {
+            reasons = append(reasons, kv{k, d})
+        }
+    }
+    sort.Slice(reasons, func(i, j int) bool {
+        if reasons[i].n != reasons[j].n {
+            return reasons[i].n > reasons[j].n
+        }
+        return reasons[i].key < reasons[j].key
+    })
+    for _, r := range reasons {
+        tag := "reason"
+        if _, ok := a.Reasons[r.key]; !ok {
+            tag = "reason new"
+        }
+        fmt.Fprintf(w, "%s %s %s\n", tag, r.key, paint(fmt.Sprintf("%+d", r.n), false))
+    }
+}
+
+func unionKeys(a, b map[string]Counts) []string {
+    seen := make(map[string]bool, len(a)+len(b))
+    for k := range a { seen[k] = true }
+    for k := range b { seen[k] = true }
+    keys := make([]string, 0, len(seen))
+    for k := range seen { keys = append(keys, k) }
+    sort.Strings(keys)
+    return keys
+}
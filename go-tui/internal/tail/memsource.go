@@ -0,0 +1,136 @@
+package tail
+
+import (
+    "bytes"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "sort"
+    "sync"
+    "time"
+)
+
+// MemSource is an in-memory Source for tests. Each Create makes a new file
+// identity, as rotation by rename-and-recreate would; Append and Truncate
+// change a file in place. Open returns a view of the contents at the time of
+// the call. Safe for concurrent use.
+type MemSource struct {
+    mu    sync.Mutex
+    files map[string]*memFile
+    now   time.Time // mod times: a fixed clock, ticked per write
+    ids   FileID    // last identity handed out
+}
+
+type memFile struct {
+    id   FileID
+    data []byte
+    mod  time.Time
+}
+
+func NewMemSource() *MemSource {
+    return &MemSource{files: make(map[string]*memFile), now: time.Unix(0, 0)}
+}
+
+// touch returns name's file, creating it if missing; call with mu held.
+func (m *MemSource) touch(name string) *memFile {
+    f := m.files[name]
+    if f == nil {
+        m.ids++
+        f = &memFile{id: m.ids}
+        m.files[name] = f
+    }
+    m.now = m.now.Add(time.Second)
+    f.mod = m.now
+    return f
+}
+
+// Create replaces name with a new file (a new identity) holding data.
+func (m *MemSource) Create(name, data string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    delete(m.files, name)
+    m.touch(name).data = []byte(data)
+}
+
+// Append adds data to the end of name, creating it if needed.
+func (m *MemSource) Append(name, data string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    f := m.touch(name)
+    f.data = append(f.data, data...)
+}
+
+// Truncate cuts name to size bytes, keeping its identity.
+func (m *MemSource) Truncate(name string, size int) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    f := m.touch(name)
+    if size < len(f.data) {
+        f.data = f.data[:size]
+    }
+}
+
+// Remove deletes name.
+func (m *MemSource) Remove(name string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    delete(m.files, name)
+}
+
+// Glob matches pattern against the file names with path.Match.
+func (m *MemSource) Glob(pattern string) ([]string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    var out []string
+    for name := range m.files {
+        ok, err := path.Match(pattern, name)
+        if err != nil {
+            return nil, err
+        }
+        if ok {
+            out = append(out, name)
+        }
+    }
+    sort.Strings(out)
+    return out, nil
+}
+
+func (m *MemSource) Open(name string) (io.ReadSeekCloser, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    f := m.files[name]
+    if f == nil {
+        return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+    }
+    return memReader{bytes.NewReader(append([]byte(nil), f.data...))}, nil
+}
+
+func (m *MemSource) Stat(name string) (os.FileInfo, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    f := m.files[name]
+    if f == nil {
+        return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+    }
+    return memInfo{name: path.Base(name), size: int64(len(f.data)), mod: f.mod, id: f.id}, nil
+}
+
+type memReader struct{ *bytes.Reader }
+
+func (memReader) Close() error { return nil }
+
+// memInfo describes a MemSource file; Sys is its identity for SameFile.
+type memInfo struct {
+    name string
+    size int64
+    mod  time.Time
+    id   FileID
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return 0o644 }
+func (i memInfo) ModTime() time.Time { return i.mod }
+func (i memInfo) IsDir() bool        { return false }
+func (i memInfo) Sys() any           { return i.id }
@@ -0,0 +1,100 @@
+package tail
+
+import (
+    "reflect"
+    "testing"
+)
+
+func newMemReader(src *MemSource) *Reader {
+    r := NewReader("logs/*.log")
+    r.Source = src
+    r.stats.Cold = -1 // MemSource writes land at once; don't wait out coldStat
+    return r
+}
+
+// lines returns the text of what ReadNew read.
+func lines(pairs [][2]string) []string {
+    out := make([]string, len(pairs))
+    for i, p := range pairs {
+        out[i] = p[1]
+    }
+    return out
+}
+
+func TestMemReaderRotation(t *testing.T) {
+    src := NewMemSource()
+    r := newMemReader(src)
+    src.Create("logs/a.log", "one\ntwo\n")
+    if got := lines(r.ReadNew()); !reflect.DeepEqual(got, []string{"one", "two"}) {
+        t.Fatalf("first read: got %q", got)
+    }
+    // rotated: a new file under the same name, longer than the old offset
+    src.Create("logs/a.log", "three\nfour\nfive\n")
+    if got := lines(r.ReadNew()); !reflect.DeepEqual(got, []string{"three", "four", "five"}) {
+        t.Fatalf("after rotation: got %q, want the new file from its start", got)
+    }
+}
+
+func TestMemReaderTruncation(t *testing.T) {
+    src := NewMemSource()
+    r := newMemReader(src)
+    src.Create("logs/a.log", "one\ntwo\n")
+    r.ReadNew()
+    // truncated in place and rewritten: the same file, now shorter
+    src.Truncate("logs/a.log", 0)
+    src.Append("logs/a.log", "new\n")
+    for i := 1; i < r.ShrinkPolls; i++ {
+        if got := r.ReadNew(); len(got) != 0 {
+            t.Fatalf("poll %d after truncation: got %q, want it waited out", i, got)
+        }
+    }
+    if got := lines(r.ReadNew()); !reflect.DeepEqual(got, []string{"new"}) {
+        t.Fatalf("after ShrinkPolls polls: got %q, want a re-read from the start", got)
+    }
+}
+
+func TestMemReaderPartialLine(t *testing.T) {
+    src := NewMemSource()
+    r := newMemReader(src)
+    src.Create("logs/a.log", "whole\nhal")
+    if got := lines(r.ReadNew()); !reflect.DeepEqual(got, []string{"whole"}) {
+        t.Fatalf("first read: got %q", got)
+    }
+    src.Append("logs/a.log", "f\n")
+    if got := lines(r.ReadNew()); !reflect.DeepEqual(got, []string{"half"}) {
+        t.Fatalf("second read: got %q, want the completed line", got)
+    }
+}
+
+func TestSameFileMemSource(t *testing.T) {
+    src := NewMemSource()
+    src.Create("a", "x")
+    before, _ := src.Stat("a")
+    src.Append("a", "y")
+    appended, _ := src.Stat("a")
+    src.Create("a", "z")
+    recreated, _ := src.Stat("a")
+    if !SameFile(before, appended) {
+        t.Error("an append changed the file's identity")
+    }
+    if SameFile(before, recreated) {
+        t.Error("recreating the file kept its identity")
+    }
+}
+
+func TestMemReaderBriefShrink(t *testing.T) {
+    src := NewMemSource()
+    r := newMemReader(src)
+    src.Create("logs/a.log", "one\ntwo\n")
+    r.ReadNew()
+    // a filesystem reports the file shorter for one poll mid-write...
+    src.Truncate("logs/a.log", 4)
+    if got := r.ReadNew(); len(got) != 0 {
+        t.Fatalf("during the dip: got %q, want it waited out", got)
+    }
+    // ...then it is back, with more written
+    src.Append("logs/a.log", "two\nthree\n")
+    if got := lines(r.ReadNew()); !reflect.DeepEqual(got, []string{"three"}) {
+        t.Fatalf("after recovery: got %q, want only the new line", got)
+    }
+}
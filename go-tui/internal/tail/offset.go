@@ -0,0 +1,99 @@
+package tail
+
+import (
+    "hash/fnv"
+    "io"
+    "os"
+)
+
+// headBytes is how much of a file's start Offset.Head fingerprints.
+const headBytes = 256
+
+// Offset is a file's read position together with the identity of the file
+// it was read from, so it can be saved and resumed across restarts.
+type Offset struct {
+    Pos  int64  `json:"pos"`
+    Dev  uint64 `json:"dev"`
+    Ino  uint64 `json:"ino"`
+    Head uint64 `json:"head"` // hash of the first bytes; inodes get reused
+}
+
+// Resumable reports whether reading path (described by fi) from src can
+// continue from o: same file, not rotated since, and not truncated below the
+// offset.
+func (o Offset) Resumable(src Source, path string, fi os.FileInfo) bool {
+    dev, ino, ok := fileID(fi)
+    if !ok || dev != o.Dev || ino != o.Ino || fi.Size() < o.Pos {
+        return false
+    }
+    head, err := headHash(src, path, o.Pos)
+    return err == nil && head == o.Head
+}
+
+// headHash hashes the first min(n, headBytes) bytes of path: a file deleted
+// and recreated at the same inode still differs there (timestamps, at
+// least).
+func headHash(src Source, path string, n int64) (uint64, error) {
+    if n > headBytes {
+        n = headBytes
+    }
+    f, err := src.Open(path)
+    if err != nil {
+        return 0, err
+    }
+    defer f.Close()
+    h := fnv.New64a()
+    if _, err := io.CopyN(h, f, n); err != nil {
+        return 0, err
+    }
+    return h.Sum64(), nil
+}
+
+// Offsets returns the read position of every regular file read so far.
+func (r *Reader) Offsets() map[string]Offset {
+    return Offsets(r.Source, r.pos, r.info)
+}
+
+// Resume starts each file in saved from its offset if it is still the same
+// file, returning how many did; the rest are read from the start as usual.
+// Call before the first ReadNew.
+func (r *Reader) Resume(saved map[string]Offset) int {
+    return Resume(r.Source, saved, r.pos, r.info)
+}
+
+// Offsets builds Offsets from a reader's position and file info maps (shared
+// with metrics.Aggregator, which keeps the same two). Files whose identity
+// the platform can't report are left out.
+func Offsets(src Source, pos map[string]int64, info map[string]os.FileInfo) map[string]Offset {
+    out := make(map[string]Offset, len(pos))
+    for p, n := range pos {
+        fi, ok := info[p]
+        if !ok {
+            continue
+        }
+        dev, ino, ok := fileID(fi)
+        if !ok {
+            continue
+        }
+        if head, err := headHash(src, p, n); err == nil {
+            out[p] = Offset{Pos: n, Dev: dev, Ino: ino, Head: head}
+        }
+    }
+    return out
+}
+
+// Resume fills a reader's position and file info maps from saved, for the
+// files that are still resumable.
+func Resume(src Source, saved map[string]Offset, pos map[string]int64, info map[string]os.FileInfo) int {
+    n := 0
+    for p, o := range saved {
+        fi, err := src.Stat(p)
+        if err != nil || IsPipe(fi) || !o.Resumable(src, p, fi) {
+            continue
+        }
+        pos[p] = o.Pos
+        info[p] = fi
+        n++
+    }
+    return n
+}
@@ -0,0 +1,86 @@
+package tail
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "os"
+    "syscall"
+    "time"
+)
+
+// pipeWait is how long a Pipe read waits for more data before returning
+// what it has.
+const pipeWait = 5 * time.Millisecond
+
+// IsPipe reports whether fi is a named pipe (FIFO), which Pipe streams
+// instead of seeking.
+func IsPipe(fi os.FileInfo) bool {
+    return fi.Mode()&os.ModeNamedPipe != 0
+}
+
+// Pipe streams lines from a named pipe, so `producer > fifo` can feed a
+// reader directly. A pipe can't seek, so there is no offset: each call
+// consumes whatever the writer has sent, keeping a trailing partial line
+// until its newline arrives. The read end is opened non-blocking and kept
+// open, so a writer that closes and a new one that connects (the producer
+// restarting) just continue the stream.
+type Pipe struct {
+    Path string
+    f    *os.File
+    part []byte
+}
+
+// ReadLines returns the complete lines (newline included) available now. It
+// never waits for a writer to connect.
+func (p *Pipe) ReadLines() ([][]byte, error) {
+    if p.f == nil {
+        // Without O_NONBLOCK, opening a FIFO for reading blocks until a
+        // writer opens it.
+        f, err := os.OpenFile(p.Path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+        if err != nil {
+            return nil, err
+        }
+        p.f = f
+    }
+    // The runtime polls FIFOs, so a deadline turns "no data yet" into an
+    // error instead of a blocked read.
+    if err := p.f.SetReadDeadline(time.Now().Add(pipeWait)); err != nil {
+        p.Close()
+        return nil, err
+    }
+    data := p.part
+    buf := make([]byte, 64*1024)
+    var rerr error
+    for {
+        n, err := p.f.Read(buf)
+        data = append(data, buf[:n]...)
+        if err != nil {
+            // EOF: no writer at the moment; a new one continues the stream.
+            if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrDeadlineExceeded) {
+                rerr = err
+                p.Close()
+            }
+            break
+        }
+    }
+    var lines [][]byte
+    for {
+        i := bytes.IndexByte(data, '\n')
+        if i < 0 {
+            break
+        }
+        lines = append(lines, data[:i+1])
+        data = data[i+1:]
+    }
+    p.part = append([]byte(nil), data...)
+    return lines, rerr
+}
+
+// Close closes the read end; the next ReadLines reopens it.
+func (p *Pipe) Close() {
+    if p.f != nil {
+        p.f.Close()
+        p.f = nil
+    }
+}
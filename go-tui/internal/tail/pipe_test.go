@@ -0,0 +1,68 @@
+//go:build unix
+
+package tail
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "syscall"
+    "testing"
+)
+
+// readPipeLines returns what one ReadLines call got, as strings.
+func readPipeLines(t *testing.T, p *Pipe) []string {
+    t.Helper()
+    got, err := p.ReadLines()
+    if err != nil {
+        t.Fatal(err)
+    }
+    out := []string{}
+    for _, l := range got {
+        out = append(out, string(l))
+    }
+    return out
+}
+
+func TestPipePartialLine(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "metrics.fifo")
+    if err := syscall.Mkfifo(path, 0o600); err != nil {
+        t.Skip("mkfifo:", err)
+    }
+    p := &Pipe{Path: path}
+    defer p.Close()
+    // no writer yet: nothing, and no waiting for one
+    if got := readPipeLines(t, p); len(got) != 0 {
+        t.Fatalf("before a writer: got %q", got)
+    }
+    w, err := os.OpenFile(path, os.O_WRONLY, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    w.WriteString("hal")
+    if got := readPipeLines(t, p); len(got) != 0 {
+        t.Fatalf("after a partial line: got %q, want it held back", got)
+    }
+    w.WriteString("f\n")
+    if got := readPipeLines(t, p); !reflect.DeepEqual(got, []string{"half\n"}) {
+        t.Fatalf("after the rest: got %q, want the line once", got)
+    }
+    if got := readPipeLines(t, p); len(got) != 0 {
+        t.Fatalf("next read: got %q, want nothing", got)
+    }
+
+    // the producer restarts: its writer closes and a new one connects
+    w.Close()
+    if got := readPipeLines(t, p); len(got) != 0 {
+        t.Fatalf("with no writer: got %q", got)
+    }
+    w, err = os.OpenFile(path, os.O_WRONLY, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer w.Close()
+    w.WriteString("next\n")
+    if got := readPipeLines(t, p); !reflect.DeepEqual(got, []string{"next\n"}) {
+        t.Fatalf("from the new writer: got %q", got)
+    }
+}
@@ -0,0 +1,41 @@
+package tail
+
+import (
+    "io"
+    "os"
+    "path/filepath"
+)
+
+// Source is the filesystem a Reader (or metrics.Aggregator) finds and reads
+// files through. OS is the real one; MemSource keeps files in memory so
+// rotation, truncation and partial lines can be exercised without a disk.
+type Source interface {
+    Glob(pattern string) ([]string, error)
+    Open(name string) (io.ReadSeekCloser, error)
+    Stat(name string) (os.FileInfo, error)
+}
+
+// OS is the default Source: filepath.Glob, os.Open and os.Stat.
+var OS Source = osSource{}
+
+type osSource struct{}
+
+func (osSource) Glob(pattern string) ([]string, error) { return filepath.Glob(pattern) }
+func (osSource) Open(name string) (io.ReadSeekCloser, error) { return os.Open(name) }
+func (osSource) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+// FileID is what a Source other than OS returns from FileInfo.Sys to
+// identify a file, as an inode does: unchanged by writes and truncation,
+// new when the path is recreated.
+type FileID uint64
+
+// SameFile reports whether a and b describe the same file, for the rotation
+// check: equal FileIDs when the Source provides them, else os.SameFile.
+func SameFile(a, b os.FileInfo) bool {
+    ia, ok1 := a.Sys().(FileID)
+    ib, ok2 := b.Sys().(FileID)
+    if ok1 || ok2 {
+        return ok1 && ok2 && ia == ib
+    }
+    return os.SameFile(a, b)
+}
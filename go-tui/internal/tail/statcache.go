@@ -0,0 +1,83 @@
+package tail
+
+import (
+    "os"
+    "time"
+)
+
+// coldStat is how long a file the last poll didn't read (left out by
+// MaxFiles or Group) keeps its cached stat by default. It only matters again
+// once it is written to, so a glob matching thousands of old files costs a
+// stat each this often rather than every poll.
+const coldStat = 5 * time.Second
+
+// StatCache is the Source a poll stats files through; Glob and Open go
+// straight to the Source it wraps. A stat is reused for the rest of the
+// poll, so Latest, Newest and the read itself share one per file.
+type StatCache struct {
+    Source
+
+    // Cold is how long the stat of a file the last poll didn't read is
+    // reused: coldStat if 0, only within the poll if negative.
+    Cold time.Duration
+
+    poll  time.Time
+    stats map[string]cachedStat
+}
+
+type cachedStat struct {
+    fi  os.FileInfo
+    err error
+    at  time.Time
+}
+
+// Begin starts a poll at now over src. The files the last poll read, and
+// stats older than Cold, are stated again.
+func (c *StatCache) Begin(src Source, now time.Time, read []string) {
+    c.Source, c.poll = src, now
+    if c.stats == nil {
+        c.stats = make(map[string]cachedStat)
+    }
+    for _, p := range read {
+        delete(c.stats, p)
+    }
+    cold := c.Cold
+    if cold == 0 {
+        cold = coldStat
+    }
+    for p, s := range c.stats {
+        if now.Sub(s.at) >= cold {
+            delete(c.stats, p)
+        }
+    }
+}
+
+// Stat returns path's cached stat, stating it if there is none.
+func (c *StatCache) Stat(path string) (os.FileInfo, error) {
+    if s, ok := c.stats[path]; ok {
+        return s.fi, s.err
+    }
+    fi, err := c.Source.Stat(path)
+    c.stats[path] = cachedStat{fi, err, c.poll}
+    return fi, err
+}
+
+// Fresh is Stat, but never older than this poll: for a file about to be
+// read.
+func (c *StatCache) Fresh(path string) (os.FileInfo, error) {
+    if s, ok := c.stats[path]; ok && !s.at.Equal(c.poll) {
+        delete(c.stats, path)
+    }
+    return c.Stat(path)
+}
+
+// Prune drops the stats of files the glob no longer matches.
+func (c *StatCache) Prune(matches []string) {
+    kept := make(map[string]bool, len(matches))
+    for _, p := range matches { kept[p] = true }
+    for p := range c.stats {
+        if !kept[p] {
+            delete(c.stats, p)
+        }
+    }
+}
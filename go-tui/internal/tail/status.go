@@ -0,0 +1,95 @@
+package tail
+
+import (
+    "os"
+    "sort"
+    "time"
+)
+
+// FileStatus is what a Reader knows about one file it is tailing.
+type FileStatus struct {
+    Path     string
+    Seen     time.Time // first matched
+    LastRead time.Time // last poll that read new lines; zero if none yet
+    Behind   int64     // bytes past the read offset at the last poll (a partial line, or unread after an error)
+    Rotated  time.Time // last found rotated or truncated and re-read from the start; zero if never
+    Err      error     // why the last poll couldn't read it; nil once it reads again
+    Pipe     bool
+}
+
+// FileHealth classifies a FileStatus for display.
+type FileHealth int
+
+const (
+    FileActive FileHealth = iota
+    FileStalled            // nothing new for the stall window
+    FileRotated            // rotated or truncated within the stall window
+    FileError
+)
+
+var fileHealthNames = [...]string{"active", "stalled", "rotated", "error"}
+
+func (h FileHealth) String() string { return fileHealthNames[h] }
+
+// Health classifies s at now: an error wins, then a rotation within stall,
+// then stalled (no new lines for stall, counted from when the file was
+// first seen if it never had any). stall <= 0 never reports stalled.
+func (s FileStatus) Health(now time.Time, stall time.Duration) FileHealth {
+    switch {
+    case s.Err != nil:
+        return FileError
+    case stall > 0 && !s.Rotated.IsZero() && now.Sub(s.Rotated) < stall:
+        return FileRotated
+    }
+    last := s.LastRead
+    if last.IsZero() {
+        last = s.Seen
+    }
+    if stall > 0 && now.Sub(last) >= stall {
+        return FileStalled
+    }
+    return FileActive
+}
+
+// Status returns the status of each file the last poll matched (after
+// MaxFiles and Group), sorted by path. Don't call it concurrently with
+// ReadNew.
+func (r *Reader) Status() []FileStatus {
+    out := make([]FileStatus, 0, len(r.status))
+    for _, s := range r.status {
+        out = append(out, *s)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+    return out
+}
+
+// fileStatus returns path's status entry, creating it first seen at now.
+func (r *Reader) fileStatus(path string, now time.Time) *FileStatus {
+    s := r.status[path]
+    if s == nil {
+        s = &FileStatus{Path: path, Seen: now}
+        r.status[path] = s
+    }
+    return s
+}
+
+// noteRead updates path's status after a poll that got as far as pos of fi.
+func (r *Reader) noteRead(path string, fi os.FileInfo, pos int64, read bool, now time.Time) {
+    s := r.fileStatus(path, now)
+    s.Err = nil
+    s.Behind = max(fi.Size()-pos, 0)
+    if read {
+        s.LastRead = now
+    }
+}
+
+// pruneStatus drops the status of files the last poll didn't read.
+func (r *Reader) pruneStatus(matches []string) {
+    kept := make(map[string]bool, len(matches))
+    for _, p := range matches { kept[p] = true }
+    for p := range r.status {
+        if !kept[p] {
+            delete(r.status, p)
+        }
+    }
+}
@@ -2,71 +2,260 @@ package tail
 
 import (
     "bufio"
+    "encoding/json"
     "io"
     "os"
-    "path/filepath"
     "sort"
+    "strings"
+    "time"
 )
 
-// Reader tails files matching a glob pattern by polling.
+// Reader tails files matching a glob pattern by polling. Named pipes are
+// streamed (see Pipe) rather than read by offset.
 type Reader struct {
     Pattern string
     pos     map[string]int64
+    info    map[string]os.FileInfo
+    shrunk  map[string]int // consecutive polls a file has been below its offset
+    pipes   map[string]*Pipe
+    status  map[string]*FileStatus // see Status
+    stats   StatCache
+    read    []string // files the last poll read
+
+    // ShrinkPolls is how many consecutive polls a file must stay smaller
+    // than the read offset before it's treated as truncated; a shorter dip
+    // (seen on some filesystems mid-write) is waited out. A changed file
+    // identity (new inode) is treated as rotation at once.
+    ShrinkPolls int
+
+    // MaxFiles, if set, limits each poll to the MaxFiles most recently
+    // modified matches; Ignored is how many the last poll skipped.
+    MaxFiles int
+    Ignored  int
+
+    // Matched is how many files the last poll's glob matched.
+    Matched int
+
+    // Source is where files are globbed, stated and opened; OS by default.
+    Source Source
+
+    // Group, if set, keys matches by instance: only the newest file of each
+    // group is read (see Latest), so rotated backups are skipped.
+    Group func(path string) string
 }
 
 func NewReader(pattern string) *Reader {
-    return &Reader{Pattern: pattern, pos: make(map[string]int64)}
+    return &Reader{Pattern: pattern, pos: make(map[string]int64), info: make(map[string]os.FileInfo), shrunk: make(map[string]int), pipes: make(map[string]*Pipe), status: make(map[string]*FileStatus), ShrinkPolls: 2, Source: OS}
+}
+
+// Rotated reports whether path, described by fi, must be re-read from the
+// start rather than from cur: its identity changed since the last call
+// (rotation), or it has stayed below cur for shrinkPolls calls in a row
+// (truncation; a shorter dip, seen on some filesystems mid-write, is waited
+// out). info and shrunk are the caller's per-file state, shared by Reader
+// and metrics.Aggregator; Rotated records fi in info.
+func Rotated(info map[string]os.FileInfo, shrunk map[string]int, shrinkPolls int, path string, fi os.FileInfo, cur int64) bool {
+    prev, ok := info[path]
+    info[path] = fi
+    if ok && !SameFile(prev, fi) {
+        delete(shrunk, path)
+        return true
+    }
+    if fi.Size() >= cur {
+        delete(shrunk, path)
+        return false
+    }
+    shrunk[path]++
+    if shrunk[path] < shrinkPolls {
+        return false
+    }
+    delete(shrunk, path)
+    return true
 }
 
 // ReadNew reads and returns new lines appended since last call.
 func (r *Reader) ReadNew() [][2]string {
     out := make([][2]string, 0, 128)
-    matches, _ := filepath.Glob(r.Pattern)
-    sort.Strings(matches)
+    now := time.Now()
+    matches, _ := r.Source.Glob(r.Pattern)
+    r.Matched = len(matches)
+    r.stats.Begin(r.Source, now, r.read)
+    r.stats.Prune(matches)
+    all := matches
+    if r.Group != nil {
+        matches, _ = Latest(&r.stats, matches, r.Group)
+    }
+    matches, r.Ignored = Newest(&r.stats, matches, r.MaxFiles)
+    r.forget(all, matches)
+    r.read = matches
+    r.pruneStatus(matches)
     for _, path := range matches {
-        fi, err := os.Stat(path)
+        fi, err := r.stats.Fresh(path)
         if err != nil {
             delete(r.pos, path)
+            delete(r.info, path)
+            delete(r.shrunk, path)
+            r.closePipe(path)
+            r.fileStatus(path, now).Err = err
+            continue
+        }
+        if IsPipe(fi) {
+            lines, err := r.readPipe(path)
+            for _, line := range lines {
+                out = append(out, [2]string{path, trimNewline(line)})
+            }
+            s := r.fileStatus(path, now)
+            s.Pipe, s.Err = true, err
+            if len(lines) > 0 {
+                s.LastRead = now
+            }
             continue
         }
         size := fi.Size()
         cur := r.pos[path]
-        if size < cur {
-            // rotated/truncated
+        if Rotated(r.info, r.shrunk, r.ShrinkPolls, path, fi, cur) {
             cur = 0
+            r.fileStatus(path, now).Rotated = now
+        } else if size < cur {
+            continue // possibly a transient shrink; check again next poll
         }
         if size == cur {
             r.pos[path] = size
+            r.noteRead(path, fi, size, false, now)
             continue
         }
-        f, err := os.Open(path)
+        f, err := r.Source.Open(path)
         if err != nil {
+            r.fileStatus(path, now).Err = err
             continue
         }
         if _, err := f.Seek(cur, io.SeekStart); err != nil {
             f.Close()
+            r.fileStatus(path, now).Err = err
             continue
         }
+        // Only complete lines are consumed: pos stops after the last newline,
+        // so a line still being written (or cut short by a read error) is
+        // read again in full on the next call.
         br := bufio.NewReader(f)
+        pos := cur
         for {
             line, err := br.ReadString('\n')
-            if len(line) > 0 {
-                out = append(out, [2]string{path, trimNewline(line)})
-            }
             if err != nil {
-                if err == io.EOF {
-                    break
-                }
                 break
             }
+            pos += int64(len(line))
+            out = append(out, [2]string{path, trimNewline(line)})
         }
-        pos, _ := f.Seek(0, io.SeekCurrent)
         r.pos[path] = pos
         f.Close()
+        r.noteRead(path, fi, pos, pos > cur, now)
     }
     return out
 }
 
+// forget drops the state of files the glob no longer matches, so a runaway
+// glob doesn't grow the maps. Files that still match but are outside keep
+// (MaxFiles, Group) keep their offsets: one that becomes one of the newest
+// again carries on where it left off instead of being read from the start.
+// Their pipes are closed; a pipe has no offset to come back to.
+func (r *Reader) forget(all, keep []string) {
+    matched := make(map[string]bool, len(all))
+    for _, p := range all { matched[p] = true }
+    kept := make(map[string]bool, len(keep))
+    for _, p := range keep { kept[p] = true }
+    for p := range r.pos {
+        if !matched[p] {
+            delete(r.pos, p)
+            delete(r.info, p)
+            delete(r.shrunk, p)
+        }
+    }
+    for p := range r.pipes {
+        if !kept[p] {
+            r.closePipe(p)
+        }
+    }
+}
+
+// readPipe returns the complete lines a named pipe has ready, and the error
+// that stopped the read, if any.
+func (r *Reader) readPipe(path string) ([]string, error) {
+    p := r.pipes[path]
+    if p == nil {
+        p = &Pipe{Path: path}
+        r.pipes[path] = p
+    }
+    lines, err := p.ReadLines()
+    out := make([]string, len(lines))
+    for i, l := range lines {
+        out[i] = string(l)
+    }
+    return out, err
+}
+
+func (r *Reader) closePipe(path string) {
+    if p := r.pipes[path]; p != nil {
+        p.Close()
+        delete(r.pipes, path)
+    }
+}
+
+// Newest returns the n most recently modified of paths (as src reports
+// them), sorted by name, and how many were left out; all of them (sorted) if
+// n <= 0 or there are no more than n. Paths that can't be stated count as
+// oldest.
+func Newest(src Source, paths []string, n int) ([]string, int) {
+    if n <= 0 || len(paths) <= n {
+        sort.Strings(paths)
+        return paths, 0
+    }
+    mtime := make(map[string]time.Time, len(paths))
+    for _, p := range paths {
+        if fi, err := src.Stat(p); err == nil {
+            mtime[p] = fi.ModTime()
+        }
+    }
+    sort.Slice(paths, func(i, j int) bool {
+        ti, tj := mtime[paths[i]], mtime[paths[j]]
+        if !ti.Equal(tj) {
+            return ti.After(tj)
+        }
+        return paths[i] < paths[j]
+    })
+    keep := paths[:n]
+    sort.Strings(keep)
+    return keep, len(paths) - n
+}
+
+// Latest keeps the most recently modified of each group of paths sharing a
+// key (the greater name on a tie), sorted by name, and returns how many were
+// left out. Paths that can't be stated count as oldest.
+func Latest(src Source, paths []string, key func(string) string) ([]string, int) {
+    type pick struct {
+        path string
+        mod  time.Time
+    }
+    best := make(map[string]pick)
+    for _, p := range paths {
+        var mod time.Time
+        if fi, err := src.Stat(p); err == nil {
+            mod = fi.ModTime()
+        }
+        k := key(p)
+        if b, ok := best[k]; ok && (mod.Before(b.mod) || mod.Equal(b.mod) && p < b.path) {
+            continue
+        }
+        best[k] = pick{p, mod}
+    }
+    out := make([]string, 0, len(best))
+    for _, b := range best {
+        out = append(out, b.path)
+    }
+    sort.Strings(out)
+    return out, len(paths) - len(out)
+}
+
 func trimNewline(s string) string {
     if len(s) == 0 {
         return s
@@ -79,3 +268,62 @@ func trimNewline(s string) string {
     }
     return s
 }
+
+var lineTimeLayouts = []string{
+    time.RFC3339Nano,
+    "2006-01-02T15:04:05.999999999",
+    "2006-01-02 15:04:05.999999999",
+    "2006/01/02 15:04:05.999999999",
+}
+
+// LineTime extracts a leading timestamp from a log line, or the "ts" field if
+// the line is a JSON object. Brackets around the timestamp are ignored.
+func LineTime(line string) (time.Time, bool) {
+    s := strings.TrimLeft(line, "[ \t")
+    if strings.HasPrefix(s, "{") {
+        var v struct {
+            TS string `json:"ts"`
+        }
+        if json.Unmarshal([]byte(s), &v) != nil || v.TS == "" {
+            return time.Time{}, false
+        }
+        s = v.TS
+    }
+    fields := strings.Fields(s)
+    if len(fields) == 0 {
+        return time.Time{}, false
+    }
+    cands := []string{strings.TrimRight(fields[0], "],")}
+    if len(fields) > 1 {
+        cands = append(cands, fields[0]+" "+strings.TrimRight(fields[1], "],"))
+    }
+    for _, c := range cands {
+        for _, layout := range lineTimeLayouts {
+            if t, err := time.Parse(layout, c); err == nil {
+                return t, true
+            }
+        }
+    }
+    return time.Time{}, false
+}
+
+// Interleave stably sorts one batch of ReadNew output by line timestamp so
+// lines from different files appear in global time order. A line without a
+// timestamp inherits the last timestamp seen in its file, keeping it next to
+// its neighbours; lines before any timestamp keep their arrival order.
+func Interleave(lines [][2]string) [][2]string {
+    keys := make([]time.Time, len(lines))
+    last := make(map[string]time.Time)
+    for i, l := range lines {
+        if t, ok := LineTime(l[1]); ok {
+            last[l[0]] = t
+        }
+        keys[i] = last[l[0]]
+    }
+    idx := make([]int, len(lines))
+    for i := range idx { idx[i] = i }
+    sort.SliceStable(idx, func(i, j int) bool { return keys[idx[i]].Before(keys[idx[j]]) })
+    out := make([][2]string, len(lines))
+    for i, k := range idx { out[i] = lines[k] }
+    return out
+}
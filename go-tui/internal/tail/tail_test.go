@@ -0,0 +1,36 @@
+package tail
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestReadNewSplitWrite(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "a.log")
+    f, err := os.Create(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    r := NewReader(filepath.Join(dir, "*.log"))
+
+    // the producer writes one line in two write calls, a tick apart
+    if _, err := f.WriteString(`{"success":tr`); err != nil {
+        t.Fatal(err)
+    }
+    if got := r.ReadNew(); len(got) != 0 {
+        t.Fatalf("after the first half: got %q, want nothing", got)
+    }
+    if _, err := f.WriteString("ue}\n"); err != nil {
+        t.Fatal(err)
+    }
+    got := r.ReadNew()
+    if len(got) != 1 || got[0][1] != `{"success":true}` {
+        t.Fatalf("after the second half: got %q, want the whole line once", got)
+    }
+    if got := r.ReadNew(); len(got) != 0 {
+        t.Fatalf("next tick: got %q, want nothing", got)
+    }
+}
@@ -0,0 +1,109 @@
+package tail
+
+import (
+    "fmt"
+    "path/filepath"
+
+    "github.com/fsnotify/fsnotify"
+)
+
+// Watcher signals on C when a file matching one of its patterns is written,
+// created or replaced, so callers can read right away instead of on the next
+// poll. Signals are coalesced: C holds at most one pending notification.
+type Watcher struct {
+    C        <-chan struct{}
+    w        *fsnotify.Watcher
+    patterns []string
+}
+
+// Watch watches the directories holding the files that currently match
+// patterns. It fails when more than maxFiles files match (polling copes
+// better with large globs) or the OS watcher can't be created; callers then
+// keep polling.
+func Watch(patterns []string, maxFiles int) (*Watcher, error) {
+    dirs := map[string]bool{}
+    var clean []string
+    n := 0
+    for _, p := range patterns {
+        if p == "" {
+            continue
+        }
+        clean = append(clean, absPath(p))
+        matches, err := filepath.Glob(p)
+        if err != nil {
+            return nil, err
+        }
+        n += len(matches)
+        for _, m := range matches {
+            dirs[filepath.Dir(m)] = true
+        }
+        if len(matches) == 0 {
+            dirs[filepath.Dir(p)] = true // files may appear later
+        }
+    }
+    if n > maxFiles {
+        return nil, fmt.Errorf("%d files match, more than %d", n, maxFiles)
+    }
+    fw, err := fsnotify.NewWatcher()
+    if err != nil {
+        return nil, err
+    }
+    for d := range dirs {
+        if err := fw.Add(d); err != nil {
+            fw.Close()
+            return nil, fmt.Errorf("watch %s: %w", d, err)
+        }
+    }
+    c := make(chan struct{}, 1)
+    w := &Watcher{C: c, w: fw, patterns: clean}
+    go w.run(c)
+    return w, nil
+}
+
+func (w *Watcher) run(c chan struct{}) {
+    for {
+        select {
+        case ev, ok := <-w.w.Events:
+            if !ok {
+                return
+            }
+            if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 || !w.matches(ev.Name) {
+                continue
+            }
+            select {
+            case c <- struct{}{}:
+            default:
+            }
+        case _, ok := <-w.w.Errors:
+            if !ok {
+                return
+            }
+        }
+    }
+}
+
+// matches reports whether an event path matches a pattern. Both sides are
+// cleaned and made absolute first: fsnotify names events after the watched
+// directory, which Glob has already cleaned ("./m/*.log" watches "m").
+func (w *Watcher) matches(name string) bool {
+    name = absPath(name)
+    for _, p := range w.patterns {
+        if ok, _ := filepath.Match(p, name); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// absPath is p made absolute, or just cleaned if the working directory is
+// unknown.
+func absPath(p string) string {
+    if a, err := filepath.Abs(p); err == nil {
+        return a
+    }
+    return filepath.Clean(p)
+}
+
+func (w *Watcher) Close() error {
+    return w.w.Close()
+}
@@ -0,0 +1,36 @@
+package tail
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestWatcherMatchesUncleanPattern(t *testing.T) {
+    dir := t.TempDir()
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(dir); err != nil {
+        t.Fatal(err)
+    }
+    defer os.Chdir(wd)
+    if err := os.Mkdir("m", 0o755); err != nil {
+        t.Fatal(err)
+    }
+    w, err := Watch([]string{"./m/*.jsonl"}, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer w.Close()
+    // fsnotify names events after the watched directory, "m"
+    for _, name := range []string{"m/a.jsonl", filepath.Join(dir, "m", "a.jsonl")} {
+        if !w.matches(name) {
+            t.Errorf("%q didn't match ./m/*.jsonl", name)
+        }
+    }
+    if w.matches("m/a.log") {
+        t.Error("m/a.log matched ./m/*.jsonl")
+    }
+}
@@ -0,0 +1,153 @@
+package ui
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+
+    "secmon/internal/events"
+    "secmon/internal/metrics"
+)
+
+// maxMarks bounds the notable events kept for timeline annotations.
+const maxMarks = 200
+
+// mark is a notable event (alert, IP rotation, producer restart) drawn as a
+// '^' under the timeline column of its bucket, or an anomalous bucket drawn
+// as a '!' (which wins when a column has both).
+type mark struct {
+    at      time.Time
+    label   string
+    anomaly bool
+}
+
+// addMark records a notable event at wall time now; safe from any goroutine.
+func (a *App) addMark(label string) {
+    a.pushMark(mark{at: time.Now(), label: label})
+}
+
+// addAnomaly marks the bucket starting at start as an outlier.
+func (a *App) addAnomaly(start time.Time, label string) {
+    a.pushMark(mark{at: start, label: label, anomaly: true})
+}
+
+func (a *App) pushMark(m mark) {
+    a.mu.Lock()
+    a.marks = append(a.marks, m)
+    if len(a.marks) > maxMarks {
+        a.marks = a.marks[len(a.marks)-maxMarks:]
+    }
+    a.mu.Unlock()
+    a.touch()
+}
+
+// marksIn returns the labels of marks in [start, start+d), prefixed with their
+// time of day, and whether any of them is an anomaly.
+func (a *App) marksIn(start time.Time, d time.Duration) ([]string, bool) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    var out []string
+    anomaly := false
+    for _, m := range a.marks {
+        if !m.at.Before(start) && m.at.Before(start.Add(d)) {
+            out = append(out, m.at.Format("15:04:05")+" "+m.label)
+            anomaly = anomaly || m.anomaly
+        }
+    }
+    return out, anomaly
+}
+
+// markRow renders the annotation row for the displayed buckets and the labels
+// of every mark it shows; "" when none of them has a mark.
+func (a *App) markRow(data []metrics.Bucket, bucketSecs int) (string, []string) {
+    step := time.Duration(bucketSecs) * time.Second
+    row := make([]rune, len(data))
+    var labels []string
+    for i, p := range data {
+        row[i] = ' '
+        if ls, anomaly := a.marksIn(p.Start, step); len(ls) > 0 {
+            row[i] = '^'
+            if anomaly {
+                row[i] = '!'
+            }
+            labels = append(labels, ls...)
+        }
+    }
+    if labels == nil {
+        return "", nil
+    }
+    return string(row), labels
+}
+
+// markRestart records a producer restart when a log line matches
+// --restart-marker: a timeline mark, an event-log entry and a count against
+// the instance (named by logInstance, as attempt-counter restarts and the i
+// view name it; id is the line's instance_id, if known).
+func (a *App) markRestart(path, id, line string) {
+    if a.cfg.RestartMark == nil || !a.cfg.RestartMark.MatchString(line) {
+        return
+    }
+    name := a.logInstance(path, id)
+    a.addMark("restart " + name)
+    a.events.Emit(events.Restart, map[string]any{"instance": name, "source": "marker"})
+    if a.agg != nil {
+        a.agg.CountRestart(name)
+    }
+}
+
+// markAttemptRestarts records the restarts the aggregator spotted from
+// attempt counters (--attempt-restarts), marked at the entry's ts.
+func (a *App) markAttemptRestarts() {
+    for _, r := range a.agg.TakeRestarts() {
+        a.pushMark(mark{at: r.At, label: fmt.Sprintf("restart %s (attempt %d -> %d)", r.Instance, r.Prev, r.Attempt)})
+        a.events.Emit(events.Restart, map[string]any{"instance": r.Instance, "source": "attempt", "attempt": r.Attempt, "prev_attempt": r.Prev, "entry_ts": r.At.UTC().Format(time.RFC3339)})
+    }
+}
+
+// restartText summarizes restarts for the stats panel, busiest instances
+// first, e.g. "Restarts: 5 (worker-3 x3, worker-1 x2)"; "" with none.
+func restartText(restarts map[string]int, num func(int) string) string {
+    total := 0
+    rows := make([]countRow, 0, len(restarts))
+    for k, n := range restarts {
+        total += n
+        rows = append(rows, countRow{key: k, s: n})
+    }
+    if total == 0 {
+        return ""
+    }
+    sort.Slice(rows, func(i, j int) bool {
+        if rows[i].s != rows[j].s {
+            return rows[i].s > rows[j].s
+        }
+        return rows[i].key < rows[j].key
+    })
+    if len(rows) > 3 { rows = rows[:3] }
+    top := make([]string, len(rows))
+    for i, r := range rows {
+        top[i] = fmt.Sprintf("%s x%s", r.key, num(r.s))
+    }
+    return fmt.Sprintf("Restarts: %s (%s)", num(total), strings.Join(top, ", "))
+}
+
+// timelineClick shows the marks under a clicked timeline column in the header.
+func (a *App) timelineClick(action tview.MouseAction, ev *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+    if action != tview.MouseLeftClick || a.agg == nil {
+        return action, ev
+    }
+    x, _ := ev.Position()
+    ix, _, _, _ := a.timeline.GetInnerRect()
+    i := x - ix
+    if i < 0 || i >= len(a.timelineCols) {
+        return action, ev
+    }
+    if ls, _ := a.marksIn(a.timelineCols[i], time.Duration(a.cfg.Bucket)*time.Second); len(ls) > 0 {
+        a.setNotice(strings.Join(ls, "; "))
+        a.updateHeader()
+    }
+    return action, ev
+}
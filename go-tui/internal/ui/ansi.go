@@ -0,0 +1,253 @@
+package ui
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+)
+
+// Under --ansi-logs, log lines that carry their own ANSI colors (a producer
+// writing to a terminal-style log) show in those colors instead of as
+// escape-code noise. Only SGR (ESC [ ... m) is interpreted; every other
+// escape sequence, and any malformed or cut-off one, is dropped.
+
+// ansiNames are the tview names of the 8 basic ANSI colors; the bright
+// variants (90-97, 100-107) follow them in the same order.
+var ansiNames = [16]string{
+    "black", "maroon", "green", "olive", "navy", "purple", "teal", "silver",
+    "gray", "red", "lime", "yellow", "blue", "fuchsia", "aqua", "white",
+}
+
+// sgrFlags are the tview attribute letters SGR codes set, in tag order.
+const sgrFlags = "bdiulrs"
+
+// sgrState is the style an ANSI line has set so far.
+type sgrState struct {
+    fg, bg string // tview color; "" is the pane's default
+    flags  string // subset of sgrFlags
+}
+
+func (s *sgrState) setFlag(f byte, on bool) {
+    have := strings.IndexByte(s.flags, f) >= 0
+    if on == have {
+        return
+    }
+    b := &strings.Builder{}
+    for i := 0; i < len(sgrFlags); i++ {
+        c := sgrFlags[i]
+        if c == f && on || c != f && strings.IndexByte(s.flags, c) >= 0 {
+            b.WriteByte(c)
+        }
+    }
+    s.flags = b.String()
+}
+
+// apply updates s by one SGR parameter list. An extended color missing its
+// arguments ends the list there.
+func (s *sgrState) apply(params []int) {
+    if len(params) == 0 {
+        params = []int{0}
+    }
+    for i := 0; i < len(params); i++ {
+        switch p := params[i]; {
+        case p == 0:
+            *s = sgrState{}
+        case p == 1:
+            s.setFlag('b', true)
+        case p == 2:
+            s.setFlag('d', true)
+        case p == 3:
+            s.setFlag('i', true)
+        case p == 4:
+            s.setFlag('u', true)
+        case p == 5 || p == 6:
+            s.setFlag('l', true)
+        case p == 7:
+            s.setFlag('r', true)
+        case p == 9:
+            s.setFlag('s', true)
+        case p == 22:
+            s.setFlag('b', false)
+            s.setFlag('d', false)
+        case p == 23:
+            s.setFlag('i', false)
+        case p == 24:
+            s.setFlag('u', false)
+        case p == 25:
+            s.setFlag('l', false)
+        case p == 27:
+            s.setFlag('r', false)
+        case p == 29:
+            s.setFlag('s', false)
+        case p >= 30 && p <= 37:
+            s.fg = ansiNames[p-30]
+        case p >= 90 && p <= 97:
+            s.fg = ansiNames[p-90+8]
+        case p == 39:
+            s.fg = ""
+        case p >= 40 && p <= 47:
+            s.bg = ansiNames[p-40]
+        case p >= 100 && p <= 107:
+            s.bg = ansiNames[p-100+8]
+        case p == 49:
+            s.bg = ""
+        case p == 38 || p == 48:
+            c, n := extColor(params[i+1:])
+            if n == 0 {
+                return
+            }
+            if p == 38 {
+                s.fg = c
+            } else {
+                s.bg = c
+            }
+            i += n
+        }
+    }
+}
+
+// extColor reads the arguments of a 38/48 extended color: 5;n (256-color
+// palette) or 2;r;g;b. It returns how many parameters it used, 0 if they
+// are missing or out of range.
+func extColor(args []int) (string, int) {
+    switch {
+    case len(args) >= 2 && args[0] == 5 && args[1] >= 0 && args[1] <= 255:
+        return fmt.Sprintf("#%06x", tcell.PaletteColor(args[1]).Hex()), 2
+    case len(args) >= 4 && args[0] == 2:
+        for _, v := range args[1:4] {
+            if v < 0 || v > 255 {
+                return "", 0
+            }
+        }
+        return fmt.Sprintf("#%02x%02x%02x", args[1], args[2], args[3]), 4
+    }
+    return "", 0
+}
+
+// tag is s as a complete tview style tag: attributes are reset before
+// they are set, so the result doesn't depend on the tag before it.
+func (s sgrState) tag() string {
+    fg, bg := s.fg, s.bg
+    if fg == "" {
+        fg = "-"
+    }
+    if bg == "" {
+        bg = "-"
+    }
+    t := "[" + fg + ":" + bg + ":-]"
+    if s.flags != "" {
+        t += "[::" + s.flags + "]"
+    }
+    return t
+}
+
+// scanANSI splits line into plain text and escape sequences, calling text
+// for each run of plain text and sgr for each well-formed SGR sequence's
+// parameters. A sequence cut off by the end of the line swallows the rest.
+func scanANSI(line string, text func(string), sgr func([]int)) {
+    for {
+        i := strings.IndexByte(line, 0x1b)
+        if i < 0 {
+            text(line)
+            return
+        }
+        text(line[:i])
+        line = line[i+1:]
+        if line == "" {
+            return
+        }
+        switch line[0] {
+        case '[': // CSI: parameters, intermediates, one final byte
+            j := 1
+            for j < len(line) && line[j] >= 0x20 && line[j] <= 0x3f {
+                j++
+            }
+            if j == len(line) {
+                return
+            }
+            if line[j] == 'm' {
+                if params, ok := sgrParams(line[1:j]); ok {
+                    sgr(params)
+                }
+            }
+            line = line[j+1:]
+        case ']', 'P', '_', '^': // OSC and other strings: to BEL or ST
+            end := strings.IndexAny(line, "\a\x1b")
+            if end < 0 {
+                return
+            }
+            if line[end] == 0x1b && end+1 < len(line) && line[end+1] == '\\' {
+                end++
+            }
+            line = line[end+1:]
+        default: // intermediates (ESC ( B), then one final byte
+            j := 0
+            for j < len(line) && line[j] >= 0x20 && line[j] <= 0x2f {
+                j++
+            }
+            if j == len(line) {
+                return
+            }
+            line = line[j+1:]
+        }
+    }
+}
+
+// sgrParams parses an SGR parameter string ("1;31", "38;5;208"); empty
+// fields are 0. Anything but digits and separators makes it malformed.
+func sgrParams(s string) ([]int, bool) {
+    if s == "" {
+        return nil, true
+    }
+    fields := strings.Split(strings.ReplaceAll(s, ":", ";"), ";")
+    params := make([]int, len(fields))
+    for i, f := range fields {
+        if f == "" {
+            continue
+        }
+        n, err := strconv.Atoi(f)
+        if err != nil || n < 0 {
+            return nil, false
+        }
+        params[i] = min(n, 1<<16)
+    }
+    return params, true
+}
+
+// ansiTags converts line's SGR colors to tview tags and escapes the rest,
+// for a pane with dynamic colors. A line still styled at its end gets a
+// reset so it can't bleed into whatever is written after it.
+func ansiTags(line string) string {
+    b := &strings.Builder{}
+    var st, shown sgrState
+    scanANSI(line, func(s string) {
+        if s == "" {
+            return
+        }
+        if st != shown {
+            b.WriteString(st.tag())
+            shown = st
+        }
+        b.WriteString(tview.Escape(s))
+    }, func(params []int) {
+        st.apply(params)
+    })
+    if shown != (sgrState{}) {
+        b.WriteString("[-:-:-]")
+    }
+    return b.String()
+}
+
+// stripANSI removes every escape sequence from line, for matching it
+// (failures, --restart-marker) as the producer wrote it.
+func stripANSI(line string) string {
+    if strings.IndexByte(line, 0x1b) < 0 {
+        return line
+    }
+    b := &strings.Builder{}
+    scanANSI(line, func(s string) { b.WriteString(s) }, func([]int) {})
+    return b.String()
+}
@@ -1,128 +1,581 @@
 package ui
 
 import (
+    "errors"
     "fmt"
     "os"
     "os/exec"
-    "sort"
+    "os/signal"
+    "regexp"
+    "strconv"
     "strings"
     "sync"
+    "sync/atomic"
+    "syscall"
     "time"
+    "unicode"
+    "unicode/utf8"
 
     "github.com/gdamore/tcell/v2"
+    "github.com/mattn/go-runewidth"
     "github.com/rivo/tview"
 
+    "secmon/internal/alert"
+    "secmon/internal/events"
+    "secmon/internal/history"
     "secmon/internal/metrics"
+    "secmon/internal/remotewrite"
+    "secmon/internal/replay"
+    "secmon/internal/snapshot"
     "secmon/internal/tail"
+    "secmon/internal/webhook"
 )
 
 type AppConfig struct {
-    LogsGlob    string
-    MetricsGlob string
-    Refresh     time.Duration
-    Bucket      int
-    SnapshotDir string
-    QuitAfter   time.Duration
-    Debug       bool
-    Headless    bool
-    Simulate    bool
+    LogsGlob     string
+    MetricsGlob  string
+    Refresh      time.Duration
+    Bucket       int
+    SnapshotDir  string
+    QuitAfter    time.Duration
+    Debug        bool
+    Headless     bool
+    Simulate     bool
+    Interleave   bool           // merge-sort each tick's log lines by timestamp
+    NameRegex    *regexp.Regexp // derives display names from paths (first capture group)
+    StaleAfter   time.Duration  // footer flags data as stale after this long without new entries
+    MinSamples   int            // events needed before a rate is displayed
+    Targets      bool           // collapse attempts into one outcome per (instance, url)
+    EventLog     string         // append NDJSON events (alerts, rotations, staleness) here
+    Legend       bool           // start with the timeline legend shown
+    RetainEvents int            // keep this many recent raw entries in memory (0 = aggregates only)
+    RateColors   bool           // color timeline columns by bucket success rate
+    TailOnly     bool           // logs pane only; no metrics aggregation
+    SplitMax     int            // per-instance log panes available to the split view (0 disables)
+    HealthSpec   string         // overrides for metrics.HealthWeights, "rate=0.5,streak=0.2,..."
+    Replay       string         // replay captured metrics from this dir/file instead of tailing
+    ReplaySpeed  float64        // replay speed multiplier
+    InstanceNorm *regexp.Regexp // collapses instance IDs to their first capture group
+    MaxInstances int            // cap on distinct instances tracked; the rest count as "(other)"
+    AlertRate    float64        // level alert: failure percentage (0 disables)
+    AlertWindow  time.Duration  // level alert: trailing window
+    AlertSlope   float64        // slope alert: rise in failure percentage points (0 disables)
+    SlopeWindow  time.Duration  // slope alert: window compared against the one before it
+    Debounce     int            // completed buckets an alert condition must hold to fire/clear
+    SnapFormat   string         // "text" (header/stats/timeline .txt) or "markdown" (snapshot.md)
+    Layout       string         // one of Layouts
+    Dedup        string         // dedup key fields, "instance_id,ts,attempt"
+    DedupWindow  int            // recent keys remembered for dedup (0 disables)
+    WebhookURL   string         // POST alert transitions here (optional)
+    WebhookTmpl  string         // text/template for the message; webhook.DefaultTemplate if empty
+    Quiet        bool           // for scripts: hide an unavailable PIA field and best-effort warnings
+    RestartMark  *regexp.Regexp // log lines matching this mark a producer restart (optional)
+    PrefixColors bool           // color each log line's [instance] prefix by a hash of the name
+    Validate     bool           // check metrics lines against the Entry schema (diagnostics view)
+    ShrinkPolls  int            // polls a file must stay shorter than its offset to count as truncated
+    Watch        string         // "poll" or "fsnotify" (read on write events; UI only)
+    ControlSock  string         // serve the line-protocol control socket here (optional)
+    RetainDur    time.Duration  // timeline history in wall-clock time (0: a fixed 72 buckets)
+    ChartStyle   string         // timeline rendering, one of ChartStyles
+    ReasonTrends int            // failure reasons with per-bucket trends in the v view (0 disables)
+    MaxFiles     int            // per glob, read only this many most recently modified files (0 = all)
+    SuccessMark  rune           // timeline character for success-only buckets ('S')
+    FailMark     rune           // failure-row character for fail-only buckets ('F')
+    PromAddr     string         // serve Prometheus/OpenMetrics /metrics here (optional)
+    SQLite       string         // append completed buckets to this SQLite database (optional)
+    Slowest      int            // slowest requests kept for the w view and snapshots (0 disables)
+    AnomalyZ     float64        // flag buckets whose volume/failures z-score reaches this (0 disables)
+    AnomalyWin   int            // trailing completed buckets the z-score is taken against
+    CompactNums  bool           // abbreviate large counts on screen (1.3M); exports keep every digit
+    PinRegions   string         // comma-separated regions always listed in the stats panel
+    PinInstances string         // comma-separated instances always listed in the stats panel
+    GroupBy      string         // custom grouping key: "$.json.path" or a regexp on the raw line
+    MaxLabels    int            // cap on distinct GroupBy keys; the rest count as "(other)"
+    ConfirmQuit  bool           // q asks "Quit? (y/n)" first; Q and Ctrl-C don't
+    Checkpoint   string         // save/resume per-file read offsets here (optional)
+    CkEvery      time.Duration  // how often the checkpoint is saved (and on shutdown)
+    ExpectedTot  int            // batch size for the progress panel; overrides total_targets (0: from metrics)
+    PIAColors    map[string]string // lowercased piactl state -> header color (see ParsePIAColors)
+    ActiveWin    time.Duration  // instances/regions with an entry this recent count as active
+    Strict       bool           // refuse to start when --logs and --metrics match the same files
+    Series       []string       // timeline series stacked at startup (see Series; toggled with 1-4)
+    LatestOnly   bool           // per glob, read only the newest file of each instance (by NameRegex)
+    MaxRegions   int            // cap on distinct regions; the rest count as "(other)" (0 = unbounded)
+    MaxReasons   int            // cap on distinct failure reasons, likewise
+    MaxTargets   int            // cap on --targets entries; later targets are only counted as untracked
+    MaxLogLines  int            // lines kept per log pane (0 = unbounded)
+    Record       string         // capture every ingested entry here, replayable with --replay (optional)
+    Label        string         // run label for the header, snapshots and event log; L edits it (optional)
+    AttemptReset bool           // an instance's attempt number dropping back to 1 counts as a restart
+    RemoteWrite  string         // push completed buckets to this Prometheus remote-write URL (optional)
+    IdleMax      time.Duration  // cap the poll interval backs off to while nothing changes (0 = off)
+    IdleAfter    int            // quiet ticks before backing off
+    IncidentDir  string         // on each alert transition, write a snapshot set into a subdirectory here (optional)
+    IncidentWin  time.Duration  // with IncidentDir, also the retained entries from this long before (0 = none)
+    OKReasons    bool           // tally the reasons successes carry, for the r table
+    StrictJSON   bool           // reject metrics entries with fields the schema doesn't have
+    RefreshMin   time.Duration  // + / - bounds and step (defaults 200ms, none, 100ms)
+    RefreshMax   time.Duration  // 0 = no ceiling
+    RefreshStep  time.Duration
+    SoftFail     string         // comma-separated success reasons counted as soft failures (optional)
+    SummaryExit  bool           // print a final summary to stderr when the UI quits
+    UnknownLabel string         // region/instance for entries without one (default "unknown")
+    ANSILogs     bool           // show log lines' ANSI SGR colors instead of the raw escape codes
+    SampleRate   float64        // fraction of log lines shown in the panes (0 or 1: all)
+    PreFill      bool           // fill the gaps between the buckets of history read at startup
 }
 
 type App struct {
     cfg      AppConfig
     app      *tview.Application
     header   *tview.TextView
+    footer   *tview.TextView
     logs     *tview.TextView
     stats    *tview.TextView
     timeline *tview.TextView
+    progress *tview.TextView // batch progress bar; nil unless Targets
+
+    progRate progressRate
 
     logsTail *tail.Reader
+    watch    *tail.Watcher // nil while polling
     agg      *metrics.Aggregator
-    paused   bool
+    paused   atomic.Bool
+    dirty    atomic.Bool   // state changed outside a read (keys, warnings); redraw even if idle
+    wake     chan struct{} // touch: redraw now instead of at the next, possibly backed-off, tick
+    refreshC chan time.Duration // + / -: the loop's new refresh interval
+    loopRefresh time.Duration   // the refresh the loop runs at, as exported; loop goroutine only
+    ctl      chan controlReq    // --control-sock commands for the loop to run
+    showPct  bool // render counts as percentage of total
+    deltas   bool // stats panel shows the last interval instead of totals
+    overlay  int  // secondary timeline series, overlay* constant
+    stack    [len(Series)]bool // 1-4: series stacked as mini-rows in place of the bars
+    axis     bool // g: time axis row and grid under the timeline
+    legend   bool // show the density ramp legend under the timeline
+    failOnly bool // f: logs panes show only failure entries
+    plot     int  // x: what the timeline bars plot, plot* constant
+    reasons  bool // r: stats panel adds the success-reason table
+
+    frozen *frozenView // t: stats/timeline render from this copy; nil when live
+
+    quitModal   *tview.Modal
+    quitPending bool           // --confirm-quit prompt is up; UI goroutine only
+    quitFocus   tview.Primitive // focus to restore when the prompt is cancelled
+
+    filter      string           // Ctrl-F region/instance filter; UI goroutine only
+    filterInput *tview.InputField
+    labelInput  *tview.InputField
+    bucketInput *tview.InputField
+    promptFocus tview.Primitive // focus to restore when the filter or label input closes
+
+    pages      *tview.Pages // "main" panels or the "focus" drill-down
+    mainRow    *tview.Flex  // the "main" page, rebuilt by layoutMain
+    ratio      int          // tenths of the main split given to the logs
+    focus      focusView
+    diag       *tview.TextView
+    slow       *tview.TextView
+    glyphs     *tview.TextView // the k key page
+    files      *tview.TextView // the F log files page
+    heat       heatmapView     // the h instance heatmap page
+    recentLogs []logLine
+    logIDs     map[string]string // log path -> the instance_id its JSON lines carry; update loop only
+
+    logsBox     *tview.Flex // left column: combined logs or the split grid
+    split       bool
+    splitPanes  map[string]*tview.TextView
+    splitOrder  []string
+    splitOthers *tview.TextView
+    logSeq      int  // lines displayed so far, for the n gutter; UI goroutine only
+    lineNumbers bool // n: number log lines as they are appended
+    screen      screenState // size and log-pane follow state at the last draw
     mu       sync.Mutex
     start    time.Time
 
     piaRegion string
     piaState  string
     piaIP     string
+
+    events        *events.Log
+    stale         bool
+    lastMalformed int
+
+    warning      string // shown in the header; guarded by mu
+    label        string // --label, or as edited with L; guarded by mu
+    notice       string // transient header message, e.g. after an export; guarded by mu
+    noticeAt     time.Time
+
+    waitLogs    bool // no file has matched --logs yet; guarded by mu
+    waitMetrics bool // likewise --metrics; guarded by mu
+    snapshotsOK  bool   // a full snapshot set has been written at least once
+    snapshotsOff atomic.Bool // --snapshot-dir failed before any set was written; cfg.SnapshotDir is left as given
+
+    snapMu    sync.Mutex // serializes snapshot sets (tick, control socket, incidents)
+    snapFails int        // consecutive failed snapshot sets; guarded by snapMu
+
+    ckMu sync.Mutex // serializes log reads with checkpoint saves and the F page; guards ckAt
+    ckAt time.Time  // last checkpoint save
+
+    health metrics.HealthWeights
+    dedup  metrics.DedupKey
+    group  *metrics.GroupBy // nil without --group-by
+
+    replay   *replay.Player
+    replayAt time.Time // ts of the last replayed entry; guarded by mu
+
+    overlap   string // files both globs matched when last reported; update goroutine only
+    overlapAt [2]int // log and metrics match counts it was last checked at
+
+    prevCounts counts   // update goroutine only
+    ignored    [2]int   // logs, metrics files past --max-files; update goroutine only
+    lastTotal  int      // Success+Fail at the last tick, to tell idle ticks; update goroutine only
+    sawData    bool     // an entry was ingested at some point; update goroutine only
+
+    writeFailed atomic.Bool // an output write failed, for ExitCode
+    interval   interval // guarded by mu
+
+    alerts      alert.Monitor // evaluated on the update goroutine
+    anomalies   alert.Anomalies
+    alertStatus alert.Status  // copy for renderers; guarded by mu
+    webhook     *webhook.Notifier
+    history     *history.DB // --sqlite; update goroutine only
+    remote      *remotewrite.Pusher // --remote-write
+
+    marks        []mark      // timeline annotations; guarded by mu
+    pinRegions   map[string]bool
+    pinInstances map[string]bool // P adds/removes; guarded by mu
+    timelineCols []time.Time // bucket start of each rendered timeline column
 }
 
+// malformedBurst is the number of unparseable metrics lines in one tick that
+// is reported to the event log.
+const malformedBurst = 10
+
 func NewApp(cfg AppConfig) *App {
-    return &App{cfg: cfg, start: time.Now()}
+    if cfg.SuccessMark == 0 { cfg.SuccessMark = 'S' }
+    if cfg.FailMark == 0 { cfg.FailMark = 'F' }
+    if cfg.RefreshMin <= 0 { cfg.RefreshMin = defaultRefreshMin }
+    if cfg.RefreshStep <= 0 { cfg.RefreshStep = defaultRefreshStep }
+    a := &App{cfg: cfg, start: time.Now(), legend: cfg.Legend, label: cfg.Label, ratio: defaultRatio, splitPanes: make(map[string]*tview.TextView),
+        wake: make(chan struct{}, 1), refreshC: make(chan time.Duration, 1), loopRefresh: cfg.Refresh, ctl: make(chan controlReq), pinRegions: parsePins(cfg.PinRegions), pinInstances: parsePins(cfg.PinInstances)}
+    for _, s := range cfg.Series {
+        if i := seriesIndex(s); i >= 0 { a.stack[i] = true }
+    }
+    return a
 }
 
 func (a *App) Run() error {
+    hw, err := metrics.ParseHealthWeights(a.cfg.HealthSpec, metrics.DefaultHealthWeights())
+    if err != nil {
+        return err
+    }
+    hw.StaleAfter = a.cfg.StaleAfter
+    a.health = hw
+    if a.cfg.DedupWindow > 0 {
+        if a.dedup, err = metrics.ParseDedupKey(a.cfg.Dedup); err != nil {
+            return err
+        }
+    }
+    a.alerts = alert.Monitor{
+        FailRate:    a.cfg.AlertRate,
+        Window:      a.cfg.AlertWindow,
+        SlopePP:     a.cfg.AlertSlope,
+        SlopeWindow: a.cfg.SlopeWindow,
+        Debounce:    a.cfg.Debounce,
+        MinSamples:  a.cfg.MinSamples,
+    }
+    a.anomalies = alert.Anomalies{Z: a.cfg.AnomalyZ, Window: a.cfg.AnomalyWin}
+    if a.cfg.GroupBy != "" {
+        if a.group, err = metrics.ParseGroupBy(a.cfg.GroupBy); err != nil {
+            return fmt.Errorf("--group-by: %w", err)
+        }
+    }
+    if a.cfg.Replay != "" {
+        p, err := replay.Load(a.cfg.Replay, a.cfg.ReplaySpeed, a.group)
+        if err != nil {
+            return fmt.Errorf("replay: %w", err)
+        }
+        a.replay = p
+    }
+    if a.cfg.EventLog != "" {
+        l, err := events.Open(a.cfg.EventLog)
+        if err != nil {
+            return err
+        }
+        a.events = l
+        a.events.SetLabel(a.cfg.Label)
+        defer a.events.Close()
+    }
+    if a.cfg.WebhookURL != "" {
+        n, err := webhook.New(a.cfg.WebhookURL, a.cfg.WebhookTmpl, a.webhookError)
+        if err != nil {
+            return err
+        }
+        a.webhook = n
+        defer a.webhook.Close(5 * time.Second)
+    }
+    if a.cfg.SnapshotDir != "" {
+        if err := os.MkdirAll(a.cfg.SnapshotDir, 0o755); err != nil {
+            err = fmt.Errorf("snapshot dir: %w", err)
+            if a.cfg.Headless {
+                return err
+            }
+            a.warnf("%v", err)
+            a.setWarning("snapshots disabled: " + err.Error())
+            a.snapshotsOff.Store(true)
+        }
+    }
+    if err := a.checkOverlap(); err != nil {
+        return err
+    }
     if a.cfg.Headless {
         return a.runHeadless()
     }
     a.app = tview.NewApplication()
 
     a.header = tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignLeft)
-    a.logs = tview.NewTextView().SetDynamicColors(false).SetScrollable(true)
+    a.footer = tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignLeft)
+    a.logs = tview.NewTextView().SetDynamicColors(a.logColors()).SetScrollable(true).SetMaxLines(a.cfg.MaxLogLines)
     a.stats = tview.NewTextView().SetDynamicColors(true)
     a.timeline = tview.NewTextView().SetDynamicColors(true)
 
-    a.logs.SetBorder(true).SetTitle("Logs")
+    a.logs.SetBorder(true).SetTitle("Logs" + a.sampleTitle())
     a.stats.SetBorder(true).SetTitle("Stats")
     a.timeline.SetBorder(true).SetTitle("Timeline")
+    a.timeline.SetMouseCapture(a.timelineClick)
 
-    left := tview.NewFlex().SetDirection(tview.FlexRow)
-    left.AddItem(a.logs, 0, 1, false)
-    right := tview.NewFlex().SetDirection(tview.FlexRow)
-    right.AddItem(a.stats, 0, 1, false)
-    right.AddItem(a.timeline, 0, 1, false)
+    a.logsBox = tview.NewFlex().SetDirection(tview.FlexRow)
+    a.layoutLogs()
+    a.mainRow = tview.NewFlex()
+    a.layoutMain()
 
-    mainRow := tview.NewFlex().SetDirection(tview.FlexColumn)
-    mainRow.AddItem(left, 0, 3, false)
-    mainRow.AddItem(right, 0, 2, false)
+    a.pages = tview.NewPages()
+    a.pages.AddPage("main", a.mainRow, true, true)
+    a.pages.AddPage("focus", a.buildFocusView(), true, false)
+    a.pages.AddPage("diag", a.buildDiagView(), true, false)
+    a.pages.AddPage("slow", a.buildSlowView(), true, false)
+    a.pages.AddPage("key", a.buildKeyView(), true, false)
+    a.pages.AddPage("files", a.buildFilesView(), true, false)
+    a.pages.AddPage("heatmap", a.buildHeatmapView(), true, false)
+    a.quitModal = a.buildQuitModal()
+    a.pages.AddPage("quit", a.quitModal, false, false)
+    a.pages.AddPage("filter", a.buildFilterInput(), true, false)
+    a.pages.AddPage("label", a.buildLabelInput(), true, false)
+    a.pages.AddPage("bucket", a.buildBucketInput(), true, false)
 
     root := tview.NewFlex().SetDirection(tview.FlexRow)
     root.AddItem(a.header, 1, 0, false)
-    root.AddItem(mainRow, 0, 1, true)
+    root.AddItem(a.pages, 0, 1, true)
+    if a.cfg.Targets && !a.cfg.TailOnly {
+        a.progress = tview.NewTextView().SetDynamicColors(true)
+        a.progress.SetBorder(true).SetTitle("Progress")
+        root.AddItem(a.progress, 3, 0, false)
+    }
+    root.AddItem(a.footer, 1, 0, false)
 
     a.logsTail = tail.NewReader(a.cfg.LogsGlob)
-    a.agg = metrics.NewAggregator(a.cfg.MetricsGlob, a.cfg.Bucket, 72)
+    a.logsTail.ShrinkPolls = a.cfg.ShrinkPolls
+    a.logsTail.MaxFiles = a.cfg.MaxFiles
+    a.logsTail.Group = a.latestGroup()
+    if !a.cfg.TailOnly {
+        a.agg = a.newAggregator()
+        if a.replay != nil {
+            go a.runReplay()
+        }
+    }
 
+    a.startWaiting()
     a.updateHeader()
     a.renderStats()
     a.renderTimeline()
+    a.renderProgress()
+    a.renderFooter()
 
     // Key bindings
     a.app.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+        a.touch()
+        if a.quitPending {
+            return a.quitKey(ev)
+        }
+        if a.focus.input.HasFocus() || a.filterInput.HasFocus() || a.labelInput.HasFocus() || a.bucketInput.HasFocus() {
+            return ev // typing an instance name, filter, label or bucket size
+        }
+        if ev.Key() == tcell.KeyCtrlF {
+            a.openFilter()
+            return nil
+        }
+        if name, _ := a.pages.GetFrontPage(); name == "focus" && ev.Key() == tcell.KeyEscape {
+            a.exitFocus()
+            return nil
+        }
+        if name, _ := a.pages.GetFrontPage(); (name == "diag" || name == "slow" || name == "key" || name == "files" || name == "heatmap") && ev.Key() == tcell.KeyEscape {
+            a.togglePage(name)
+            return nil
+        }
+        if ev.Modifiers()&tcell.ModCtrl != 0 {
+            switch ev.Key() {
+            case tcell.KeyLeft:
+                a.resize(-1)
+                return nil
+            case tcell.KeyRight:
+                a.resize(+1)
+                return nil
+            }
+        }
         switch ev.Rune() {
         case 'q':
+            a.requestQuit()
+            return nil
+        case 'Q':
             a.app.Stop()
             return nil
         case 'p':
-            a.paused = !a.paused
+            a.paused.Store(!a.paused.Load())
             return nil
         case '+':
-            if a.cfg.Refresh > 200*time.Millisecond {
-                a.cfg.Refresh -= 100 * time.Millisecond
-            }
+            a.stepRefresh(-1)
             return nil
         case '-':
-            a.cfg.Refresh += 100 * time.Millisecond
+            a.stepRefresh(+1)
             return nil
         case '[':
-            if a.cfg.Bucket > 1 {
-                a.cfg.Bucket -= 5
-                if a.cfg.Bucket < 1 { a.cfg.Bucket = 1 }
-                a.agg.SetBucketSeconds(a.cfg.Bucket)
+            if a.agg != nil && a.cfg.Bucket > 1 {
+                a.setBucket(a.cfg.Bucket - 5)
             }
             return nil
         case ']':
-            a.cfg.Bucket += 5
-            if a.cfg.Bucket > 120 { a.cfg.Bucket = 120 }
-            a.agg.SetBucketSeconds(a.cfg.Bucket)
+            if a.agg == nil {
+                return nil
+            }
+            a.setBucket(min(a.cfg.Bucket+5, max(a.cfg.Bucket, 120))) // stops at 120s, or where b left it
+            return nil
+        case 'b':
+            a.openBucket()
             return nil
         case 'c':
-            a.logs.Clear()
+            a.clearLogs()
+            return nil
+        case 's':
+            if a.cfg.SplitMax > 0 {
+                a.split = !a.split
+                a.layoutLogs()
+            }
+            return nil
+        case 'i':
+            a.enterFocus()
+            return nil
+        case 'v':
+            a.togglePage("diag")
+            return nil
+        case 'w':
+            a.togglePage("slow")
+            return nil
+        case 'o':
+            a.overlay = (a.overlay + 1) % overlayCount
+            a.setTitles()
+            a.renderTimeline()
+            return nil
+        case 'm':
+            a.exportMarkdown()
+            return nil
+        case 'l':
+            a.legend = !a.legend
+            a.renderTimeline()
+            return nil
+        case '%':
+            a.showPct = !a.showPct
+            a.renderStats()
+            return nil
+        case 'd', 'Δ':
+            a.deltas = !a.deltas
+            a.setTitles()
+            a.renderStats()
+            return nil
+        case 't':
+            a.toggleFreeze()
+            return nil
+        case 'y':
+            a.copyStats()
+            return nil
+        case 'P':
+            a.togglePin()
+            return nil
+        case '1', '2', '3', '4':
+            a.toggleSeries(int(ev.Rune() - '1'))
+            return nil
+        case 'g':
+            a.toggleAxis()
+            return nil
+        case 'f':
+            a.toggleFailOnly()
+            return nil
+        case 'L':
+            a.openLabel()
+            return nil
+        case 'r':
+            a.toggleSuccessReasons()
+            return nil
+        case 'x':
+            a.cyclePlot()
+            return nil
+        case 'n':
+            a.toggleLineNumbers()
+            return nil
+        case 'k':
+            a.togglePage("key")
+            return nil
+        case 'F':
+            a.togglePage("files")
+            return nil
+        case 'h':
+            a.togglePage("heatmap")
             return nil
         }
         return ev
     })
 
+    stop, err := a.startControl()
+    if err != nil {
+        return err
+    }
+    defer stop()
+    stopProm, err := a.startProm()
+    if err != nil {
+        return err
+    }
+    defer stopProm()
+    stopHistory, err := a.startHistory()
+    if err != nil {
+        return err
+    }
+    defer stopHistory()
+    stopRecord, err := a.startRecord()
+    if err != nil {
+        return err
+    }
+    defer stopRecord()
+    stopRemote, err := a.startRemoteWrite()
+    if err != nil {
+        return err
+    }
+    defer stopRemote()
+    stopCheckpoint, err := a.startCheckpoint()
+    if err != nil {
+        return err
+    }
+    defer stopCheckpoint()
+    if a.cfg.Watch == "fsnotify" {
+        patterns := []string{a.cfg.LogsGlob}
+        if a.agg != nil && a.replay == nil {
+            patterns = append(patterns, a.cfg.MetricsGlob)
+        }
+        if w, err := tail.Watch(patterns, watchMaxFiles); err != nil {
+            a.warnf("--watch fsnotify: %v; polling instead", err)
+        } else {
+            a.watch = w
+            defer a.watch.Close()
+        }
+    }
+
     // Tickers
     go a.loop()
     go a.pollPIA()
@@ -133,38 +586,222 @@ func (a *App) Run() error {
         }()
     }
 
-    return a.app.SetRoot(root, true).EnableMouse(true).Run()
+    a.app.SetAfterDrawFunc(a.afterDraw)
+    err = a.app.SetRoot(root, true).EnableMouse(true).Run()
+    if err == nil && a.cfg.SummaryExit {
+        a.writeSummary(os.Stderr)
+    }
+    return err
 }
 
+// Under --watch fsnotify, files are read on write events; ticks only redraw,
+// plus a read every watchFallback in case an event was missed.
+const (
+    watchFallback = 5 * time.Second
+    watchMaxFiles = 16
+)
+
+// A tick that reads nothing new and follows no key press or other state
+// change skips the redraw (bar one every idleRedraw), and with --idle-max
+// the ticks themselves back off; see idleBackoff.
 func (a *App) loop() {
-    ticker := time.NewTicker(a.cfg.Refresh)
-    defer ticker.Stop()
+    backoff := newIdleBackoff(a.cfg)
+    timer := time.NewTimer(backoff.base)
+    defer timer.Stop()
+    var notify <-chan struct{}
+    if a.watch != nil {
+        notify = a.watch.C
+    }
+    var lastRead, lastDraw time.Time
+    draw := func(active bool) {
+        if active || a.dirty.Swap(false) || time.Since(lastDraw) >= idleRedraw {
+            a.redraw()
+            lastDraw = time.Now()
+        }
+    }
     for {
         select {
-        case <-ticker.C:
-            if a.paused {
+        case <-timer.C:
+            if a.paused.Load() {
+                timer.Reset(backoff.base)
+                continue
+            }
+            active := false
+            if a.watch == nil || time.Since(lastRead) >= watchFallback {
+                active = a.readTick()
+                lastRead = time.Now()
+            }
+            a.tickSnapshots()
+            a.checkpointTick()
+            draw(active)
+            timer.Reset(backoff.next(active))
+        case <-notify:
+            if a.paused.Load() {
                 continue
             }
-            // logs
-            for _, pair := range a.logsTail.ReadNew() {
-                name := filepathBase(pair[0])
-                line := fmt.Sprintf("[%s] %s", name, pair[1])
-                a.app.QueueUpdateDraw(func() {
-                    fmt.Fprintln(a.logs, line)
-                })
+            active := a.readTick()
+            lastRead = time.Now()
+            draw(active)
+            if active {
+                backoff.wakeUp()
+            }
+        case req := <-a.ctl:
+            req.resp <- a.controlCommand(req.cmd, req.args)
+        case r := <-a.refreshC:
+            a.loopRefresh = r
+            backoff.base = r
+            backoff.wakeUp()
+            if !timer.Stop() {
+                <-timer.C
+            }
+            timer.Reset(r)
+        case <-a.wake:
+            draw(false)
+            if backoff.cur != backoff.base {
+                backoff.wakeUp()
+                if !timer.Stop() {
+                    <-timer.C
+                }
+                timer.Reset(backoff.base)
             }
-            // metrics
-            a.agg.Update()
-            a.agg.EnsureBucketsTo(time.Now())
-            a.app.QueueUpdateDraw(func() {
-                a.updateHeader()
-                a.renderStats()
-                a.renderTimeline()
-            })
         }
     }
 }
 
+// readTick reads new log lines and metrics, and reports whether there was
+// anything new.
+func (a *App) readTick() bool {
+    // logs
+    a.ckMu.Lock()
+    pairs := a.logsTail.ReadNew()
+    a.ckMu.Unlock()
+    a.noteIgnored(0, "log", a.logsTail.Ignored)
+    a.noteMatches(a.logsTail.Matched, 0)
+    if a.cfg.Interleave {
+        pairs = tail.Interleave(pairs)
+    }
+    for _, pair := range pairs {
+        path := pair[0]
+        raw := pair[1]
+        if a.cfg.ANSILogs {
+            raw = stripANSI(raw)
+        }
+        if !a.sampleLog() {
+            a.markRestart(path, "", raw)
+            continue
+        }
+        line := a.formatLog(path, pair[1])
+        fail, id := logFields(raw)
+        inst := a.logInstance(path, id)
+        a.app.QueueUpdateDraw(func() {
+            a.appendLog(path, inst, line, fail)
+        })
+        a.markRestart(path, id, raw)
+    }
+    // metrics
+    active := len(pairs) > 0
+    if a.agg != nil && a.updateMetrics() {
+        active = true
+    }
+    return active
+}
+
+// noteIgnored warns when the number of files skipped by --max-files for
+// one glob (slot 0 logs, 1 metrics) changes.
+func (a *App) noteIgnored(slot int, kind string, n int) {
+    if n == a.ignored[slot] {
+        return
+    }
+    a.ignored[slot] = n
+    if n == 0 {
+        return
+    }
+    msg := fmt.Sprintf("--max-files: ignoring the %d oldest %s files", n, kind)
+    if a.cfg.Headless {
+        a.warnf("%s", msg)
+        return
+    }
+    a.setWarning(msg)
+}
+
+// tickSnapshots writes the snapshot set once per refresh.
+func (a *App) tickSnapshots() {
+    if a.agg == nil || a.cfg.SnapshotDir == "" || a.snapshotsOff.Load() {
+        return
+    }
+    if err := a.writeSnapshots(); err != nil && !a.snapshotsOK {
+        a.setWarning("snapshots disabled: " + err.Error())
+        a.snapshotsOff.Store(true)
+    }
+}
+
+func (a *App) redraw() {
+    a.app.QueueUpdateDraw(func() {
+        a.updateHeader()
+        a.renderStats()
+        a.renderTimeline()
+        a.renderProgress()
+        a.renderFooter()
+        a.renderFocus()
+        a.renderDiag()
+        a.renderSlow()
+        a.renderKey()
+        a.renderFiles()
+        a.renderHeatmap()
+    })
+}
+
+// updateMetrics is the per-tick metrics step: read new entries (unless a
+// replay is feeding them), advance the timeline and report transitions.
+// --debug gets a one-line summary of what was read. It reports whether
+// anything changed: lines read, buckets added, or counts moved (a replay or
+// a reset).
+func (a *App) updateMetrics() bool {
+    now := time.Now()
+    var us metrics.UpdateStats
+    if a.replay != nil {
+        a.mu.Lock()
+        now = a.replayAt
+        a.mu.Unlock()
+    } else {
+        us = a.agg.Update()
+    }
+    if !now.IsZero() {
+        us.Buckets += a.agg.EnsureBucketsTo(now)
+    }
+    a.markAttemptRestarts()
+    if a.replay == nil {
+        a.debugf("tick: %s", us)
+        a.noteMatches(0, us.Files)
+        a.noteOverlap(a.logsTail.Matched, us.Files)
+    }
+    st := a.agg.Snapshot()
+    a.flushHistory(st)
+    a.flushRemoteWrite(st)
+    a.noteIgnored(1, "metrics", st.IgnoredFiles)
+    a.recordInterval(st, time.Now())
+    a.observe(st)
+    total := st.Success + st.Fail
+    if total > 0 {
+        a.sawData = true
+    }
+    changed := us.Lines > 0 || us.Buckets > 0 || total != a.lastTotal
+    a.lastTotal = total
+    return changed
+}
+
+// runReplay feeds the loaded capture into the aggregator; the replay clock
+// (the last entry's ts) drives the timeline instead of wall time.
+func (a *App) runReplay() {
+    a.replay.Run(nil, func(e metrics.Entry, ts time.Time) {
+        a.agg.Ingest(e)
+        a.mu.Lock()
+        a.replayAt = ts
+        a.mu.Unlock()
+    })
+    a.debugf("replay: finished %d entries", a.replay.Len())
+}
+
 func (a *App) pollPIA() {
     ticker := time.NewTicker(3 * time.Second)
     defer ticker.Stop()
@@ -173,9 +810,63 @@ func (a *App) pollPIA() {
         state := readPIA("connectionstate")
         ip := readPIA("vpnip")
         a.mu.Lock()
+        prev := a.piaIP
+        changed := region != a.piaRegion || state != a.piaState || ip != prev
         a.piaRegion, a.piaState, a.piaIP = region, state, ip
         a.mu.Unlock()
+        if changed {
+            a.touch()
+        }
+        if prev != "" && prev != "na" && ip != "na" && ip != prev {
+            a.events.Emit(events.IPRotation, map[string]any{"old_ip": prev, "new_ip": ip, "region": region})
+            a.addMark("ip " + prev + " -> " + ip)
+        }
+    }
+}
+
+// observe runs once per tick after ingestion and reports state transitions
+// (alerts, anomalous buckets, stale onset/recovery, malformed bursts) to the
+// event log.
+func (a *App) observe(st metrics.Snapshot) {
+    for _, t := range a.alerts.Evaluate(st.Buckets(), st.BucketSecs, time.Now()) {
+        typ := events.AlertCleared
+        if t.Fired {
+            typ = events.AlertFired
+            a.addMark(t.Kind + " alert")
+        }
+        a.events.Emit(typ, map[string]any{"kind": t.Kind, "value": t.Value, "threshold": t.Threshold, "window": t.Window.String()})
+        a.debugf("%s", t)
+        state := "cleared"
+        if t.Fired { state = "fired" }
+        a.webhook.Send(webhook.Alert{Kind: t.Kind, State: state, Value: t.Value, Threshold: t.Threshold, Window: t.Window, TopReason: topReason(st.PerReason)})
+        a.captureIncident(t, time.Now())
+    }
+    a.mu.Lock()
+    a.alertStatus = a.alerts.Status()
+    a.mu.Unlock()
+    for _, an := range a.anomalies.Check(st.Buckets()) {
+        a.events.Emit(events.Anomaly, map[string]any{"series": an.Series, "bucket": an.Start.UTC().Format(time.RFC3339), "value": an.Value, "mean": an.Mean, "stddev": an.Std, "z": an.Z})
+        a.addAnomaly(an.Start, fmt.Sprintf("%s z=%+.1f (%d vs %.1f)", an.Series, an.Z, an.Value, an.Mean))
+        a.debugf("%s", an)
+    }
+
+    stale := a.isStale(st)
+    if stale != a.stale {
+        if stale {
+            a.events.Emit(events.StaleOnset, map[string]any{"last_ingest": st.LastIngest.UTC().Format(time.RFC3339)})
+        } else {
+            a.events.Emit(events.StaleRecovery, nil)
+        }
+        a.stale = stale
+    }
+    if n := st.Malformed - a.lastMalformed; n >= malformedBurst {
+        a.events.Emit(events.MalformedBurst, map[string]any{"count": n, "total": st.Malformed})
     }
+    a.lastMalformed = st.Malformed
+}
+
+func (a *App) isStale(st metrics.Snapshot) bool {
+    return a.cfg.StaleAfter > 0 && !st.LastIngest.IsZero() && time.Since(st.LastIngest) > a.cfg.StaleAfter
 }
 
 func readPIA(field string) string {
@@ -186,83 +877,504 @@ func readPIA(field string) string {
     return strings.TrimSpace(string(out))
 }
 
+// piaText is the "pia=region:state:ip | " header segment, with the state
+// colored for the screen if color is set. --quiet drops it while piactl
+// reports nothing; call with mu held.
+func (a *App) piaText(color bool) string {
+    na := func(v string) bool { return v == "" || v == "na" }
+    if a.cfg.Quiet && na(a.piaRegion) && na(a.piaState) && na(a.piaIP) {
+        return ""
+    }
+    state := a.piaState
+    if color {
+        state = a.piaStateText(state)
+    }
+    return fmt.Sprintf("pia=%s:%s:%s | ", a.piaRegion, state, a.piaIP)
+}
+
 func (a *App) updateHeader() {
     a.mu.Lock()
-    pia := fmt.Sprintf("pia=%s:%s:%s", a.piaRegion, a.piaState, a.piaIP)
+    pia := a.piaText(true)
+    warning := a.warning
+    label := a.label
+    as := a.alertStatus
+    notice := a.notice
+    if time.Since(a.noticeAt) > noticeFor {
+        notice = ""
+    }
     a.mu.Unlock()
-    hdr := fmt.Sprintf(" %s | bucket=%ds | r=%.1fs  (q quit, p pause, +/- refresh, [/] bucket, c clear)", pia, a.cfg.Bucket, a.cfg.Refresh.Seconds())
+    filter := ""
+    if a.filter != "" {
+        filter = "[yellow]filter=" + tview.Escape(a.filter) + "[-] | "
+    }
+    if label != "" {
+        filter = "[aqua]" + tview.Escape(label) + "[-] | " + filter
+    }
+    hdr := fmt.Sprintf(" %s | %s%sbucket=%ds | [::b]refresh %s[::-]  (q quit, p pause, +/- refresh, [/] bucket, b set bucket, c clear, s split, f failures, i instance, l legend, m markdown, y copy, v diag, w slowest, o overlay, 1-4 series, g axis, t freeze, %% counts/pct, d deltas, ^F filter, L label, r success reasons, x plot, n line numbers, k key, F files, h heatmap)", a.healthText(), pia, filter, a.cfg.Bucket, a.cfg.Refresh)
+    if notice != "" {
+        hdr = " [green]" + tview.Escape(notice) + "[-] |" + hdr
+    }
+    if as.Slope {
+        hdr = fmt.Sprintf(" [black:fuchsia]SPIKE %+.0fpp[-:-] |", as.SlopeValue) + hdr
+    }
+    if as.Level {
+        hdr = fmt.Sprintf(" [white:red]ALERT fail %.0f%%[-:-] |", as.LevelValue) + hdr
+    }
+    if warning != "" {
+        hdr = " [red]" + tview.Escape(warning) + "[-] |" + hdr
+    }
     a.header.SetText(hdr)
 }
 
+// healthText renders the 0-100 health score colored by band.
+func (a *App) healthText() string {
+    if a.agg == nil {
+        return "health=--"
+    }
+    h := a.agg.HealthScore()
+    switch {
+    case h < 0:
+        return "health=--"
+    case h >= 80:
+        return fmt.Sprintf("[green::b]health=%d[-::-]", h)
+    case h >= 50:
+        return fmt.Sprintf("[yellow::b]health=%d[-::-]", h)
+    default:
+        return fmt.Sprintf("[red::b]health=%d[-::-]", h)
+    }
+}
+
+// webhookError reports a failed delivery; called from the sender goroutine.
+func (a *App) webhookError(err error) {
+    if a.cfg.Headless {
+        a.warnf("%v", err)
+        return
+    }
+    a.setWarning(err.Error())
+}
+
+// topReason returns the most common failure reason, "" if there are none.
+func topReason(reasons map[string]int) string {
+    top, n := "", 0
+    for k, v := range reasons {
+        if v > n || (v == n && k < top) {
+            top, n = k, v
+        }
+    }
+    return top
+}
+
+func (a *App) setWarning(msg string) {
+    a.mu.Lock()
+    a.warning = msg
+    a.mu.Unlock()
+    a.touch()
+}
+
+// noticeFor is how long a notice stays in the header.
+const noticeFor = 5 * time.Second
+
+// retainNotice reports the bucket count --retain-duration works out to after
+// a bucket size change.
+func (a *App) retainNotice() {
+    if a.cfg.RetainDur > 0 {
+        a.setNotice(fmt.Sprintf("history %s = %d x %ds buckets", a.cfg.RetainDur, a.agg.EffectiveMaxBuckets(), a.cfg.Bucket))
+    }
+}
+
+func (a *App) setNotice(msg string) {
+    a.mu.Lock()
+    a.notice, a.noticeAt = msg, time.Now()
+    a.mu.Unlock()
+    a.touch()
+}
+
+// warnf prints a best-effort warning to stderr; --quiet silences it unless
+// --debug is also on.
+func (a *App) warnf(format string, args ...any) {
+    if a.cfg.Quiet && !a.cfg.Debug {
+        return
+    }
+    fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}
+
+func (a *App) debugf(format string, args ...any) {
+    if a.cfg.Debug {
+        fmt.Fprintf(os.Stderr, "debug: "+format+"\n", args...)
+    }
+}
+
+// renderFooter draws the always-visible status strip: totals, success rate and
+// whether new metrics have stopped arriving.
+func (a *App) renderFooter() {
+    if a.agg == nil {
+        a.footer.SetText(" tail-only: " + tview.Escape(a.cfg.LogsGlob))
+        return
+    }
+    st := a.agg.Snapshot()
+    total := st.Success + st.Fail
+    b := &strings.Builder{}
+    fmt.Fprintf(b, " total=%s ok=%s fail=%s rate=%s", a.num(total), a.num(st.Success), a.num(st.Fail), a.rateText(st.Success, total))
+    switch {
+    case st.LastIngest.IsZero():
+        b.WriteString(" | [yellow]no data yet[-]")
+    case a.isStale(st):
+        fmt.Fprintf(b, " | [red]STALE %s[-]", time.Since(st.LastIngest).Round(time.Second))
+    default:
+        fmt.Fprintf(b, " | [green]live[-] (last %s ago)", time.Since(st.LastIngest).Round(time.Second))
+    }
+    a.mu.Lock()
+    as := a.alertStatus
+    a.mu.Unlock()
+    switch {
+    case as.Level:
+        fmt.Fprintf(b, " | [red]alert: fail %.1f%% since %s[-]", as.LevelValue, as.LevelSince.Format("15:04:05"))
+    case as.Slope:
+        fmt.Fprintf(b, " | [fuchsia]alert: fail rate %+.1fpp since %s[-]", as.SlopeValue, as.SlopeSince.Format("15:04:05"))
+    case a.cfg.AlertRate > 0 || a.cfg.AlertSlope > 0:
+        b.WriteString(" | alerts ok")
+    }
+    a.footer.SetText(b.String())
+}
+
 func (a *App) renderStats() {
-    total := a.agg.Success + a.agg.Fail
+    if a.agg == nil {
+        return
+    }
+    if a.waitingMetrics() {
+        a.stats.SetText(a.waitingText(a.cfg.MetricsGlob, true))
+        return
+    }
+    if a.deltas {
+        a.stats.SetText(a.deltaText(a.statsFit()))
+        return
+    }
+    a.stats.SetText(a.statsText(a.viewSnapshot(), a.num, a.statsFit(), a.showPct, a.fleetNow()))
+}
+
+// statsText renders the stats panel body; snapshots write the same text, with
+// counts formatted by num (a.num on screen, strconv.Itoa for exports), as
+// percentages if pct (the '%' toggle; exports pass false), tables sized by
+// fit (nil for exports) and instance activity judged at now (fleetNow on
+// screen, clockNow for exports). Exports run off the UI goroutine, so the
+// display modes are read only on screen.
+func (a *App) statsText(st metrics.Snapshot, num func(int) string, fit *tableFit, pct bool, now time.Time) string {
+    total := st.Success + st.Fail
     b := &strings.Builder{}
-    fmt.Fprintf(b, "Total: %d  Success: %d  Fail: %d\n", total, a.agg.Success, a.agg.Fail)
-    if n := len(a.agg.Timeline); n > 0 {
-        last := a.agg.Timeline[n-1]
-        fmt.Fprintf(b, "Last %ds  S:%d F:%d\n", a.cfg.Bucket, last[1], last[2])
+    fmt.Fprintln(b, a.gaugeText(st, fit))
+    fmt.Fprintf(b, "Total: %s  Success: %s  Fail: %s  Rate: %s", num(total), a.countText(st.Success, total, num, pct), a.countText(st.Fail, total, num, pct), a.rateText(st.Success, total))
+    if a.cfg.SoftFail != "" {
+        soft := "-"
+        if total > 0 {
+            soft = fmt.Sprintf("%.1f%%", 100*float64(st.SoftFail)/float64(total))
+        }
+        fmt.Fprintf(b, "  Soft fail: %s (%s)", num(st.SoftFail), soft)
+    }
+    b.WriteByte('\n')
+    if bs := st.Buckets(); len(bs) > 0 {
+        last := bs[len(bs)-1]
+        lt := last.Total()
+        fmt.Fprintf(b, "Last %ds  S:%s F:%s  Rate: %s\n", st.BucketSecs, a.countText(last.Success, lt, num, pct), a.countText(last.Fail, lt, num, pct), a.rateText(last.Success, lt))
+    }
+    if f := st.Fleet(now, a.cfg.ActiveWin); f.Instances > 0 {
+        fmt.Fprintf(b, "Instances: %s (%s active)  Regions: %s (%s active)\n", num(f.Instances), num(f.ActiveInstances), num(f.Regions), num(f.ActiveRegions))
+    }
+    if a.cfg.DedupWindow > 0 {
+        fmt.Fprintf(b, "Duplicates skipped: %s\n", num(st.DuplicatesSkipped))
+    }
+    if s := restartText(st.Restarts, num); s != "" {
+        fmt.Fprintln(b, s)
+    }
+    if st.Unbucketed > 0 {
+        fmt.Fprintf(b, "Unbucketed: %s (ts before 2000 or ahead of the clock)\n", num(st.Unbucketed))
     }
-    // top regions
-    type kv struct{ key string; s, f int }
-    arr := make([]kv, 0, len(a.agg.PerRegion))
-    for k, v := range a.agg.PerRegion { arr = append(arr, kv{k, v[0], v[1]}) }
-    sort.Slice(arr, func(i, j int) bool { return (arr[i].s+arr[i].f) > (arr[j].s+arr[j].f) })
-    if len(arr) > 6 { arr = arr[:6] }
-    fmt.Fprintln(b, "Regions:")
-    for _, it := range arr {
-        fmt.Fprintf(b, "  %-18s S:%4d F:%4d\n", it.key, it.s, it.f)
+    if st.MissingRegion > 0 || st.MissingInstance > 0 {
+        label := a.cfg.UnknownLabel
+        if label == "" { label = metrics.DefaultUnknown }
+        fmt.Fprintf(b, "Missing fields: region %s, instance %s (counted as %q)\n", num(st.MissingRegion), num(st.MissingInstance), label)
     }
-    a.stats.SetText(b.String())
+    if st.LateDropped > 0 {
+        fmt.Fprintf(b, "Late dropped: %s (older than the retained timeline)\n", num(st.LateDropped))
+    }
+    if st.UnknownFields > 0 {
+        fmt.Fprintf(b, "Rejected: %s (unknown fields, --strict-json)\n", num(st.UnknownFields))
+    }
+    if a.cfg.Targets {
+        t := st.Targets
+        fmt.Fprintf(b, "Targets: %s  OK: %s (%s after retry)  Failing: %s  Rate: %s", num(t.Total), num(t.Succeeded), num(t.Recovered), num(t.Failed), a.rateText(t.Succeeded, t.Total))
+        if t.Untracked > 0 {
+            fmt.Fprintf(b, "  Untracked: %s (--max-targets)", num(t.Untracked))
+        }
+        b.WriteByte('\n')
+    }
+    // top regions, after any pinned ones; instances only once some are pinned
+    pinRegions, pinInstances := a.pins()
+    type table struct {
+        title string
+        m     map[string][2]int
+        pins  map[string]bool
+        share bool // one count column, as a share of successes
+    }
+    // the Ctrl-F filter narrows the region and instance tables on screen;
+    // exports stay whole
+    filtered := fit != nil && a.filter != ""
+    regions, instances := st.PerRegion, st.PerInstance
+    if filtered {
+        regions, instances = a.filterCounts(regions), a.filterCounts(instances)
+    }
+    tables := []table{{"Regions:", regions, pinRegions, false}}
+    if len(pinInstances) > 0 || filtered {
+        tables = append(tables, table{"Instances:", instances, pinInstances, false})
+    }
+    if a.group != nil {
+        tables = append(tables, table{"By " + a.group.Spec + ":", st.PerLabel, nil, false})
+    }
+    if fit != nil && a.reasons {
+        tables = append(tables, table{"Success reasons:", successReasonCounts(st.PerSuccessReason), nil, true})
+    }
+    used := strings.Count(b.String(), "\n")
+    rows := make([][]countRow, len(tables))
+    for i, t := range tables {
+        rows[i] = topRows(t.m, t.pins, fit.rows(used, len(tables), len(t.pins)))
+    }
+    w := fit.nameWidth(rows...)
+    for i, t := range tables {
+        fmt.Fprintln(b, t.title)
+        for _, it := range rows[i] {
+            mark := ' '
+            if it.pinned { mark = pinMark }
+            if t.share {
+                fmt.Fprintf(b, " %c%-*s S:%5s\n", mark, w, fit.name(it.key, w), a.countText(it.s, st.Success, num, pct))
+                continue
+            }
+            fmt.Fprintf(b, " %c%-*s S:%5s F:%5s\n", mark, w, fit.name(it.key, w), a.countText(it.s, total, num, pct), a.countText(it.f, total, num, pct))
+        }
+    }
+    return b.String()
+}
+
+// countText formats n as an absolute count (with num), or as a percentage of
+// total if pct.
+func (a *App) countText(n, total int, num func(int) string, pct bool) string {
+    if !pct {
+        return num(n)
+    }
+    if total == 0 {
+        return "-"
+    }
+    return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
+
+// rateText formats a success rate, or "warming up (n/N)" until the sample
+// holds at least MinSamples events so early swings don't look alarming.
+func (a *App) rateText(success, total int) string {
+    if total == 0 || total < a.cfg.MinSamples {
+        return fmt.Sprintf("warming up (%d/%d)", total, a.cfg.MinSamples)
+    }
+    return fmt.Sprintf("%.1f%%", 100*float64(success)/float64(total))
 }
 
 func (a *App) renderTimeline() {
     // Simple ASCII density chart across available width
     width := getWidth(a.timeline)
     height := getHeight(a.timeline)
-    if width < 20 { width = 20 }
-    if height < 4 { height = 4 }
-    data := a.agg.Timeline
+    if a.agg == nil {
+        return
+    }
+    data := a.viewBuckets()
     if len(data) == 0 {
         a.timeline.SetText("(no data)")
         return
     }
-    // limit to width-2 buckets
+    data, _ = a.filterBuckets(data)
+    // limit to width-2 buckets, leaving room for stacked series captions
     maxp := width - 2
+    stacked := a.stacked()
+    if len(stacked) > 0 && maxp-seriesLabelWidth >= 10 {
+        maxp -= seriesLabelWidth
+    }
     if len(data) > maxp { data = data[len(data)-maxp:] }
+    a.timelineCols = a.timelineCols[:0]
     maxv := 1
     for _, p := range data {
-        if v := p[1] + p[2]; v > maxv { maxv = v }
+        if v := a.plotValue(p); v > maxv { maxv = v }
+        a.timelineCols = append(a.timelineCols, p.Start)
     }
     // Build two rows: density and failure markers
-    chars := []rune(" .:-=+*#%@")
-    line1 := make([]rune, 0, len(data))
-    line2 := make([]rune, 0, len(data))
+    chars := densityChars
+    line1 := &strings.Builder{}
+    line2 := &strings.Builder{}
     for _, p := range data {
-        v := p[1] + p[2]
+        v := a.plotValue(p)
         idx := int(float64(len(chars)-1) * float64(v) / float64(maxv))
         ch := chars[idx]
-        if p[1] > 0 && p[2] == 0 { // success only
-            ch = 'S'
+        if a.plot == plotTotal && p.Success > 0 && p.Fail == 0 { // success only
+            fmt.Fprintf(line1, "[%s]%c[-]", rateColor(1), a.cfg.SuccessMark)
+        } else if a.cfg.RateColors && v > 0 {
+            fmt.Fprintf(line1, "[%s]%c[-]", rateColor(float64(p.Success)/float64(p.Total())), ch)
+        } else {
+            line1.WriteRune(ch)
+        }
+        if p.Fail > 0 && p.Success == 0 {
+            fmt.Fprintf(line2, "[%s]%c[-]", rateColor(0), a.cfg.FailMark)
+        } else if a.axis && axisTick(p, a.cfg.Bucket) {
+            line2.WriteString("[gray]┊[-]") // grid line down to the axis
+        } else {
+            line2.WriteByte(' ')
         }
-        line1 = append(line1, ch)
-        if p[2] > 0 && p[1] == 0 { line2 = append(line2, 'F') } else { line2 = append(line2, ' ') }
     }
     b := &strings.Builder{}
-    b.WriteString(string(line1))
-    b.WriteByte('\n')
-    b.WriteString(string(line2))
+    overlay, caption := a.overlayRow(data)
+    if overlay != "" {
+        b.WriteString("[aqua]" + overlay + "[-]\n")
+    }
+    if len(stacked) > 0 {
+        b.WriteString(a.stackedRows(stacked, data))
+    } else if a.cfg.ChartStyle == "health" {
+        rows := height - 1 // marks row
+        if overlay != "" { rows-- }
+        if a.legend { rows -= 2 }
+        b.WriteString(healthBars(data, a.plotValue, maxv, rows))
+    } else {
+        b.WriteString(line1.String())
+        b.WriteByte('\n')
+        b.WriteString(line2.String())
+    }
+    marks, labels := a.markRow(data, a.cfg.Bucket)
+    if marks != "" {
+        b.WriteString("\n[yellow]" + marks + "[-]")
+    }
+    if a.axis {
+        b.WriteString("\n[gray]" + axisRow(data, a.cfg.Bucket) + "[-]")
+    }
+    if a.legend {
+        b.WriteByte('\n')
+        if a.cfg.ChartStyle == "health" {
+            b.WriteString(healthLegend(maxv))
+        } else {
+            b.WriteString(tview.Escape(rampLegend(chars, maxv, a.cfg.SuccessMark, a.cfg.FailMark)))
+        }
+        if caption != "" {
+            b.WriteString("\n[aqua]" + string(sparkChars) + "[-] " + caption)
+        }
+        if len(labels) > 3 { labels = labels[len(labels)-3:] }
+        for _, l := range labels {
+            b.WriteString("\n^ " + tview.Escape(l))
+        }
+    }
     a.timeline.SetText(b.String())
 }
 
+// rateColor maps a bucket success rate in [0,1] onto a red -> yellow -> green
+// gradient as a tview hex color.
+func rateColor(rate float64) string {
+    r, g := 255, 255
+    if rate < 0.5 {
+        g = int(510 * rate)
+    } else {
+        r = int(510 * (1 - rate))
+    }
+    return fmt.Sprintf("#%02x%02x00", r, g)
+}
+
+// densityChars is the timeline's density ramp, empty to full.
+var densityChars = []rune(" .:-=+*#%@")
+
+// ParseMark validates a --success-mark/--fail-mark value: one visible
+// character, one column wide (a wide one would shift every column after it),
+// that isn't part of the density ramp or a color tag bracket.
+func ParseMark(s string) (rune, error) {
+    if utf8.RuneCountInString(s) != 1 {
+        return 0, fmt.Errorf("want a single character, got %q", s)
+    }
+    r, _ := utf8.DecodeRuneInString(s)
+    if !unicode.IsGraphic(r) || unicode.IsSpace(r) || r == '[' || r == ']' || strings.ContainsRune(string(densityChars), r) {
+        return 0, fmt.Errorf("%q can't be used: it's blank, a color tag bracket or in the density ramp %q", r, string(densityChars))
+    }
+    if w := runewidth.RuneWidth(r); w != 1 {
+        return 0, fmt.Errorf("%q is %d columns wide on a terminal, want 1", r, w)
+    }
+    return r, nil
+}
+
+// rampLegend maps each density character to the bucket totals it stands for
+// at the current scale, e.g. " =0 .=1-2 :=3-4 ... @=40  S=ok only F=fail only",
+// with ok and fail the success-only and fail-only marks.
+func rampLegend(chars []rune, maxv int, ok, fail rune) string {
+    n := len(chars) - 1
+    b := &strings.Builder{}
+    for i, ch := range chars {
+        // smallest v with int(n*v/maxv) == i, and the last before the next step
+        lo := (i*maxv + n - 1) / n
+        hi := ((i+1)*maxv+n-1)/n - 1
+        if i == n { hi = maxv }
+        if lo > hi { continue }
+        if b.Len() > 0 { b.WriteByte(' ') }
+        if lo == hi {
+            fmt.Fprintf(b, "%c=%d", ch, lo)
+        } else {
+            fmt.Fprintf(b, "%c=%d-%d", ch, lo, hi)
+        }
+    }
+    fmt.Fprintf(b, "  %c=ok only %c=fail only", ok, fail)
+    return b.String()
+}
+
 func getWidth(tv *tview.TextView) int {
     _, _, w, _ := tv.GetInnerRect()
-    if w <= 0 { w = 80 }
-    return w
+    if w <= 0 { w = 80 } // not laid out yet
+    return max(w, minPanelWidth)
 }
 func getHeight(tv *tview.TextView) int {
     _, _, _, h := tv.GetInnerRect()
     if h <= 0 { h = 10 }
-    return h
+    return max(h, minPanelHeight)
+}
+
+// instanceName derives a display name for a source file: the first capture
+// group of --name-regex (or the whole match if it has none), else the basename.
+func (a *App) instanceName(path string) string {
+    if re := a.cfg.NameRegex; re != nil {
+        if m := re.FindStringSubmatch(path); m != nil {
+            if len(m) > 1 && m[1] != "" {
+                return m[1]
+            }
+            if len(m) == 1 && m[0] != "" {
+                return m[0]
+            }
+        }
+    }
+    return filepathBase(path)
+}
+
+// logInstance is the instance a log file's lines belong to, in the key
+// space of the metrics tables (and so of the i view): the instance_id its
+// JSON lines carry, once one has been seen, else instanceName; normalized by
+// --instance-normalize either way. id is the current line's instance_id, if
+// any. Update loop only.
+func (a *App) logInstance(path, id string) string {
+    if id != "" {
+        if a.logIDs == nil {
+            a.logIDs = make(map[string]string)
+        }
+        a.logIDs[path] = id
+    } else if id = a.logIDs[path]; id == "" {
+        id = a.instanceName(path)
+    }
+    if a.agg != nil {
+        return a.agg.NormalizeInstance(id)
+    }
+    return id
+}
+
+// latestGroup is the file grouping for --latest-per-instance: by
+// instanceName, so a group is what the panes and tables call one instance.
+// Nil (read every match) when the option is off.
+func (a *App) latestGroup() func(string) string {
+    if !a.cfg.LatestOnly {
+        return nil
+    }
+    return a.instanceName
 }
 
 func filepathBase(p string) string {
@@ -271,72 +1383,347 @@ func filepathBase(p string) string {
     return p[i+1:]
 }
 
+func (a *App) newAggregator() *metrics.Aggregator {
+    agg := metrics.NewAggregator(a.cfg.MetricsGlob, a.cfg.Bucket, 72)
+    agg.InstanceName = a.instanceName
+    agg.TrackTargets = a.cfg.Targets
+    agg.RetainEvents(a.cfg.RetainEvents)
+    agg.HealthWeights = a.health
+    agg.InstanceNormalize = a.cfg.InstanceNorm
+    agg.MaxInstances = a.cfg.MaxInstances
+    agg.MaxRegions = a.cfg.MaxRegions
+    agg.MaxReasons = a.cfg.MaxReasons
+    agg.SuccessReasons = a.cfg.OKReasons
+    agg.SoftFailReasons = metrics.ReasonSet(a.cfg.SoftFail)
+    agg.UnknownLabel = a.cfg.UnknownLabel
+    agg.MaxTargets = a.cfg.MaxTargets
+    agg.Dedup(a.dedup, a.cfg.DedupWindow)
+    agg.Validate = a.cfg.Validate
+    agg.StrictJSON = a.cfg.StrictJSON
+    if a.cfg.StrictJSON && a.cfg.Debug {
+        agg.OnUnknown = func(path, field string) {
+            a.debugf("strict-json: %s: rejected entry with unknown field %q", path, field)
+        }
+    }
+    agg.ShrinkPolls = a.cfg.ShrinkPolls
+    agg.TrackSlowest(a.cfg.Slowest)
+    agg.RetainDuration = a.cfg.RetainDur
+    agg.PreFill = a.cfg.PreFill
+    agg.TrackReasonTrends(a.cfg.ReasonTrends)
+    agg.MaxFiles = a.cfg.MaxFiles
+    agg.Group = a.latestGroup()
+    agg.TrackBucketRegions(a.cfg.SQLite != "" || a.cfg.RemoteWrite != "")
+    agg.TrackBucketInstances(!a.cfg.Headless) // for the h heatmap
+    agg.GroupBy = a.group
+    agg.MaxLabels = a.cfg.MaxLabels
+    agg.DetectRestarts = a.cfg.AttemptReset
+    return agg
+}
+
 // Headless mode: periodically update aggregator and write snapshots without UI.
 func (a *App) runHeadless() error {
     a.logsTail = tail.NewReader(a.cfg.LogsGlob)
-    a.agg = metrics.NewAggregator(a.cfg.MetricsGlob, a.cfg.Bucket, 72)
+    a.logsTail.ShrinkPolls = a.cfg.ShrinkPolls
+    a.logsTail.MaxFiles = a.cfg.MaxFiles
+    a.logsTail.Group = a.latestGroup()
+    if a.cfg.TailOnly {
+        return a.runTailOnly()
+    }
+    a.agg = a.newAggregator()
+    if a.replay != nil {
+        go a.runReplay()
+    }
+    stop, err := a.startControl()
+    if err != nil {
+        return err
+    }
+    defer stop()
+    stopProm, err := a.startProm()
+    if err != nil {
+        return err
+    }
+    defer stopProm()
+    stopHistory, err := a.startHistory()
+    if err != nil {
+        return err
+    }
+    defer stopHistory()
+    stopRecord, err := a.startRecord()
+    if err != nil {
+        return err
+    }
+    defer stopRecord()
+    stopRemote, err := a.startRemoteWrite()
+    if err != nil {
+        return err
+    }
+    defer stopRemote()
+    stopCheckpoint, err := a.startCheckpoint()
+    if err != nil {
+        return err
+    }
+    defer stopCheckpoint()
     start := time.Now()
-    ticker := time.NewTicker(a.cfg.Refresh)
-    defer ticker.Stop()
+    backoff := newIdleBackoff(a.cfg)
+    timer := time.NewTimer(a.cfg.Refresh)
+    defer timer.Stop()
+    sigs := shutdownSignals()
+    defer signal.Stop(sigs)
     for {
         select {
-        case <-ticker.C:
-            a.agg.Update()
-            a.agg.EnsureBucketsTo(time.Now())
-            if a.cfg.SnapshotDir != "" { a.writeSnapshots() }
+        case <-sigs:
+            return nil
+        case <-timer.C:
+            if a.paused.Load() {
+                timer.Reset(a.cfg.Refresh)
+                continue
+            }
+            active := a.updateMetrics()
+            if a.cfg.SnapshotDir != "" {
+                if err := a.writeSnapshots(); err != nil && !a.snapshotsOK {
+                    return fmt.Errorf("writing snapshots: %w", err)
+                }
+            }
+            a.checkpointTick()
             if a.cfg.QuitAfter > 0 && time.Since(start) >= a.cfg.QuitAfter {
                 return nil
             }
+            timer.Reset(a.untilQuit(start, backoff.next(active)))
+        case req := <-a.ctl:
+            req.resp <- a.controlCommand(req.cmd, req.args)
+        }
+    }
+}
+
+// untilQuit shortens a backed-off wait so --quit-after isn't overshot.
+func (a *App) untilQuit(start time.Time, wait time.Duration) time.Duration {
+    if a.cfg.QuitAfter > 0 {
+        wait = max(min(wait, a.cfg.QuitAfter-time.Since(start)), 0)
+    }
+    return wait
+}
+
+// runTailOnly is headless --tail-only: a plain multi-file tail to stdout.
+func (a *App) runTailOnly() error {
+    stopCheckpoint, err := a.startCheckpoint()
+    if err != nil {
+        return err
+    }
+    defer stopCheckpoint()
+    start := time.Now()
+    backoff := newIdleBackoff(a.cfg)
+    timer := time.NewTimer(a.cfg.Refresh)
+    defer timer.Stop()
+    sigs := shutdownSignals()
+    defer signal.Stop(sigs)
+    for {
+        select {
+        case <-sigs:
+            return nil
+        case <-timer.C:
+        }
+        pairs := a.logsTail.ReadNew()
+        a.noteIgnored(0, "log", a.logsTail.Ignored)
+        if a.cfg.Interleave {
+            pairs = tail.Interleave(pairs)
+        }
+        for _, pair := range pairs {
+            fmt.Printf("[%s] %s\n", a.instanceName(pair[0]), pair[1])
         }
+        a.checkpointTick()
+        if a.cfg.QuitAfter > 0 && time.Since(start) >= a.cfg.QuitAfter {
+            return nil
+        }
+        timer.Reset(a.untilQuit(start, backoff.next(len(pairs) > 0)))
     }
 }
 
-func (a *App) writeSnapshots() {
+// shutdownSignals delivers SIGINT/SIGTERM so headless runs return normally,
+// running their deferred cleanup (final checkpoint, socket removal).
+func shutdownSignals() chan os.Signal {
+    sigs := make(chan os.Signal, 1)
+    signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+    return sigs
+}
+
+// writeSnapshots writes the snapshot set and returns the first write error.
+// Every failure is logged under --debug.
+func (a *App) writeSnapshots() error {
+    a.snapMu.Lock()
+    defer a.snapMu.Unlock()
+    first := a.writeSnapshotSet(a.cfg.SnapshotDir)
+    // logs snapshot is not tracked in headless by default
+    if first == nil {
+        a.snapshotsOK = true
+    }
+    a.snapshotResult(first)
+    return first
+}
+
+// writeSnapshotSet writes header/stats/timeline (or snapshot.md) and
+// snapshot.json into dir, returning the first error; call with snapMu held.
+func (a *App) writeSnapshotSet(dir string) error {
     // header.txt, stats.txt, timeline.txt, logs.txt (logs limited)
-    // (Errors ignored — best effort.)
-    pia := fmt.Sprintf("pia=%s:%s:%s", a.piaRegion, a.piaState, a.piaIP)
-    _ = writeFile(a.cfg.SnapshotDir+"/header.txt", fmt.Sprintf("%s | bucket=%ds | r=%.1fs\n", pia, a.cfg.Bucket, a.cfg.Refresh.Seconds()))
+    var first error
+    check := func(err error) {
+        if err == nil {
+            return
+        }
+        a.debugf("snapshot: %v", err)
+        if first == nil {
+            first = err
+        }
+    }
+    a.mu.Lock()
+    pia := a.piaText(false)
+    label := a.label
+    a.mu.Unlock()
+    if label != "" {
+        label = "label=" + label + " | "
+    }
+    st := a.agg.Snapshot()
+    snap := a.buildSnapshot(st)
+    if a.cfg.SnapFormat == "markdown" {
+        check(snapshot.WriteMarkdown(dir+"/snapshot.md", snap, a.timelineText(st.Buckets())))
+    } else {
+        check(writeFile(dir+"/header.txt", fmt.Sprintf("health=%d | %s%sbucket=%ds | r=%.1fs\n", st.Health, label, pia, st.BucketSecs, a.loopRefresh.Seconds())))
+        check(writeFile(dir+"/stats.txt", a.statsText(st, strconv.Itoa, nil, false, a.clockNow())))
+        check(writeFile(dir+"/timeline.txt", a.timelineText(st.Buckets())))
+    }
+    check(snapshot.Write(dir+"/snapshot.json", snap))
+    return first
+}
 
-    // stats
-    b := &strings.Builder{}
-    total := a.agg.Success + a.agg.Fail
-    fmt.Fprintf(b, "Total: %d  Success: %d  Fail: %d\n", total, a.agg.Success, a.agg.Fail)
-    if n := len(a.agg.Timeline); n > 0 {
-        last := a.agg.Timeline[n-1]
-        fmt.Fprintf(b, "Last %ds  S:%d F:%d\n", a.cfg.Bucket, last[1], last[2])
-    }
-    type kv struct{ key string; s, f int }
-    arr := make([]kv, 0, len(a.agg.PerRegion))
-    for k, v := range a.agg.PerRegion { arr = append(arr, kv{k, v[0], v[1]}) }
-    sort.Slice(arr, func(i, j int) bool { return (arr[i].s+arr[i].f) > (arr[j].s+arr[j].f) })
-    if len(arr) > 6 { arr = arr[:6] }
-    b.WriteString("Regions:\n")
-    for _, it := range arr {
-        fmt.Fprintf(b, "  %-18s S:%4d F:%4d\n", it.key, it.s, it.f)
-    }
-    _ = writeFile(a.cfg.SnapshotDir+"/stats.txt", b.String())
-
-    // timeline
-    // shallow render as in UI
+// snapshotFailAfter is how many consecutive failed snapshot sets it takes
+// to raise the SNAPSHOT FAILING warning, so a single transient error
+// doesn't.
+const snapshotFailAfter = 3
+
+// snapshotResult tracks consecutive snapshot failures once snapshots have
+// worked (earlier failures disable them instead): past snapshotFailAfter the
+// header (or stderr, headless) says so until a set is written again.
+func (a *App) snapshotResult(err error) {
+    if err == nil {
+        if a.snapFails >= snapshotFailAfter {
+            a.snapshotWarn("", fmt.Sprintf("snapshots: recovered after %d failed writes", a.snapFails))
+        }
+        a.snapFails = 0
+        return
+    }
+    a.noteWriteFailure()
+    if !a.snapshotsOK {
+        return
+    }
+    a.snapFails++
+    if a.snapFails < snapshotFailAfter {
+        return
+    }
+    msg := fmt.Sprintf("SNAPSHOT FAILING (%dx): %v", a.snapFails, err)
+    if errors.Is(err, syscall.ENOSPC) {
+        msg = fmt.Sprintf("SNAPSHOT FAILING (%dx): disk full: %v", a.snapFails, err)
+    }
+    // Headless prints on the first failure past the threshold only.
+    if a.snapFails == snapshotFailAfter || !a.cfg.Headless {
+        a.snapshotWarn(msg, msg)
+    }
+}
+
+// snapshotWarn sets (or, with "", clears) the header warning in the UI and
+// prints line to stderr when headless. Not subject to --quiet: failing
+// snapshots aren't best-effort.
+func (a *App) snapshotWarn(warning, line string) {
+    if a.cfg.Headless {
+        fmt.Fprintln(os.Stderr, line)
+        return
+    }
+    a.mu.Lock()
+    if warning != "" || strings.HasPrefix(a.warning, "SNAPSHOT FAILING") {
+        a.warning = warning
+    }
+    a.mu.Unlock()
+    if warning == "" {
+        a.setNotice(line)
+    }
+}
+
+// timelineText is the uncolored two-row timeline (density, failure markers)
+// over the last 80 buckets, as written to snapshots.
+func (a *App) timelineText(data []metrics.Bucket) string {
     maxp := 80
-    data := a.agg.Timeline
     if len(data) > maxp { data = data[len(data)-maxp:] }
     maxv := 1
-    for _, p := range data { if v := p[1]+p[2]; v > maxv { maxv = v } }
-    chars := []rune(" .:-=+*#%@")
+    for _, p := range data { if v := p.Total(); v > maxv { maxv = v } }
+    chars := densityChars
     l1 := make([]rune, 0, len(data))
     l2 := make([]rune, 0, len(data))
     for _, p := range data {
-        v := p[1] + p[2]
+        v := p.Total()
         idx := int(float64(len(chars)-1) * float64(v) / float64(maxv))
         ch := chars[idx]
-        if p[1] > 0 && p[2] == 0 { ch = 'S' }
+        if p.Success > 0 && p.Fail == 0 { ch = a.cfg.SuccessMark }
         l1 = append(l1, ch)
-        if p[2] > 0 && p[1] == 0 { l2 = append(l2, 'F') } else { l2 = append(l2, ' ') }
+        if p.Fail > 0 && p.Success == 0 { l2 = append(l2, a.cfg.FailMark) } else { l2 = append(l2, ' ') }
     }
-    _ = writeFile(a.cfg.SnapshotDir+"/timeline.txt", string(l1)+"\n"+string(l2))
+    return string(l1) + "\n" + string(l2)
+}
 
-    // logs snapshot is not tracked in headless by default
+// exportMarkdown writes snapshot.md on demand (the m key) into the snapshot
+// dir, or the working directory without one.
+func (a *App) exportMarkdown() {
+    if a.agg == nil {
+        return
+    }
+    dir := a.cfg.SnapshotDir
+    if dir == "" || a.snapshotsOff.Load() {
+        dir = "."
+    }
+    st := a.agg.Snapshot()
+    path := dir + "/snapshot.md"
+    if err := snapshot.WriteMarkdown(path, a.buildSnapshot(st), a.timelineText(st.Buckets())); err != nil {
+        a.setNotice("export failed: " + err.Error())
+    } else {
+        a.setNotice("wrote " + path)
+    }
+    a.updateHeader()
+}
+
+func (a *App) buildSnapshot(st metrics.Snapshot) *snapshot.Snapshot {
+    s := &snapshot.Snapshot{
+        Time:      time.Now().UTC(),
+        Bucket:    st.BucketSecs,
+        Total:     st.Success + st.Fail,
+        Success:   st.Success,
+        Fail:      st.Fail,
+        Regions:   make(map[string]snapshot.Counts, len(st.PerRegion)),
+        Instances: make(map[string]snapshot.Counts, len(st.PerInstance)),
+        Reasons:   make(map[string]int, len(st.PerReason)),
+        Health:    st.Health,
+        Label:     a.runLabel(),
+        Restarts:  st.Restarts,
+    }
+    if a.cfg.OKReasons {
+        s.SuccessReasons = st.PerSuccessReason
+    }
+    if a.cfg.SoftFail != "" {
+        s.SoftFail = &st.SoftFail
+    }
+    if a.cfg.Targets {
+        t := st.Targets
+        t.Expected = a.expectedTotal(t)
+        s.Targets = &t
+    }
+    for k, v := range st.PerRegion { s.Regions[k] = snapshot.Counts{Success: v[0], Fail: v[1]} }
+    for k, v := range st.PerInstance { s.Instances[k] = snapshot.Counts{Success: v[0], Fail: v[1]} }
+    for k, v := range st.PerReason { s.Reasons[k] = v }
+    if a.group != nil {
+        s.GroupBy = a.group.Spec
+        s.Labels = make(map[string]snapshot.Counts, len(st.PerLabel))
+        for k, v := range st.PerLabel { s.Labels[k] = snapshot.Counts{Success: v[0], Fail: v[1]} }
+    }
+    for _, ev := range a.agg.SlowestEvents(0) {
+        s.Slowest = append(s.Slowest, snapshot.Slow{Time: ev.Time.UTC(), ElapsedMS: ev.ElapsedMS, Instance: ev.InstanceID, Region: ev.BatchRegion, URL: ev.URL, Success: ev.Success, Reason: ev.Reason})
+    }
+    return s
 }
 
 func writeFile(path, content string) error {
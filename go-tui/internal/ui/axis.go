@@ -0,0 +1,59 @@
+package ui
+
+import (
+    "strings"
+
+    "secmon/internal/metrics"
+)
+
+// axisMinStep is the fewest columns between time axis ticks.
+const axisMinStep = 10
+
+// axisLayout picks the time format for the axis labels (seconds only when
+// buckets are shorter than a minute) and the tick spacing that fits them.
+func axisLayout(bucketSecs int) (string, int) {
+    layout := "15:04"
+    if bucketSecs < 60 {
+        layout = "15:04:05"
+    }
+    step := len(layout) + 2
+    if step < axisMinStep {
+        step = axisMinStep
+    }
+    return layout, step
+}
+
+// axisRow renders the g time axis under the timeline: a '|' tick on every
+// step'th bucket (by bucket number, so ticks move with the buckets as the
+// timeline scrolls), each followed by that bucket's start time.
+func axisRow(data []metrics.Bucket, bucketSecs int) string {
+    layout, _ := axisLayout(bucketSecs)
+    row := []rune(strings.Repeat(" ", len(data)))
+    for i, b := range data {
+        if !axisTick(b, bucketSecs) {
+            continue
+        }
+        row[i] = '|'
+        label := []rune(data[i].Start.Format(layout))
+        if i+1+len(label) > len(row) {
+            continue
+        }
+        copy(row[i+1:], label)
+    }
+    return string(row)
+}
+
+// axisTick reports whether bucket b's column carries an axis tick.
+func axisTick(b metrics.Bucket, bucketSecs int) bool {
+    if bucketSecs < 1 {
+        bucketSecs = 1
+    }
+    _, step := axisLayout(bucketSecs)
+    return (b.Start.Unix()/int64(bucketSecs))%int64(step) == 0
+}
+
+// toggleAxis shows or hides the g time axis and grid.
+func (a *App) toggleAxis() {
+    a.axis = !a.axis
+    a.renderTimeline()
+}
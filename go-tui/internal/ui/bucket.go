@@ -0,0 +1,88 @@
+package ui
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+
+    "secmon/internal/metrics"
+)
+
+// maxBucket bounds a bucket size typed at the b prompt ([ and ] stop at 120s).
+const maxBucket = time.Hour
+
+// parseBucket reads a bucket size: whole seconds ("60") or a Go duration
+// ("1m", "90s") of at least a second, in whole seconds, up to maxBucket.
+func parseBucket(s string) (int, error) {
+    s = strings.TrimSpace(s)
+    d, err := time.ParseDuration(s)
+    if n, nerr := strconv.Atoi(s); nerr == nil {
+        d, err = time.Duration(n)*time.Second, nil
+    }
+    if err != nil {
+        return 0, fmt.Errorf("want seconds or a duration like 1m, got %q", s)
+    }
+    if d < time.Second || d > maxBucket || d%time.Second != 0 {
+        return 0, fmt.Errorf("want whole seconds from 1s to %s, got %s", maxBucket, d)
+    }
+    return int(d / time.Second), nil
+}
+
+// columnOf returns the column of data holding a time, for buckets of secs
+// seconds, looked up by bucket start: data need not be contiguous (gaps,
+// skipped empty buckets), so an offset from data[0] lands in the wrong
+// column.
+func columnOf(data []metrics.Bucket, secs int) func(time.Time) (int, bool) {
+    cols := make(map[int64]int, len(data))
+    for i, b := range data {
+        cols[b.Start.Unix()] = i
+    }
+    return func(t time.Time) (int, bool) {
+        sec := t.Unix()
+        i, ok := cols[sec-sec%int64(secs)]
+        return i, ok
+    }
+}
+
+// buildBucketInput is the b prompt for an exact bucket size.
+func (a *App) buildBucketInput() *tview.Flex {
+    a.bucketInput = tview.NewInputField().SetLabel(" Bucket: ").SetFieldWidth(12).
+        SetPlaceholder("e.g. 60 or 5m")
+    a.bucketInput.SetDoneFunc(func(key tcell.Key) {
+        if key == tcell.KeyEnter {
+            sec, err := parseBucket(a.bucketInput.GetText())
+            if err != nil {
+                a.setNotice("bucket: " + err.Error())
+                a.updateHeader()
+                return // stay open to correct it
+            }
+            a.setBucket(sec)
+        }
+        a.closePrompt("bucket")
+    })
+    return promptPage(a.bucketInput)
+}
+
+// openBucket shows the b prompt holding the current bucket size.
+func (a *App) openBucket() {
+    if a.agg == nil {
+        return
+    }
+    a.openPrompt("bucket", a.bucketInput, strconv.Itoa(a.cfg.Bucket))
+}
+
+// setBucket applies a new bucket size ([, ], b): the timeline is rebuilt
+// from --retain-events if kept, and with --retain-duration the header notes
+// how many buckets now cover it.
+func (a *App) setBucket(sec int) {
+    if sec < 1 { sec = 1 }
+    a.cfg.Bucket = sec
+    a.agg.SetBucketSeconds(sec)
+    a.retainNotice()
+    a.updateHeader()
+    a.renderTimeline()
+}
@@ -0,0 +1,66 @@
+package ui
+
+import (
+    "fmt"
+    "time"
+
+    "secmon/internal/checkpoint"
+)
+
+// startCheckpoint resumes the log and metrics readers from --checkpoint and
+// returns a func that saves a final checkpoint on shutdown. Call once the
+// readers exist and before the first read.
+func (a *App) startCheckpoint() (func(), error) {
+    if a.cfg.Checkpoint == "" {
+        return func() {}, nil
+    }
+    st, err := checkpoint.Load(a.cfg.Checkpoint)
+    if err != nil {
+        return nil, fmt.Errorf("--checkpoint: %w", err)
+    }
+    n := a.logsTail.Resume(st.Logs)
+    if a.agg != nil && a.replay == nil {
+        n += a.agg.Resume(st.Metrics)
+    }
+    a.debugf("checkpoint: resumed %d files from %s", n, a.cfg.Checkpoint)
+    a.ckMu.Lock()
+    a.ckAt = time.Now()
+    a.ckMu.Unlock()
+    return a.saveCheckpoint, nil
+}
+
+// checkpointTick saves a checkpoint every --checkpoint-every.
+func (a *App) checkpointTick() {
+    if a.cfg.Checkpoint == "" {
+        return
+    }
+    a.ckMu.Lock()
+    due := time.Since(a.ckAt) >= a.cfg.CkEvery
+    a.ckMu.Unlock()
+    if due {
+        a.saveCheckpoint()
+    }
+}
+
+// saveCheckpoint writes every file's read position to --checkpoint. Failures
+// are warnings: the next save tries again.
+func (a *App) saveCheckpoint() {
+    a.ckMu.Lock()
+    defer a.ckMu.Unlock()
+    a.ckAt = time.Now()
+    s := &checkpoint.State{Saved: a.ckAt.UTC(), Logs: a.logsTail.Offsets()}
+    if a.agg != nil && a.replay == nil {
+        s.Metrics = a.agg.Offsets()
+    }
+    err := checkpoint.Save(a.cfg.Checkpoint, s)
+    if err == nil {
+        a.debugf("checkpoint: saved %d log and %d metrics offsets", len(s.Logs), len(s.Metrics))
+        return
+    }
+    a.noteWriteFailure()
+    if a.cfg.Headless {
+        a.warnf("checkpoint: %v", err)
+        return
+    }
+    a.setWarning("checkpoint: " + err.Error())
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+    "encoding/base64"
+    "errors"
+    "io"
+    "os"
+    "os/exec"
+    "runtime"
+    "strconv"
+    "strings"
+
+    "github.com/gdamore/tcell/v2"
+)
+
+// clipboardCmds are tried in order. A tool is only used on goos, if set,
+// and when env, if set, is non-empty (no X/Wayland display over plain SSH).
+var clipboardCmds = []struct {
+    goos string
+    env  string
+    args []string
+}{
+    {"darwin", "", []string{"pbcopy"}},
+    {"", "WAYLAND_DISPLAY", []string{"wl-copy"}},
+    {"", "DISPLAY", []string{"xclip", "-selection", "clipboard"}},
+    {"", "DISPLAY", []string{"xsel", "--clipboard", "--input"}},
+}
+
+// copyStats copies the stats panel text (the y key) and reports how in the
+// header. Runs off the UI goroutine since the tools can be slow to exit.
+func (a *App) copyStats() {
+    if a.agg == nil {
+        return
+    }
+    text := a.statsText(a.viewSnapshot(), strconv.Itoa, nil, a.showPct, a.fleetNow())
+    if a.deltas {
+        text = a.deltaText(nil)
+    }
+    go func() {
+        via, err := copyText(text)
+        if errors.Is(err, errNoClipboardTool) {
+            a.app.QueueUpdate(func() { a.copyOSC52(text) })
+            return
+        }
+        if err != nil {
+            a.setNotice("copy failed: " + err.Error())
+            return
+        }
+        a.setNotice("stats copied via " + via)
+    }()
+}
+
+var errNoClipboardTool = errors.New("no clipboard tool (pbcopy, wl-copy, xclip, xsel)")
+
+// copyText puts text on the system clipboard with the first available tool
+// and returns which, or errNoClipboardTool.
+func copyText(text string) (string, error) {
+    for _, c := range clipboardCmds {
+        if c.goos != "" && c.goos != runtime.GOOS {
+            continue
+        }
+        if c.env != "" && os.Getenv(c.env) == "" {
+            continue
+        }
+        if _, err := exec.LookPath(c.args[0]); err != nil {
+            continue
+        }
+        cmd := exec.Command(c.args[0], c.args[1:]...)
+        cmd.Stdin = strings.NewReader(text)
+        if err := cmd.Run(); err == nil {
+            return c.args[0], nil
+        }
+    }
+    return "", errNoClipboardTool
+}
+
+// copyOSC52 sends text to the terminal as an OSC 52 sequence (works over
+// SSH in terminals that support it) through tcell's tty, between frames so
+// it can't land in the middle of one; UI goroutine only.
+func (a *App) copyOSC52(text string) {
+    var tty tcell.Tty
+    ok := false
+    if a.screen.scr != nil {
+        tty, ok = a.screen.scr.Tty()
+    }
+    if !ok {
+        a.setNotice("copy failed: " + errNoClipboardTool.Error() + " and no terminal for OSC 52")
+        return
+    }
+    if _, err := io.WriteString(tty, osc52(text, os.Getenv("TMUX") != "")); err != nil {
+        a.setNotice("copy failed: " + err.Error())
+        return
+    }
+    a.setNotice("stats copied via OSC 52 (if the terminal allows it)")
+}
+
+// osc52 is the set-clipboard escape sequence for text, wrapped in tmux's
+// passthrough when running under tmux.
+func osc52(text string, tmux bool) string {
+    seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"
+    if tmux {
+        return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+    }
+    return seq
+}
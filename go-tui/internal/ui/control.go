@@ -0,0 +1,106 @@
+package ui
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+    "time"
+
+    "secmon/internal/control"
+)
+
+// controlHelp lists the --control-sock commands.
+const controlHelp = "commands: stats, regions, instances, reasons, reset, pause, resume, snapshot, help, quit"
+
+// startControl serves --control-sock, if set; the returned func closes it and
+// removes the socket.
+func (a *App) startControl() (func(), error) {
+    if a.cfg.ControlSock == "" {
+        return func() {}, nil
+    }
+    srv, err := control.Listen(a.cfg.ControlSock, a.handleControl)
+    if err != nil {
+        return nil, fmt.Errorf("control socket: %w", err)
+    }
+    return func() { srv.Close() }, nil
+}
+
+// controlReq is a control socket command handed to the update loop, which
+// owns the snapshot state the answers are built from.
+type controlReq struct {
+    cmd  string
+    args []string
+    resp chan string
+}
+
+// controlWait bounds how long a connection waits for the loop to take its
+// command (it may be mid-tick, or already shutting down).
+const controlWait = 5 * time.Second
+
+// handleControl answers one control socket command; called concurrently
+// from connection goroutines. pause, resume and help only touch atomics and
+// are answered here; the rest run on the update loop.
+func (a *App) handleControl(cmd string, args []string) string {
+    switch cmd {
+    case "pause", "resume", "help":
+        return a.controlCommand(cmd, args)
+    }
+    req := controlReq{cmd, args, make(chan string, 1)}
+    select {
+    case a.ctl <- req:
+    case <-time.After(controlWait):
+        return "error: busy, try again"
+    }
+    return <-req.resp
+}
+
+// controlCommand runs one command; on the update loop unless handleControl
+// says otherwise.
+func (a *App) controlCommand(cmd string, args []string) string {
+    if a.agg == nil && cmd != "pause" && cmd != "resume" && cmd != "help" {
+        return "error: no metrics (--tail-only)"
+    }
+    switch cmd {
+    case "stats":
+        s := *a.buildSnapshot(a.agg.Snapshot())
+        s.Regions, s.Instances, s.Reasons = nil, nil, nil
+        return jsonLine(s)
+    case "regions":
+        return jsonLine(a.buildSnapshot(a.agg.Snapshot()).Regions)
+    case "instances":
+        return jsonLine(a.buildSnapshot(a.agg.Snapshot()).Instances)
+    case "reasons":
+        return jsonLine(a.agg.Snapshot().PerReason)
+    case "reset":
+        a.agg.Reset()
+        return "ok"
+    case "pause":
+        a.paused.Store(true)
+        return "ok paused"
+    case "resume":
+        a.paused.Store(false)
+        return "ok resumed"
+    case "snapshot":
+        if a.cfg.SnapshotDir == "" {
+            return "error: no --snapshot-dir"
+        }
+        if a.snapshotsOff.Load() {
+            return "error: snapshots disabled"
+        }
+        if err := a.writeSnapshots(); err != nil {
+            return "error: " + err.Error()
+        }
+        return "ok " + a.cfg.SnapshotDir
+    case "help":
+        return controlHelp
+    }
+    return "error: unknown command " + strings.Join(append([]string{cmd}, args...), " ") + "; " + controlHelp
+}
+
+func jsonLine(v any) string {
+    b, err := json.Marshal(v)
+    if err != nil {
+        return "error: " + err.Error()
+    }
+    return string(b)
+}
@@ -0,0 +1,88 @@
+package ui
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// interval holds what changed between the last two metrics updates, for the
+// d (delta) view of the stats panel.
+type interval struct {
+    Success, Fail int
+    PerRegion     map[string][2]int
+    Secs          float64
+}
+
+// counts is the cumulative state interval deltas are taken against.
+type counts struct {
+    success, fail int
+    perRegion     map[string][2]int
+    at            time.Time
+}
+
+// recordInterval diffs st against the previous update. Counters that went
+// down (the control socket's reset) count from zero.
+func (a *App) recordInterval(st metrics.Snapshot, now time.Time) {
+    cur := counts{success: st.Success, fail: st.Fail, perRegion: st.PerRegion, at: now}
+    prev := a.prevCounts
+    a.prevCounts = cur
+    if prev.at.IsZero() {
+        return
+    }
+    iv := interval{
+        Success:   since(cur.success, prev.success),
+        Fail:      since(cur.fail, prev.fail),
+        PerRegion: make(map[string][2]int),
+        Secs:      now.Sub(prev.at).Seconds(),
+    }
+    for k, v := range cur.perRegion {
+        p := prev.perRegion[k]
+        if d := [2]int{since(v[0], p[0]), since(v[1], p[1])}; d[0]+d[1] > 0 {
+            iv.PerRegion[k] = d
+        }
+    }
+    a.mu.Lock()
+    a.interval = iv
+    a.mu.Unlock()
+}
+
+func since(cur, prev int) int {
+    if cur < prev {
+        return cur
+    }
+    return cur - prev
+}
+
+// deltaText renders the stats panel in delta mode: new successes/fails since
+// the previous refresh with their per-second rate, and the regions that
+// moved, as many as fit allows.
+func (a *App) deltaText(fit *tableFit) string {
+    a.mu.Lock()
+    iv := a.interval
+    a.mu.Unlock()
+    if a.frozen != nil {
+        iv = a.frozen.iv
+    }
+    b := &strings.Builder{}
+    if iv.Secs <= 0 {
+        b.WriteString("Since last refresh: (waiting for a second update)\n")
+        return b.String()
+    }
+    total := iv.Success + iv.Fail
+    fmt.Fprintf(b, "Since last refresh (%.1fs): +%s  Success: +%s (%s)  Fail: +%s (%s)  Rate: %s\n",
+        iv.Secs, a.num(total), a.num(iv.Success), perSec(iv.Success, iv.Secs), a.num(iv.Fail), perSec(iv.Fail, iv.Secs), a.rateText(iv.Success, total))
+    arr := topRows(iv.PerRegion, nil, fit.rows(1, 1, 0))
+    w := fit.nameWidth(arr)
+    fmt.Fprintln(b, "Regions:")
+    for _, it := range arr {
+        fmt.Fprintf(b, "  %-*s S:%5s F:%5s\n", w, fit.name(it.key, w), "+"+a.num(it.s), "+"+a.num(it.f))
+    }
+    return b.String()
+}
+
+func perSec(n int, secs float64) string {
+    return fmt.Sprintf("%.1f/s", float64(n)/secs)
+}
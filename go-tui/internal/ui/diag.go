@@ -0,0 +1,148 @@
+package ui
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/rivo/tview"
+
+    "secmon/internal/metrics"
+)
+
+// buildDiagView is the "diag" page: metrics input health for producer
+// authors, the proxy and rotation summary, per-field schema violations
+// under --validate, and per-reason failure trends.
+func (a *App) buildDiagView() *tview.TextView {
+    a.diag = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+    a.diag.SetBorder(true).SetTitle("Diagnostics (v or Esc to close)")
+    return a.diag
+}
+
+// togglePage switches between the main panels and an overlay page ("diag",
+// "slow", "key", "files", "heatmap").
+func (a *App) togglePage(page string) {
+    if name, _ := a.pages.GetFrontPage(); name == page {
+        a.pages.SwitchToPage("main")
+        return
+    }
+    a.pages.SwitchToPage(page)
+    a.renderDiag()
+    a.renderSlow()
+    a.renderKey()
+    a.renderFiles()
+    a.renderHeatmap()
+}
+
+func (a *App) renderDiag() {
+    if a.agg == nil {
+        return
+    }
+    if name, _ := a.pages.GetFrontPage(); name != "diag" {
+        return
+    }
+    a.diag.SetText(diagText(a.agg.Snapshot(), a.cfg.Validate) + a.trendText(getWidth(a.diag)))
+}
+
+// proxyText is the proxy and rotation summary: proxied share and failure
+// rate (against direct attempts), how often a failure led to a rotated
+// attempt, and how many rotated attempts then succeeded.
+func proxyText(p metrics.ProxyStats) string {
+    b := &strings.Builder{}
+    b.WriteString("\nProxy and rotation:\n")
+    if p.Proxied+p.Direct == 0 {
+        b.WriteString("  (no attempts yet)\n")
+        return b.String()
+    }
+    fmt.Fprintf(b, "  Proxied   %d of %d attempts (%s), %s failed; direct %s failed\n",
+        p.Proxied, p.Proxied+p.Direct, share(p.Proxied, p.Proxied+p.Direct), share(p.ProxyFail, p.Proxied), share(p.DirectFail, p.Direct))
+    fmt.Fprintf(b, "  Rotation  after %d of %d failures (%s) the next attempt had rotated\n",
+        p.RotatedAfter, p.FollowedFails, share(p.RotatedAfter, p.FollowedFails))
+    fmt.Fprintf(b, "  Recovery  %d of %d rotated attempts succeeded (%s)\n",
+        p.RotatedOK, p.Rotated, share(p.RotatedOK, p.Rotated))
+    return b.String()
+}
+
+// share formats n as a percentage of total, "-" when total is 0.
+func share(n, total int) string {
+    if total == 0 {
+        return "-"
+    }
+    return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
+
+// trendText renders one sparkline per tracked failure reason over the most
+// recent buckets that fit in width, each scaled to its own peak.
+func (a *App) trendText(width int) string {
+    if a.cfg.ReasonTrends <= 0 {
+        return ""
+    }
+    b := &strings.Builder{}
+    fmt.Fprintf(b, "\nFailure reason trends (%ds buckets, oldest left):\n", a.cfg.Bucket)
+    reasons := a.agg.TrendReasons()
+    if len(reasons) == 0 {
+        b.WriteString("(no failures yet)\n")
+        return b.String()
+    }
+    cols := width - 42
+    if cols < 10 { cols = 10 }
+    for _, r := range reasons {
+        counts := a.agg.ReasonTrend(r)
+        if len(counts) > cols { counts = counts[len(counts)-cols:] }
+        peak, last := 0, 0
+        for _, n := range counts { if n > peak { peak = n } }
+        if len(counts) > 0 { last = counts[len(counts)-1] }
+        fmt.Fprintf(b, "%-20.20s %s  now %d, peak %d\n", tview.Escape(r), trendLine(counts, peak), last, peak)
+    }
+    return b.String()
+}
+
+// trendLine is a sparkline of counts scaled to peak; empty buckets are
+// blank.
+func trendLine(counts []int, peak int) string {
+    out := make([]rune, len(counts))
+    for i, n := range counts {
+        out[i] = ' '
+        if n > 0 && peak > 0 {
+            out[i] = sparkChars[(len(sparkChars)-1)*n/peak]
+        }
+    }
+    return string(out)
+}
+
+// diagText renders the malformed count and, when validating, one row per
+// field ordered by violation count.
+func diagText(st metrics.Snapshot, validate bool) string {
+    b := &strings.Builder{}
+    lines := st.Success + st.Fail + st.DuplicatesSkipped + st.Malformed + st.UnknownFields
+    fmt.Fprintf(b, "Lines: %d  Unparseable: %d", lines, st.Malformed)
+    if st.UnknownFields > 0 {
+        fmt.Fprintf(b, "  Unknown fields: %d", st.UnknownFields)
+    }
+    b.WriteByte('\n')
+    b.WriteString(proxyText(st.Proxy))
+    if !validate {
+        b.WriteString("\n(start with --validate for per-field schema violations)\n")
+        return b.String()
+    }
+    fmt.Fprintf(b, "Lines violating the schema: %d\n\n", st.InvalidLines)
+    if len(st.Violations) == 0 {
+        b.WriteString("[green]no violations[-]\n")
+        return b.String()
+    }
+    keys := make([]string, 0, len(st.Violations))
+    for k := range st.Violations { keys = append(keys, k) }
+    sort.Slice(keys, func(i, j int) bool {
+        ti, tj := st.Violations[keys[i]].Total(), st.Violations[keys[j]].Total()
+        if ti != tj {
+            return ti > tj
+        }
+        return keys[i] < keys[j]
+    })
+    fmt.Fprintf(b, "%-20s %9s %10s\n", "Field", "Missing", "WrongType")
+    for _, k := range keys {
+        v := st.Violations[k]
+        fmt.Fprintf(b, "%-20s %9d %10d\n", tview.Escape(k), v.Missing, v.WrongType)
+    }
+    return b.String()
+}
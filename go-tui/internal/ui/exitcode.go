@@ -0,0 +1,36 @@
+package ui
+
+// Headless exit codes (--exit-codes). Run returning an error exits 1. When
+// more than one applies, the first listed here wins: a run that saw no data
+// checked nothing, and one whose writes failed left incomplete output.
+const (
+    ExitClean     = 0
+    ExitAlert     = 2 // an alert was active at exit
+    ExitNoData    = 3 // no metrics entry was ingested during the run
+    ExitWriteFail = 4 // a snapshot, checkpoint, --sqlite, --record or incident write failed
+)
+
+// ExitCode is the outcome of a headless run that returned without error,
+// for main to exit with; 0 for the UI and --tail-only.
+func (a *App) ExitCode() int {
+    if !a.cfg.Headless || a.agg == nil {
+        return ExitClean
+    }
+    a.mu.Lock()
+    alerting := a.alertStatus.Any()
+    a.mu.Unlock()
+    switch {
+    case !a.sawData:
+        return ExitNoData
+    case a.writeFailed.Load():
+        return ExitWriteFail
+    case alerting:
+        return ExitAlert
+    }
+    return ExitClean
+}
+
+// noteWriteFailure remembers that an output write failed, for ExitCode.
+func (a *App) noteWriteFailure() {
+    a.writeFailed.Store(true)
+}
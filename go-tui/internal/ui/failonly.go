@@ -0,0 +1,45 @@
+package ui
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+)
+
+// logFields decodes what the logs panes use from a line that is a JSON
+// entry: whether it is a failure ("success": false) and its instance_id.
+// Anything else (plain text, entries without the fields) has neither.
+func logFields(line string) (fail bool, id string) {
+    s := strings.TrimSpace(line)
+    if !strings.HasPrefix(s, "{") {
+        return false, ""
+    }
+    var v struct {
+        Success    *bool  `json:"success"`
+        InstanceID string `json:"instance_id"`
+    }
+    if json.Unmarshal([]byte(s), &v) != nil {
+        return false, ""
+    }
+    return v.Success != nil && !*v.Success, v.InstanceID
+}
+
+// toggleFailOnly switches the logs panes between every line and failures
+// only (the f key), leaving a marker line where the mode changed. Lines
+// hidden while it is on are dropped, not buffered: switching back shows new
+// lines from then on, not the successes that were skipped.
+func (a *App) toggleFailOnly() {
+    a.failOnly = !a.failOnly
+    marker := "--- all lines ---"
+    if a.failOnly {
+        marker = "--- failures only (f shows all) ---"
+    }
+    fmt.Fprintln(a.logs, marker)
+    for _, tv := range a.splitPanes {
+        fmt.Fprintln(tv, marker)
+    }
+    if a.splitOthers != nil {
+        fmt.Fprintln(a.splitOthers, marker)
+    }
+    a.setTitles()
+}
@@ -0,0 +1,100 @@
+package ui
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/rivo/tview"
+
+    "secmon/internal/tail"
+)
+
+// fileColors are the status dot colors on the files page, by tail.FileHealth.
+var fileColors = [...]string{
+    tail.FileActive:  "green",
+    tail.FileStalled: "yellow",
+    tail.FileRotated: "aqua",
+    tail.FileError:   "red",
+}
+
+// fileOrder lists problems first: errors, stalled, rotated, then active.
+var fileOrder = [...]int{
+    tail.FileError:   0,
+    tail.FileStalled: 1,
+    tail.FileRotated: 2,
+    tail.FileActive:  3,
+}
+
+// buildFilesView is the "files" page: each tailed log file with a status dot,
+// so the instances that stopped logging stand out.
+func (a *App) buildFilesView() *tview.TextView {
+    a.files = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+    a.files.SetBorder(true).SetTitle("Log files (F or Esc to close)")
+    return a.files
+}
+
+func (a *App) renderFiles() {
+    if name, _ := a.pages.GetFrontPage(); name != "files" {
+        return
+    }
+    a.ckMu.Lock()
+    st := a.logsTail.Status()
+    a.ckMu.Unlock()
+    a.files.SetText(a.filesText(st, time.Now()))
+}
+
+// filesText lists the log files the last poll read, problems first, under
+// a count per status. A file is stalled once nothing new has arrived for
+// --stale-after, and shows as rotated for as long after a rotation.
+func (a *App) filesText(st []tail.FileStatus, now time.Time) string {
+    if len(st) == 0 {
+        return "(no log files matched yet)"
+    }
+    stall := a.cfg.StaleAfter
+    health := make([]tail.FileHealth, len(st))
+    idx := make([]int, len(st))
+    var counts [len(fileColors)]int
+    for i, s := range st {
+        health[i] = s.Health(now, stall)
+        counts[health[i]]++
+        idx[i] = i
+    }
+    sort.SliceStable(idx, func(i, j int) bool { return fileOrder[health[idx[i]]] < fileOrder[health[idx[j]]] })
+    w := 0
+    for _, s := range st {
+        w = max(w, len(s.Path))
+    }
+    b := &strings.Builder{}
+    for h, n := range counts {
+        fmt.Fprintf(b, "[%s]●[-] %s %s  ", fileColors[h], a.num(n), tail.FileHealth(h))
+    }
+    if stall > 0 {
+        fmt.Fprintf(b, "(stalled: nothing new for %s)", stall)
+    }
+    b.WriteString("\n\n")
+    for _, i := range idx {
+        s := st[i]
+        fmt.Fprintf(b, "[%s]●[-] %-7s  %-*s  ", fileColors[health[i]], health[i], w, tview.Escape(s.Path))
+        if s.LastRead.IsZero() {
+            b.WriteString("nothing read yet")
+        } else {
+            fmt.Fprintf(b, "read %s ago", now.Sub(s.LastRead).Round(time.Second))
+        }
+        if s.Behind > 0 {
+            fmt.Fprintf(b, "  %s B behind", a.num(int(s.Behind)))
+        }
+        if !s.Rotated.IsZero() {
+            fmt.Fprintf(b, "  rotated %s", s.Rotated.Format("15:04:05"))
+        }
+        if s.Pipe {
+            b.WriteString("  (pipe)")
+        }
+        if s.Err != nil {
+            fmt.Fprintf(b, "  [red]%s[-]", tview.Escape(s.Err.Error()))
+        }
+        b.WriteByte('\n')
+    }
+    return b.String()
+}
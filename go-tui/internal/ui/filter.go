@@ -0,0 +1,104 @@
+package ui
+
+import (
+    "path"
+    "strings"
+
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+
+    "secmon/internal/metrics"
+)
+
+// buildFilterInput is the Ctrl-F region/instance filter prompt.
+func (a *App) buildFilterInput() *tview.Flex {
+    a.filterInput = tview.NewInputField().SetLabel(" Filter: ").SetFieldWidth(40).
+        SetPlaceholder("region or instance substring, or a glob like us-*")
+    a.filterInput.SetChangedFunc(func(text string) { a.setFilter(text) })
+    a.filterInput.SetDoneFunc(func(key tcell.Key) {
+        if key == tcell.KeyEscape {
+            a.setFilter("")
+        }
+        a.closePrompt("filter") // Enter keeps the filter, Esc has cleared it
+    })
+    return promptPage(a.filterInput)
+}
+
+// openFilter shows the filter input holding the current filter.
+func (a *App) openFilter() {
+    if a.agg == nil {
+        return
+    }
+    a.openPrompt("filter", a.filterInput, a.filter)
+}
+
+// setFilter applies a filter as it's typed.
+func (a *App) setFilter(text string) {
+    a.filter = strings.TrimSpace(text)
+    a.setTitles()
+    a.updateHeader()
+    a.renderStats()
+    a.renderTimeline()
+}
+
+// filterMatch reports whether key passes filter: a case-insensitive
+// substring, or a glob when filter has any of *?[. An empty filter passes
+// everything and a malformed glob nothing.
+func filterMatch(filter, key string) bool {
+    if filter == "" {
+        return true
+    }
+    filter, key = strings.ToLower(filter), strings.ToLower(key)
+    if !strings.ContainsAny(filter, "*?[") {
+        return strings.Contains(key, filter)
+    }
+    ok, err := path.Match(filter, key)
+    return err == nil && ok
+}
+
+// filterCounts returns the entries of m whose key passes the filter.
+func (a *App) filterCounts(m map[string][2]int) map[string][2]int {
+    if a.filter == "" {
+        return m
+    }
+    out := make(map[string][2]int)
+    for k, v := range m {
+        if filterMatch(a.filter, k) {
+            out[k] = v
+        }
+    }
+    return out
+}
+
+// filterBuckets recounts data from the retained events whose region or
+// instance passes the filter, reporting false (data unchanged) without
+// --retain-events.
+func (a *App) filterBuckets(data []metrics.Bucket) ([]metrics.Bucket, bool) {
+    if a.filter == "" || len(data) == 0 {
+        return data, true
+    }
+    if a.cfg.RetainEvents <= 0 {
+        return data, false
+    }
+    evs := a.agg.EventsWhere(func(ev metrics.Event) bool {
+        return !ev.Time.Before(data[0].Start) && (filterMatch(a.filter, ev.BatchRegion) || filterMatch(a.filter, ev.InstanceID))
+    })
+    col := columnOf(data, a.cfg.Bucket)
+    out := make([]metrics.Bucket, len(data))
+    for i, b := range data {
+        out[i] = metrics.Bucket{Start: b.Start}
+    }
+    for _, ev := range evs {
+        i, ok := col(ev.Time)
+        if !ok {
+            continue
+        }
+        if ev.Success {
+            out[i].Success++
+        } else {
+            out[i].Fail++
+        }
+    }
+    return out, true
+}
+
@@ -0,0 +1,282 @@
+package ui
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+
+    "secmon/internal/metrics"
+)
+
+// recentLogLines bounds the backlog replayed into the focus log pane.
+const recentLogLines = 2000
+
+type logLine struct {
+    inst, text string // inst: see logInstance
+}
+
+// focusView is the single-instance drill-down: pick an instance, then see its
+// counts, reasons, latency, mini timeline and only its log lines. Naming a
+// region instead opens the same view for the region, with its latency
+// percentiles and the log lines of the instances seen in it.
+type focusView struct {
+    root    *tview.Flex
+    input   *tview.InputField
+    stats   *tview.TextView
+    logs    *tview.TextView
+    id      string          // focused instance or region; "" while picking
+    region  bool            // id is a region
+    members map[string]bool // region view: instances whose logs are shown
+}
+
+func (a *App) buildFocusView() *tview.Flex {
+    f := &a.focus
+    f.input = tview.NewInputField().SetLabel(" Instance or region: ").SetFieldWidth(40)
+    f.input.SetAutocompleteFunc(func(cur string) []string {
+        if a.agg == nil {
+            return nil
+        }
+        st := a.agg.Snapshot()
+        var out []string
+        for _, m := range []map[string][2]int{st.PerInstance, st.PerRegion} {
+            for k := range m {
+                if strings.Contains(k, cur) {
+                    out = append(out, k)
+                }
+            }
+        }
+        sort.Strings(out)
+        return out
+    })
+    f.input.SetDoneFunc(func(key tcell.Key) {
+        switch key {
+        case tcell.KeyEnter:
+            a.setFocusInstance(strings.TrimSpace(f.input.GetText()))
+        case tcell.KeyEscape:
+            a.exitFocus()
+        }
+    })
+    f.stats = tview.NewTextView().SetDynamicColors(true)
+    f.stats.SetBorder(true).SetTitle("Instance")
+    f.logs = tview.NewTextView().SetDynamicColors(a.logColors()).SetScrollable(true).SetMaxLines(a.cfg.MaxLogLines)
+    f.logs.SetBorder(true).SetTitle("Instance logs")
+
+    body := tview.NewFlex().SetDirection(tview.FlexColumn)
+    body.AddItem(f.logs, 0, 3, false)
+    body.AddItem(f.stats, 0, 2, false)
+    f.root = tview.NewFlex().SetDirection(tview.FlexRow)
+    f.root.AddItem(f.input, 1, 0, true)
+    f.root.AddItem(body, 0, 1, false)
+    return f.root
+}
+
+// enterFocus opens the drill-down, pre-filled with the instance that has the
+// most failures.
+func (a *App) enterFocus() {
+    if a.agg == nil {
+        return
+    }
+    worst, wf := "", -1
+    for k, v := range a.agg.Snapshot().PerInstance {
+        if v[1] > wf || (v[1] == wf && k < worst) {
+            worst, wf = k, v[1]
+        }
+    }
+    a.focus.input.SetText(worst)
+    a.focus.id, a.focus.region, a.focus.members = "", false, nil
+    a.focus.logs.Clear()
+    a.focus.stats.SetText("Type an instance or region name and press Enter (Esc to go back)")
+    a.pages.SwitchToPage("focus")
+    a.app.SetFocus(a.focus.input)
+}
+
+// focusOn opens the drill-down straight on instance id.
+func (a *App) focusOn(id string) {
+    a.focus.input.SetText(id)
+    a.pages.SwitchToPage("focus")
+    a.setFocusInstance(id)
+}
+
+func (a *App) exitFocus() {
+    a.focus.id, a.focus.region, a.focus.members = "", false, nil
+    a.pages.SwitchToPage("main")
+    a.app.SetFocus(a.pages)
+}
+
+func (a *App) setFocusInstance(id string) {
+    if id == "" {
+        return
+    }
+    st := a.agg.Snapshot()
+    _, isInstance := st.PerInstance[id]
+    _, isRegion := st.PerRegion[id]
+    a.focus.id, a.focus.region, a.focus.members = id, isRegion && !isInstance, nil
+    a.focus.stats.SetTitle("Instance " + id)
+    if a.focus.region {
+        a.focus.stats.SetTitle("Region " + id)
+        a.focus.members = make(map[string]bool)
+        a.noteMembers(a.agg.EventsForRegion(id))
+    }
+    a.focus.logs.Clear()
+    for _, l := range a.recentLogs {
+        if a.focusShows(l.inst) {
+            fmt.Fprintln(a.focus.logs, l.text)
+        }
+    }
+    a.focus.logs.ScrollToEnd()
+    a.app.SetFocus(a.focus.stats)
+    a.renderFocus()
+}
+
+// focusLog mirrors a log line of instance inst into the focus pane and the
+// recent backlog.
+func (a *App) focusLog(inst, line string) {
+    a.recentLogs = append(a.recentLogs, logLine{inst, line})
+    if len(a.recentLogs) > recentLogLines {
+        a.recentLogs = a.recentLogs[len(a.recentLogs)-recentLogLines:]
+    }
+    if a.focus.id != "" && a.focusShows(inst) {
+        fmt.Fprintln(a.focus.logs, line)
+    }
+}
+
+// noteMembers adds the instances of a region's retained entries to the
+// region view's members. Event instance IDs are already normalized, the
+// key space logInstance files log lines under.
+func (a *App) noteMembers(evs []metrics.Event) {
+    for _, ev := range evs {
+        a.focus.members[ev.InstanceID] = true
+    }
+}
+
+// focusShows reports whether the focus logs pane shows lines of instance
+// inst: the focused instance's, or in the region view its members'.
+func (a *App) focusShows(inst string) bool {
+    if a.focus.region {
+        return a.focus.members[inst]
+    }
+    return inst == a.focus.id
+}
+
+func (a *App) renderFocus() {
+    id := a.focus.id
+    if id == "" || a.agg == nil {
+        return
+    }
+    st := a.agg.Snapshot()
+    c, evs := st.PerInstance[id], a.agg.EventsForInstance(id)
+    if a.focus.region {
+        c, evs = st.PerRegion[id], a.agg.EventsForRegion(id)
+        a.noteMembers(evs) // instances that joined since the view opened
+    }
+    b := &strings.Builder{}
+    fmt.Fprintf(b, "Success: %d  Fail: %d  Rate: %s\n", c[0], c[1], a.rateText(c[0], c[0]+c[1]))
+    if n := st.Restarts[id]; n > 0 && !a.focus.region {
+        fmt.Fprintf(b, "Restarts: %d\n", n)
+    }
+    if a.focus.region {
+        a.regionLatencyText(b, st, id)
+    }
+    if evs == nil {
+        b.WriteString("\n(start with --retain-events N for reasons, latency and a timeline)\n")
+        a.focus.stats.SetText(b.String())
+        return
+    }
+    reasons := map[string]int{}
+    lat := make([]int, 0, len(evs))
+    for _, ev := range evs {
+        if !ev.Success {
+            r := ev.Reason
+            if r == "" { r = "unknown" }
+            reasons[r]++
+        }
+        if ev.ElapsedMS > 0 { lat = append(lat, ev.ElapsedMS) }
+    }
+    if len(lat) > 0 && !a.focus.region {
+        sort.Ints(lat)
+        fmt.Fprintf(b, "Latency ms  p50:%d p90:%d p99:%d  (n=%d)\n", pct(lat, 50), pct(lat, 90), pct(lat, 99), len(lat))
+    }
+    type kv struct{ key string; n int }
+    arr := make([]kv, 0, len(reasons))
+    for k, n := range reasons { arr = append(arr, kv{k, n}) }
+    sort.Slice(arr, func(i, j int) bool { return arr[i].n > arr[j].n || (arr[i].n == arr[j].n && arr[i].key < arr[j].key) })
+    if len(arr) > 8 { arr = arr[:8] }
+    fmt.Fprintln(b, "Recent fail reasons:")
+    for _, it := range arr {
+        fmt.Fprintf(b, "  %-24s %d\n", tview.Escape(it.key), it.n)
+    }
+    width := getWidth(a.focus.stats) - 2
+    if width < 10 { width = 10 }
+    fmt.Fprintf(b, "Timeline (%ds buckets):\n%s\n", st.BucketSecs, miniTimeline(evs, st.BucketSecs, width))
+    a.focus.stats.SetText(b.String())
+}
+
+// regionLatencyText writes the region view's latency table: every region
+// with samples, slowest p90 first, the focused one marked with '>'. Region
+// percentiles don't need --retain-events.
+func (a *App) regionLatencyText(b *strings.Builder, st metrics.Snapshot, focused string) {
+    type row struct {
+        region string
+        lp     metrics.LatencyPercentiles
+    }
+    var rows []row
+    for r := range st.PerRegion {
+        if lp, ok := a.agg.RegionLatency(r); ok {
+            rows = append(rows, row{r, lp})
+        }
+    }
+    if len(rows) == 0 {
+        return
+    }
+    sort.Slice(rows, func(i, j int) bool {
+        if rows[i].lp.P90 != rows[j].lp.P90 {
+            return rows[i].lp.P90 > rows[j].lp.P90
+        }
+        return rows[i].region < rows[j].region
+    })
+    for i, r := range rows {
+        if i >= 8 && r.region == focused {
+            rows[7] = r // keep the focused region listed
+        }
+    }
+    if len(rows) > 8 { rows = rows[:8] }
+    fmt.Fprintln(b, "Latency ms by region:     p50    p90    p99   fail")
+    for _, r := range rows {
+        mark := ' '
+        if r.region == focused { mark = '>' }
+        fmt.Fprintf(b, "%c %-20s %6d %6d %6d %6d\n", mark, tview.Escape(r.region), r.lp.P50, r.lp.P90, r.lp.P99, st.PerRegion[r.region][1])
+    }
+}
+
+// pct returns the p-th percentile of sorted values.
+func pct(sorted []int, p float64) int {
+    return sorted[int(p/100*float64(len(sorted)-1))]
+}
+
+// miniTimeline buckets events and renders one density character per bucket
+// for the last width buckets ending at the newest event.
+func miniTimeline(evs []metrics.Event, bucketSecs, width int) string {
+    if len(evs) == 0 || bucketSecs < 1 {
+        return ""
+    }
+    step := time.Duration(bucketSecs) * time.Second
+    end := evs[len(evs)-1].Time.Truncate(step)
+    counts := make([]int, width)
+    maxv := 1
+    for _, ev := range evs {
+        i := width - 1 - int(end.Sub(ev.Time.Truncate(step))/step)
+        if i < 0 || i >= width { continue }
+        counts[i]++
+        if counts[i] > maxv { maxv = counts[i] }
+    }
+    chars := []rune(" .:-=+*#%@")
+    out := make([]rune, width)
+    for i, v := range counts {
+        out[i] = chars[int(float64(len(chars)-1)*float64(v)/float64(maxv))]
+    }
+    return string(out)
+}
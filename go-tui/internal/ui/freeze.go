@@ -0,0 +1,111 @@
+package ui
+
+import (
+    "strings"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// frozenView is what the stats and timeline panels show while t has them
+// frozen; ingestion and the logs carry on underneath.
+type frozenView struct {
+    st metrics.Snapshot
+    iv interval
+    at time.Time // the clock (see fleetNow) when frozen
+}
+
+// toggleFreeze freezes the stats and timeline panels on the current counts,
+// or unfreezes them, catching up on everything ingested meanwhile.
+func (a *App) toggleFreeze() {
+    if a.agg == nil {
+        return
+    }
+    if a.frozen != nil {
+        a.frozen = nil
+    } else {
+        a.mu.Lock()
+        iv := a.interval
+        a.mu.Unlock()
+        a.frozen = &frozenView{st: a.agg.Snapshot(), iv: iv, at: a.fleetNow()}
+    }
+    a.setTitles()
+    a.renderStats()
+    a.renderTimeline()
+}
+
+// viewSnapshot is the snapshot the stats panel renders: the frozen one, or
+// the live counts.
+func (a *App) viewSnapshot() metrics.Snapshot {
+    if a.frozen != nil {
+        return a.frozen.st
+    }
+    return a.agg.Snapshot()
+}
+
+// viewBuckets is the timeline panel's counterpart of viewSnapshot.
+func (a *App) viewBuckets() []metrics.Bucket {
+    if a.frozen != nil {
+        return a.frozen.st.Buckets()
+    }
+    return a.agg.Buckets()
+}
+
+// setTitles titles the stats and timeline panels after the modes that
+// change what they show (d, o, t, x, f, n, Ctrl-F).
+func (a *App) setTitles() {
+    stats, timeline := "Stats", "Timeline"
+    if a.deltas {
+        stats += " (since last refresh)"
+    }
+    if st := a.stacked(); len(st) > 0 {
+        timeline += ": " + strings.Join(st, ", ")
+    } else if a.plot != plotTotal {
+        timeline += " [" + plotNames[a.plot] + ", x cycles]"
+    }
+    if a.overlay != overlayNone {
+        timeline += " + " + overlayNames[a.overlay]
+    }
+    if a.filter != "" {
+        stats += " [filter " + a.filter + "]"
+        if a.cfg.RetainEvents > 0 {
+            timeline += " [filter " + a.filter + "]"
+        } else {
+            timeline += " [unfiltered: filtering needs --retain-events]"
+        }
+    }
+    if a.frozen != nil {
+        stats += " [frozen, t resumes]"
+        timeline += " [frozen, t resumes]"
+    }
+    logs := "Logs" + a.sampleTitle()
+    if a.failOnly {
+        logs += " [failures only, f shows all]"
+    }
+    if a.lineNumbers {
+        logs += " [numbered]"
+    }
+    a.logs.SetTitle(logs)
+    a.stats.SetTitle(stats)
+    a.timeline.SetTitle(timeline)
+}
+
+// fleetNow is the time instance/region activity is judged against on
+// screen: when the panels were frozen, else clockNow. UI goroutine only.
+func (a *App) fleetNow() time.Time {
+    if a.frozen != nil {
+        return a.frozen.at
+    }
+    return a.clockNow()
+}
+
+// clockNow is the replay clock, or now; exports judge activity against it
+// whatever the panels show.
+func (a *App) clockNow() time.Time {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    if a.replay != nil && !a.replayAt.IsZero() {
+        return a.replayAt
+    }
+    return time.Now()
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// Success-rate gauge: bar width bounds (the fixed width is for exports) and
+// the color bands.
+const (
+    gaugeWidth    = 20
+    gaugeMaxWidth = 40
+    gaugeGreen    = 90.0 // percent and up
+    gaugeYellow   = 70.0
+)
+
+// windowCounts sums the buckets, current one included, covering the trailing
+// window.
+func windowCounts(bs []metrics.Bucket, window time.Duration, bucketSecs int) (success, total int) {
+    n := len(bs)
+    if bucketSecs > 0 {
+        n = int((window + time.Duration(bucketSecs)*time.Second - 1) / (time.Duration(bucketSecs) * time.Second))
+        if n < 1 { n = 1 }
+    }
+    if n > len(bs) { n = len(bs) }
+    for _, b := range bs[len(bs)-n:] {
+        success += b.Success
+        total += b.Total()
+    }
+    return success, total
+}
+
+// gaugeText renders the success rate over --alert-window as a meter, e.g.
+// "Rate 1m0s [████████░░] 82.3%", colored and sized to fit on screen;
+// exports (nil fit) get a plain gaugeWidth meter.
+func (a *App) gaugeText(st metrics.Snapshot, fit *tableFit) string {
+    success, total := windowCounts(st.Buckets(), a.cfg.AlertWindow, st.BucketSecs)
+    label := fmt.Sprintf("Rate %s ", a.cfg.AlertWindow)
+    if total == 0 || total < a.cfg.MinSamples {
+        return label + a.rateText(success, total)
+    }
+    rate := 100 * float64(success) / float64(total)
+    w := gaugeWidth
+    if fit != nil {
+        w = fit.width - len(label) - len(" [] 100.0%")
+        if w > gaugeMaxWidth { w = gaugeMaxWidth }
+    }
+    pct := fmt.Sprintf("%.1f%%", rate)
+    if w < 5 {
+        return label + pct
+    }
+    color := "red"
+    switch {
+    case rate >= gaugeGreen:
+        color = "green"
+    case rate >= gaugeYellow:
+        color = "yellow"
+    }
+    fill := int(float64(w)*rate/100 + 0.5)
+    bar := strings.Repeat("█", fill) + strings.Repeat("░", w-fill)
+    if fit == nil {
+        return fmt.Sprintf("%s[%s] %s", label, bar, pct) // exports stay plain text
+    }
+    return fmt.Sprintf("%s[[%s]%s[-]] [%s]%s[-]", label, color, bar, color, pct)
+}
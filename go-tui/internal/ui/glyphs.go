@@ -0,0 +1,101 @@
+package ui
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/rivo/tview"
+)
+
+// buildKeyView is the "key" page: what the glyphs and colors on screen stand
+// for, given the options and toggles in effect. The help line covers keys;
+// this covers the visual encoding.
+func (a *App) buildKeyView() *tview.TextView {
+    a.glyphs = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+    a.glyphs.SetBorder(true).SetTitle("Key (k or Esc to close)")
+    return a.glyphs
+}
+
+func (a *App) renderKey() {
+    if name, _ := a.pages.GetFrontPage(); name != "key" {
+        return
+    }
+    a.glyphs.SetText(a.keyText())
+}
+
+// keyText lists the encodings currently drawn, panel by panel; anything
+// switched off is left out.
+func (a *App) keyText() string {
+    b := &strings.Builder{}
+    item := func(glyph, meaning string) {
+        fmt.Fprintf(b, "  %s  %s\n", glyph, meaning)
+    }
+    fmt.Fprintf(b, "Timeline (%s per bucket, newest right):\n", plotNames[a.plot])
+    switch stacked := a.stacked(); {
+    case len(stacked) > 0:
+        for _, name := range stacked {
+            item("["+seriesColors[name]+"]"+string(sparkChars)+"[-]", "stacked "+name+" series, low to high; its scale is on the right")
+        }
+    case a.cfg.ChartStyle == "health":
+        item("█", "bar height is the bucket's count against the busiest bucket shown")
+        item("[green]█[-] [yellow]█[-] [red]█[-]", fmt.Sprintf("success rate above %.0f%%, %.0f-%.0f%%, below %.0f%%", 100*healthGreen, 100*healthYellow, 100*healthGreen, 100*healthYellow))
+    default:
+        item(tview.Escape(string(densityChars[1:])), "count, empty to the busiest bucket shown (l shows the counts)")
+        if a.plot == plotTotal {
+            item(tview.Escape(string(a.cfg.SuccessMark)), "bucket with successes only")
+        }
+        item(tview.Escape(string(a.cfg.FailMark)), "bucket with failures only (row under the bars)")
+        if a.cfg.RateColors {
+            item("["+rateColor(1)+"]■[-] ["+rateColor(0.5)+"]■[-] ["+rateColor(0)+"]■[-]", "bucket success rate, 100% to 0% (--rate-colors)")
+        }
+    }
+    if a.overlay != overlayNone {
+        item("[aqua]"+string(sparkChars)+"[-]", overlayNames[a.overlay]+" overlay line, low to high (o)")
+    }
+    item("[yellow]^[-]", "annotation: alert, restart or PIA IP change (l lists the latest)")
+    if a.cfg.AnomalyZ > 0 {
+        item("[yellow]![-]", fmt.Sprintf("anomalous bucket, z-score %.1f or more", a.cfg.AnomalyZ))
+    }
+    if a.axis {
+        item("[gray]┊[-]", "axis tick; times are on the row below (g)")
+    }
+
+    b.WriteString("\nStats:\n")
+    item("[green]█[-][yellow]█[-][red]█[-]░", fmt.Sprintf("success-rate gauge over %s: %.0f%% and up, %.0f%% and up, below", a.cfg.AlertWindow, gaugeGreen, gaugeYellow))
+    if pinRegions, pinInstances := a.pins(); len(pinRegions)+len(pinInstances) > 0 {
+        item(string(pinMark), "pinned row, kept whatever its volume (P in the i view)")
+    }
+    item("…", "name clipped to fit the panel")
+    if a.deltas {
+        item("+N", "count in the last refresh interval, not the total (d)")
+    }
+    if a.showPct {
+        item("N%", "share of all requests (%)")
+    }
+
+    b.WriteString("\nHeader:\n")
+    item("[green]live[-] [red]STALE[-]", "whether metrics are still arriving")
+    item("[red]alert[-]", "an alert is firing")
+    if len(a.cfg.PIAColors) > 0 {
+        item("[green]■[-] [yellow]■[-] [red]■[-]", "PIA state as colored by --pia-colors (default: connected, changing, down)")
+    }
+    if a.progress != nil {
+        b.WriteString("\nProgress:\n")
+        item("[green]█[-]░", "targets done against the expected total")
+        item("[yellow]█[-]", "more done than expected")
+    }
+
+    if a.cfg.PrefixColors || a.cfg.ANSILogs || a.lineNumbers {
+        b.WriteString("\nLogs:\n")
+        if a.cfg.PrefixColors {
+            item(tview.Escape("[instance]"), "line prefix, one stable color per instance")
+        }
+        if a.cfg.ANSILogs {
+            item("[red]t[yellow]e[aqua]xt[-]", "colors the producer wrote into the line (--ansi-logs)")
+        }
+        if a.lineNumbers {
+            item(fmt.Sprintf("[gray]%0*d[-]", lineNoWidth, 1), "line number (n)")
+        }
+    }
+    return b.String()
+}
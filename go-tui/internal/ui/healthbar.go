@@ -0,0 +1,85 @@
+package ui
+
+import (
+    "fmt"
+    "strings"
+
+    "secmon/internal/metrics"
+)
+
+// ChartStyles are the accepted --timeline-style values: "density" is the
+// character ramp plus failure row, "health" one bar per bucket whose height
+// is volume and whose color alone is the success rate.
+var ChartStyles = []string{"density", "health"}
+
+// ValidChartStyle reports whether name is one of ChartStyles.
+func ValidChartStyle(name string) bool {
+    for _, c := range ChartStyles {
+        if c == name {
+            return true
+        }
+    }
+    return false
+}
+
+// Success rate thresholds for the health bar colors.
+const (
+    healthGreen  = 0.95
+    healthYellow = 0.80
+)
+
+// maxBarRows caps the health bars' height; taller adds little resolution.
+const maxBarRows = 8
+
+// healthColor is green above healthGreen, yellow down to healthYellow, red
+// below.
+func healthColor(rate float64) string {
+    switch {
+    case rate > healthGreen:
+        return "green"
+    case rate >= healthYellow:
+        return "yellow"
+    }
+    return "red"
+}
+
+// healthBars renders data as vertical bars rows high, value(bucket) in
+// eighth-block steps of maxv, colored by each bucket's success rate.
+func healthBars(data []metrics.Bucket, value func(metrics.Bucket) int, maxv, rows int) string {
+    if rows < 1 { rows = 1 }
+    if rows > maxBarRows { rows = maxBarRows }
+    lines := make([]strings.Builder, rows)
+    for _, p := range data {
+        v := value(p)
+        eighths := 0
+        if v > 0 {
+            eighths = (v*rows*8 + maxv - 1) / maxv // a non-empty bucket shows at least a sliver
+        }
+        color := ""
+        if v > 0 {
+            color = healthColor(float64(p.Success) / float64(p.Total()))
+        }
+        for r := 0; r < rows; r++ {
+            // r counts from the top; fill is what this row holds, 0..8
+            fill := eighths - (rows-1-r)*8
+            if fill < 0 { fill = 0 }
+            if fill > 8 { fill = 8 }
+            if fill == 0 {
+                lines[r].WriteByte(' ')
+                continue
+            }
+            fmt.Fprintf(&lines[r], "[%s]%c[-]", color, sparkChars[fill-1])
+        }
+    }
+    out := make([]string, rows)
+    for i := range lines {
+        out[i] = lines[i].String()
+    }
+    return strings.Join(out, "\n")
+}
+
+// healthLegend explains the health bars at the current scale.
+func healthLegend(maxv int) string {
+    return fmt.Sprintf("[green]█[-] >%.0f%%  [yellow]█[-] %.0f-%.0f%%  [red]█[-] <%.0f%% success  height: 0-%d per bucket",
+        100*healthGreen, 100*healthYellow, 100*healthGreen, 100*healthYellow, maxv)
+}
@@ -0,0 +1,146 @@
+package ui
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+
+    "secmon/internal/metrics"
+)
+
+// heatCellWidth is one heatmap cell: two block characters and a gap.
+const heatCellWidth = 3
+
+// heatmapView is the "heatmap" page's layout at its last render, for mapping
+// clicks back to instances; UI goroutine only.
+type heatmapView struct {
+    tv    *tview.TextView
+    cells []string // instance per cell, row by row
+    cols  int
+    top   int // rows of text above the grid
+}
+
+// buildHeatmapView is the "heatmap" page: one cell per instance, colored by
+// its success rate over --alert-window, so the few unhealthy instances of a
+// large fleet stand out. Clicking a cell opens that instance's i view.
+func (a *App) buildHeatmapView() *tview.TextView {
+    a.heat.tv = tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+    a.heat.tv.SetBorder(true).SetTitle("Instances (h or Esc to close, click a cell for its i view)")
+    a.heat.tv.SetMouseCapture(a.heatmapClick)
+    return a.heat.tv
+}
+
+func (a *App) renderHeatmap() {
+    if a.agg == nil {
+        return
+    }
+    if name, _ := a.pages.GetFrontPage(); name != "heatmap" {
+        return
+    }
+    n := int((a.cfg.AlertWindow + time.Duration(a.cfg.Bucket)*time.Second - 1) / (time.Duration(a.cfg.Bucket) * time.Second))
+    text := a.heatmapText(a.agg.Snapshot().PerInstance, a.agg.RecentInstances(max(n, 1)), getWidth(a.heat.tv))
+    a.heat.tv.SetText(text)
+}
+
+// heatColor is a cell's color: the gauge's bands by success rate, gray for
+// an instance with no entries in the window.
+func heatColor(c [2]int) string {
+    total := c[0] + c[1]
+    if total == 0 {
+        return "gray"
+    }
+    switch rate := 100 * float64(c[0]) / float64(total); {
+    case rate >= gaugeGreen:
+        return "green"
+    case rate >= gaugeYellow:
+        return "yellow"
+    }
+    return "red"
+}
+
+// heatmapText lays out every instance seen (by name, so cells keep their
+// place) across width, and lists the red and yellow ones below the grid.
+func (a *App) heatmapText(all, recent map[string][2]int, width int) string {
+    ids := make([]string, 0, len(all))
+    for id := range all {
+        if id != metrics.OtherKey {
+            ids = append(ids, id)
+        }
+    }
+    sort.Strings(ids)
+    a.heat.cells = ids
+    a.heat.cols = max(width/heatCellWidth, 1)
+    if len(ids) == 0 {
+        return "(no instances yet)"
+    }
+    b := &strings.Builder{}
+    counts := make(map[string]int)
+    colors := make([]string, len(ids))
+    for i, id := range ids {
+        colors[i] = heatColor(recent[id])
+        counts[colors[i]]++
+    }
+    fmt.Fprintf(b, "%s instances over %s:  [green]██[-] %s  [yellow]██[-] %s  [red]██[-] %s  [gray]░░[-] %s idle\n",
+        a.num(len(ids)), a.cfg.AlertWindow, a.num(counts["green"]), a.num(counts["yellow"]), a.num(counts["red"]), a.num(counts["gray"]))
+    a.heat.top = 1
+    for i, c := range colors {
+        cell := "██"
+        if c == "gray" {
+            cell = "░░"
+        }
+        fmt.Fprintf(b, "[%s]%s[-]", c, cell)
+        if (i+1)%a.heat.cols == 0 || i == len(ids)-1 {
+            b.WriteByte('\n')
+        } else {
+            b.WriteByte(' ')
+        }
+    }
+    // the red and yellow instances by name, worst first, wrapped to width
+    type badCell struct {
+        id   string
+        rate float64
+    }
+    var bad []badCell
+    for i, id := range ids {
+        if c := recent[id]; colors[i] == "red" || colors[i] == "yellow" {
+            bad = append(bad, badCell{id, 100 * float64(c[0]) / float64(c[0]+c[1])})
+        }
+    }
+    sort.SliceStable(bad, func(i, j int) bool { return bad[i].rate < bad[j].rate })
+    line := 0
+    for i, c := range bad {
+        item := fmt.Sprintf("%s %.0f%%", c.id, c.rate)
+        if i == 0 || line+2+len(item) > width {
+            b.WriteByte('\n')
+            line = 0
+        } else {
+            b.WriteString("  ")
+            line += 2
+        }
+        fmt.Fprintf(b, "[%s]%s[-] %.0f%%", heatColor(recent[c.id]), tview.Escape(c.id), c.rate)
+        line += len(item)
+    }
+    return b.String()
+}
+
+// heatmapClick opens the i view on the instance under a clicked cell.
+func (a *App) heatmapClick(action tview.MouseAction, ev *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+    if action != tview.MouseLeftClick || a.agg == nil {
+        return action, ev
+    }
+    x, y := ev.Position()
+    ix, iy, _, _ := a.heat.tv.GetInnerRect()
+    col, row := (x-ix)/heatCellWidth, y-iy-a.heat.top
+    if x < ix || row < 0 || col >= a.heat.cols || (x-ix)%heatCellWidth == heatCellWidth-1 {
+        return action, ev
+    }
+    if i := row*a.heat.cols + col; i < len(a.heat.cells) {
+        a.focusOn(a.heat.cells[i])
+        return action, nil
+    }
+    return action, ev
+}
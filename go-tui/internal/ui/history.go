@@ -0,0 +1,59 @@
+package ui
+
+import (
+    "fmt"
+
+    "secmon/internal/history"
+    "secmon/internal/metrics"
+)
+
+// startHistory opens --sqlite, if set; the returned func closes it.
+func (a *App) startHistory() (func(), error) {
+    if a.cfg.SQLite == "" || a.agg == nil {
+        return func() {}, nil
+    }
+    h, err := history.Open(a.cfg.SQLite)
+    if err != nil {
+        return nil, fmt.Errorf("sqlite: %w", err)
+    }
+    a.history = h
+    return func() { h.Close() }, nil
+}
+
+// flushHistory writes the completed buckets (all but the newest) not yet in
+// --sqlite; called per tick on the update goroutine.
+func (a *App) flushHistory(st metrics.Snapshot) {
+    if a.history == nil {
+        return
+    }
+    bs := st.Buckets()
+    if len(bs) < 2 {
+        return
+    }
+    newest := bs[0].Start
+    for _, b := range bs {
+        if b.Start.After(newest) { newest = b.Start }
+    }
+    done := a.history.Flushed(st.BucketSecs)
+    var rows []history.Row
+    for _, b := range bs {
+        if !b.Start.After(done) || !b.Start.Before(newest) {
+            continue
+        }
+        rows = append(rows, history.Row{Start: b.Start, Secs: st.BucketSecs, Success: b.Success, Fail: b.Fail, Regions: a.agg.BucketRegions(b.Start)})
+    }
+    n, err := a.history.Flush(rows)
+    if err != nil {
+        a.noteWriteFailure()
+        err = fmt.Errorf("sqlite: %w", err)
+        if a.cfg.Headless {
+            a.warnf("%v", err)
+        } else {
+            a.setWarning(err.Error())
+        }
+        return
+    }
+    if n > 0 {
+        a.debugf("sqlite: flushed %d buckets", n)
+    }
+}
@@ -0,0 +1,38 @@
+package ui
+
+import (
+    "fmt"
+    "strconv"
+)
+
+// humanizeInt abbreviates n for --compact-numbers: exact below 1000, then
+// K/M/G/T with one decimal under 10 (1.3M) and none above (284K).
+func humanizeInt(n int) string {
+    if n < 0 {
+        return "-" + humanizeInt(-n)
+    }
+    if n < 1000 {
+        return strconv.Itoa(n)
+    }
+    const units = "KMGT"
+    v := float64(n)
+    for i := 0; ; i++ {
+        v /= 1000
+        // 999.5K would print as 1000K; carry it to 1.0M.
+        if v < 999.5 || i == len(units)-1 {
+            if v < 9.95 {
+                return fmt.Sprintf("%.1f%c", v, units[i])
+            }
+            return fmt.Sprintf("%.0f%c", v, units[i])
+        }
+    }
+}
+
+// num formats a count for the screen: humanizeInt under --compact-numbers.
+// Snapshots and other exports call strconv.Itoa directly to keep every digit.
+func (a *App) num(n int) string {
+    if a.cfg.CompactNums {
+        return humanizeInt(n)
+    }
+    return strconv.Itoa(n)
+}
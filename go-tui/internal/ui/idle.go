@@ -0,0 +1,51 @@
+package ui
+
+import "time"
+
+// idleRedraw is how often the UI redraws while idle anyway, so ages in the
+// footer keep moving, notices expire and a resized window is re-fitted.
+const idleRedraw = 5 * time.Second
+
+// idleBackoff stretches the poll interval while ticks find nothing new:
+// after IdleAfter quiet ticks it doubles per tick from the refresh up to
+// IdleMax, and snaps back to the refresh on the first tick with data. An
+// IdleMax no longer than the refresh disables it.
+type idleBackoff struct {
+    base, max time.Duration
+    after     int
+    quiet     int
+    cur       time.Duration
+}
+
+func newIdleBackoff(cfg AppConfig) *idleBackoff {
+    return &idleBackoff{base: cfg.Refresh, max: cfg.IdleMax, after: cfg.IdleAfter, cur: cfg.Refresh}
+}
+
+// next returns the wait before the next tick, given whether this one found
+// anything.
+func (b *idleBackoff) next(active bool) time.Duration {
+    if active || b.max <= b.base {
+        b.quiet, b.cur = 0, b.base
+        return b.cur
+    }
+    b.quiet++
+    if b.quiet > b.after {
+        b.cur = min(b.cur*2, b.max)
+    }
+    return b.cur
+}
+
+// wakeUp resets to the refresh, e.g. after a key press.
+func (b *idleBackoff) wakeUp() {
+    b.quiet, b.cur = 0, b.base
+}
+
+// touch marks the screen out of date and wakes the UI loop for an immediate
+// redraw; safe from any goroutine.
+func (a *App) touch() {
+    a.dirty.Store(true)
+    select {
+    case a.wake <- struct{}{}:
+    default:
+    }
+}
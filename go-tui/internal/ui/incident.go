@@ -0,0 +1,73 @@
+package ui
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+
+    "secmon/internal/alert"
+    "secmon/internal/metrics"
+)
+
+// captureIncident writes the state at an alert transition into its own
+// subdirectory of --snapshot-on-alert: alert.txt, the snapshot set and, with
+// --incident-events, the retained entries from that window before it as
+// events.jsonl (replayable with --replay). Called on the update goroutine.
+func (a *App) captureIncident(t alert.Transition, at time.Time) {
+    if a.cfg.IncidentDir == "" || a.agg == nil {
+        return
+    }
+    state := "cleared"
+    if t.Fired { state = "fired" }
+    dir := filepath.Join(a.cfg.IncidentDir, fmt.Sprintf("incident-%s-%s-%s", at.UTC().Format("20060102T150405Z"), t.Kind, state))
+    err := os.MkdirAll(dir, 0o755)
+    if err == nil {
+        err = writeFile(dir+"/alert.txt", fmt.Sprintf("%s\nat %s\n", t, at.UTC().Format(time.RFC3339)))
+    }
+    if err == nil {
+        a.snapMu.Lock()
+        err = a.writeSnapshotSet(dir)
+        a.snapMu.Unlock()
+    }
+    if err == nil && a.cfg.IncidentWin > 0 {
+        err = writeEvents(dir+"/events.jsonl", a.agg.EventsSince(at.Add(-a.cfg.IncidentWin)))
+    }
+    if err != nil {
+        a.noteWriteFailure()
+        err = fmt.Errorf("incident snapshot: %w", err)
+        if a.cfg.Headless {
+            a.warnf("%v", err)
+        } else {
+            a.setWarning(err.Error())
+        }
+        return
+    }
+    a.debugf("incident snapshot: %s", dir)
+    if !a.cfg.Headless {
+        a.setNotice("incident snapshot: " + dir)
+    }
+}
+
+// writeEvents writes evs as metrics lines, oldest first.
+func writeEvents(path string, evs []metrics.Event) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    w := bufio.NewWriter(f)
+    enc := json.NewEncoder(w)
+    for _, ev := range evs {
+        if err := enc.Encode(ev.Entry); err != nil {
+            f.Close()
+            return err
+        }
+    }
+    if err := w.Flush(); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
@@ -0,0 +1,51 @@
+package ui
+
+import (
+    "strings"
+
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+
+    "secmon/internal/events"
+)
+
+// buildLabelInput is the L prompt for editing the run label.
+func (a *App) buildLabelInput() *tview.Flex {
+    a.labelInput = tview.NewInputField().SetLabel(" Label: ").SetFieldWidth(40).
+        SetPlaceholder("e.g. the experiment this run belongs to")
+    a.labelInput.SetDoneFunc(func(key tcell.Key) {
+        if key == tcell.KeyEnter {
+            a.setLabel(strings.TrimSpace(a.labelInput.GetText()))
+        }
+        a.closePrompt("label")
+    })
+    return promptPage(a.labelInput)
+}
+
+// openLabel shows the label prompt holding the current label; Enter sets it
+// (empty clears it), Esc leaves it unchanged.
+func (a *App) openLabel() {
+    a.openPrompt("label", a.labelInput, a.runLabel())
+}
+
+// setLabel changes the run label from the next snapshot on, noting the
+// change in the event log.
+func (a *App) setLabel(label string) {
+    a.mu.Lock()
+    prev := a.label
+    a.label = label
+    a.mu.Unlock()
+    if label == prev {
+        return
+    }
+    a.events.SetLabel(label)
+    a.events.Emit(events.LabelChanged, map[string]any{"old_label": prev})
+    a.updateHeader()
+}
+
+// runLabel returns the current run label.
+func (a *App) runLabel() string {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    return a.label
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+    "fmt"
+
+    "github.com/rivo/tview"
+)
+
+// Layouts selectable with --layout.
+var Layouts = []string{"default", "timeline-top", "logs-bottom"}
+
+// Split ratio bounds, in tenths of the main area given to the logs.
+const (
+    defaultRatio = 6 // the original 3:2 split
+    minRatio     = 2
+    maxRatio     = 8
+)
+
+// ValidLayout reports whether name is one of Layouts.
+func ValidLayout(name string) bool {
+    for _, l := range Layouts {
+        if l == name {
+            return true
+        }
+    }
+    return false
+}
+
+// layoutMain (re)fills the main page from cfg.Layout and the current ratio:
+//
+//   default       logs | stats over timeline
+//   timeline-top  timeline across the top, then logs | stats
+//   logs-bottom   stats | timeline across the top, logs below
+//
+// The ratio is always the logs' share of its split.
+func (a *App) layoutMain() {
+    m := a.mainRow
+    m.Clear()
+    if a.cfg.TailOnly {
+        m.SetDirection(tview.FlexColumn)
+        m.AddItem(a.logsBox, 0, 1, false)
+        return
+    }
+    logs, rest := a.ratio, 10-a.ratio
+    switch a.cfg.Layout {
+    case "timeline-top":
+        below := tview.NewFlex().SetDirection(tview.FlexColumn)
+        below.AddItem(a.logsBox, 0, logs, false)
+        below.AddItem(a.stats, 0, rest, false)
+        m.SetDirection(tview.FlexRow)
+        m.AddItem(a.timeline, 0, 1, false)
+        m.AddItem(below, 0, 2, false)
+    case "logs-bottom":
+        top := tview.NewFlex().SetDirection(tview.FlexColumn)
+        top.AddItem(a.stats, 0, 1, false)
+        top.AddItem(a.timeline, 0, 1, false)
+        m.SetDirection(tview.FlexRow)
+        m.AddItem(top, 0, rest, false)
+        m.AddItem(a.logsBox, 0, logs, false)
+    default:
+        right := tview.NewFlex().SetDirection(tview.FlexRow)
+        right.AddItem(a.stats, 0, 1, false)
+        right.AddItem(a.timeline, 0, 1, false)
+        m.SetDirection(tview.FlexColumn)
+        m.AddItem(a.logsBox, 0, logs, false)
+        m.AddItem(right, 0, rest, false)
+    }
+}
+
+// resize moves the split by delta tenths and rebuilds the layout.
+func (a *App) resize(delta int) {
+    r := a.ratio + delta
+    if r < minRatio || r > maxRatio {
+        return
+    }
+    a.ratio = r
+    a.layoutMain()
+    a.setNotice(fmt.Sprintf("logs %d0%%", r))
+    a.updateHeader()
+}
@@ -0,0 +1,28 @@
+package ui
+
+import "fmt"
+
+// lineNoWidth is the gutter's zero-padded width; numbers past it just grow.
+const lineNoWidth = 6
+
+// numberLog prefixes line with the next display sequence number when the
+// gutter is on. Every displayed line takes a number either way, so a number
+// means the same line in the combined and split panes and doesn't shift
+// when the gutter is toggled; lines hidden by f never get one.
+func (a *App) numberLog(line string) string {
+    a.logSeq++
+    if !a.lineNumbers {
+        return line
+    }
+    if a.logColors() {
+        return fmt.Sprintf("[gray]%0*d[-] %s", lineNoWidth, a.logSeq, line)
+    }
+    return fmt.Sprintf("%0*d %s", lineNoWidth, a.logSeq, line)
+}
+
+// toggleLineNumbers shows or hides the logs gutter (the n key). Lines
+// already in the panes keep the form they were written in.
+func (a *App) toggleLineNumbers() {
+    a.lineNumbers = !a.lineNumbers
+    a.setTitles()
+}
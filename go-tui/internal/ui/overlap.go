@@ -0,0 +1,84 @@
+package ui
+
+import (
+    "fmt"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// globOverlap returns the files both globs match, by absolute path, sorted.
+func globOverlap(a, b string) []string {
+    am, _ := filepath.Glob(a)
+    bm, _ := filepath.Glob(b)
+    seen := make(map[string]bool, len(am))
+    for _, p := range am {
+        if abs, err := filepath.Abs(p); err == nil { seen[abs] = true }
+    }
+    var out []string
+    for _, p := range bm {
+        if abs, err := filepath.Abs(p); err == nil && seen[abs] {
+            out = append(out, abs)
+            delete(seen, abs) // list each file once
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+// overlapText describes files, listing a few.
+func overlapText(files []string) string {
+    const show = 3
+    list := strings.Join(files[:min(len(files), show)], ", ")
+    if len(files) > show {
+        list += fmt.Sprintf(" and %d more", len(files)-show)
+    }
+    return fmt.Sprintf("--logs and --metrics both match %d file(s): %s", len(files), list)
+}
+
+// checkOverlap reports files matched by both --logs and --metrics at
+// startup: an error with --strict, otherwise a warning. Only the UI reads
+// both globs (headless doesn't tail logs), and not --tail-only or --replay.
+func (a *App) checkOverlap() error {
+    if a.cfg.Headless || a.cfg.TailOnly || a.replay != nil {
+        return nil
+    }
+    files := globOverlap(a.cfg.LogsGlob, a.cfg.MetricsGlob)
+    if len(files) > 0 && a.cfg.Strict {
+        return fmt.Errorf("%s (--strict)", overlapText(files))
+    }
+    if a.reportOverlap(files) {
+        a.warnf("%s", overlapText(files))
+    }
+    return nil
+}
+
+// noteOverlap re-checks the overlap when either glob's match count changes,
+// since files that appear later can overlap too; called on the update
+// goroutine.
+func (a *App) noteOverlap(logs, metrics int) {
+    if a.cfg.Headless || a.cfg.TailOnly || a.replay != nil {
+        return
+    }
+    if n := [2]int{logs, metrics}; n != a.overlapAt {
+        a.overlapAt = n
+        if files := globOverlap(a.cfg.LogsGlob, a.cfg.MetricsGlob); a.reportOverlap(files) {
+            a.debugf("%s", overlapText(files))
+        }
+    }
+}
+
+// reportOverlap puts a changed, non-empty overlapping set in the header
+// warning, reporting whether it did.
+func (a *App) reportOverlap(files []string) bool {
+    key := strings.Join(files, "\x00")
+    if key == a.overlap {
+        return false
+    }
+    a.overlap = key
+    if len(files) == 0 {
+        return false
+    }
+    a.setWarning(overlapText(files))
+    return true
+}
@@ -0,0 +1,33 @@
+package ui
+
+import "secmon/internal/metrics"
+
+// Secondary series drawn as a line over the timeline bars, cycled with o.
+const (
+    overlayNone = iota
+    overlayFailRate
+    overlayLatency
+    overlayCount
+)
+
+var overlayNames = [overlayCount]string{"", "fail rate", "latency"}
+
+// overlaySeries is the Series each overlay draws.
+var overlaySeries = [overlayCount]string{"", seriesFailRate, seriesLatency}
+
+// sparkChars draws the overlay line, low to high.
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+// overlayRow renders the selected secondary series over the displayed
+// buckets, one character per column (blank where a bucket has no value), and
+// a caption with its scale.
+func (a *App) overlayRow(data []metrics.Bucket) (string, string) {
+    if a.overlay == overlayNone {
+        return "", ""
+    }
+    p := a.project(overlaySeries[a.overlay], data)
+    if p.vals == nil {
+        return "", p.caption
+    }
+    return p.row(), p.caption
+}
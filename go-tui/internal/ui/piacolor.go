@@ -0,0 +1,61 @@
+package ui
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+)
+
+// defaultPIAColors colors the header's PIA state by piactl connectionstate:
+// green when up, yellow while changing, red when down or unreadable.
+var defaultPIAColors = map[string]string{
+    "connected":                "green",
+    "connecting":               "yellow",
+    "reconnecting":             "yellow",
+    "interrupted":              "yellow",
+    "disconnecting":            "yellow",
+    "disconnectingtoreconnect": "yellow",
+    "disconnected":             "red",
+    "na":                       "red",
+}
+
+// ParsePIAColors merges a --pia-colors spec, "State=color,..." (state names
+// are case-insensitive; colors are tview names or #rrggbb), over the
+// defaults. "none" leaves the state uncolored.
+func ParsePIAColors(spec string) (map[string]string, error) {
+    if strings.TrimSpace(spec) == "none" {
+        return map[string]string{}, nil
+    }
+    m := make(map[string]string, len(defaultPIAColors))
+    for k, v := range defaultPIAColors { m[k] = v }
+    for _, kv := range strings.Split(spec, ",") {
+        kv = strings.TrimSpace(kv)
+        if kv == "" {
+            continue
+        }
+        state, color, ok := strings.Cut(kv, "=")
+        state, color = strings.ToLower(strings.TrimSpace(state)), strings.ToLower(strings.TrimSpace(color))
+        if !ok || state == "" || color == "" {
+            return nil, fmt.Errorf("want State=color, got %q", kv)
+        }
+        if color == "none" {
+            delete(m, state)
+            continue
+        }
+        if _, named := tcell.ColorNames[color]; !named && (!strings.HasPrefix(color, "#") || tcell.GetColor(color) == tcell.ColorDefault) {
+            return nil, fmt.Errorf("unknown color %q for %s", color, state)
+        }
+        m[state] = color
+    }
+    return m, nil
+}
+
+// piaStateText colors state by cfg.PIAColors; unknown states stay plain.
+func (a *App) piaStateText(state string) string {
+    if c, ok := a.cfg.PIAColors[strings.ToLower(state)]; ok && state != "" {
+        return "[" + c + "]" + tview.Escape(state) + "[-]"
+    }
+    return state
+}
@@ -0,0 +1,85 @@
+package ui
+
+import (
+    "sort"
+    "strings"
+)
+
+// pinMark prefixes pinned rows in the stats panel tables.
+const pinMark = '*'
+
+// countRow is one row of a stats panel table.
+type countRow struct {
+    key    string
+    s, f   int
+    pinned bool
+}
+
+// parsePins splits a comma-separated --pin-region / --pin-instance value.
+func parsePins(spec string) map[string]bool {
+    pins := make(map[string]bool)
+    for _, k := range strings.Split(spec, ",") {
+        if k = strings.TrimSpace(k); k != "" {
+            pins[k] = true
+        }
+    }
+    return pins
+}
+
+// topRows returns the pinned keys (by name, with zero counts if they have no
+// data yet) followed by the n busiest of the rest.
+func topRows(m map[string][2]int, pins map[string]bool, n int) []countRow {
+    var pinned, rest []countRow
+    for k := range pins {
+        v := m[k]
+        pinned = append(pinned, countRow{k, v[0], v[1], true})
+    }
+    sort.Slice(pinned, func(i, j int) bool { return pinned[i].key < pinned[j].key })
+    for k, v := range m {
+        if !pins[k] {
+            rest = append(rest, countRow{key: k, s: v[0], f: v[1]})
+        }
+    }
+    sort.Slice(rest, func(i, j int) bool {
+        if ti, tj := rest[i].s+rest[i].f, rest[j].s+rest[j].f; ti != tj {
+            return ti > tj
+        }
+        return rest[i].key < rest[j].key
+    })
+    if len(rest) > n { rest = rest[:n] }
+    return append(pinned, rest...)
+}
+
+// togglePin pins or unpins the instance open in the i view (the P key).
+func (a *App) togglePin() {
+    id := a.focus.id
+    if id == "" || a.focus.region {
+        return
+    }
+    a.mu.Lock()
+    pinned := !a.pinInstances[id]
+    if pinned {
+        a.pinInstances[id] = true
+    } else {
+        delete(a.pinInstances, id)
+    }
+    a.mu.Unlock()
+    if pinned {
+        a.setNotice("pinned " + id + " to the stats panel")
+    } else {
+        a.setNotice("unpinned " + id)
+    }
+    a.updateHeader()
+    a.renderStats()
+}
+
+// pins returns copies of the pinned regions and instances.
+func (a *App) pins() (regions, instances map[string]bool) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    instances = make(map[string]bool, len(a.pinInstances))
+    for k := range a.pinInstances {
+        instances[k] = true
+    }
+    return a.pinRegions, instances
+}
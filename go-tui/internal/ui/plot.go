@@ -0,0 +1,32 @@
+package ui
+
+import "secmon/internal/metrics"
+
+// What the timeline bars plot and scale to, cycled with x.
+const (
+    plotTotal = iota
+    plotSuccess
+    plotFail
+    plotCount
+)
+
+var plotNames = [plotCount]string{"total", "successes only", "failures only"}
+
+// plotValue is bucket b's bar under the current plot mode.
+func (a *App) plotValue(b metrics.Bucket) int {
+    switch a.plot {
+    case plotSuccess:
+        return b.Success
+    case plotFail:
+        return b.Fail
+    }
+    return b.Total()
+}
+
+// cyclePlot switches the timeline between total, success and failure
+// volume (the x key).
+func (a *App) cyclePlot() {
+    a.plot = (a.plot + 1) % plotCount
+    a.setTitles()
+    a.renderTimeline()
+}
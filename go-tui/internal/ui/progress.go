@@ -0,0 +1,97 @@
+package ui
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "secmon/internal/metrics"
+)
+
+// progressMinElapsed is how long completions must be watched before an ETA
+// is guessed from their rate.
+const progressMinElapsed = 5 * time.Second
+
+// progressRate remembers where the completion count was when the panel first
+// saw it moving, so the ETA reflects this run's rate rather than however
+// many targets an earlier run had already read back from the files.
+type progressRate struct {
+    since time.Time
+    base  int
+}
+
+// expectedTotal is the batch size progress is measured against: --expected-total
+// if given, else the latest total_targets a producer announced (0: unknown).
+func (a *App) expectedTotal(t metrics.TargetCounts) int {
+    if a.cfg.ExpectedTot > 0 {
+        return a.cfg.ExpectedTot
+    }
+    return t.Expected
+}
+
+// eta estimates the time left to finish total targets from done so far, or
+// false while there's too little to go on.
+func (p *progressRate) eta(done, total int, now time.Time) (time.Duration, bool) {
+    if p.since.IsZero() || done < p.base {
+        // first sighting, or the counts were cleared
+        p.since, p.base = now, done
+        return 0, false
+    }
+    elapsed := now.Sub(p.since)
+    n := done - p.base
+    if n <= 0 || elapsed < progressMinElapsed || done >= total {
+        return 0, false
+    }
+    left := time.Duration(float64(elapsed) * float64(total-done) / float64(n))
+    return left.Round(time.Second), true
+}
+
+// renderProgress draws the batch progress bar: distinct targets with an
+// outcome (retries collapsed, as in --targets) against the expected total.
+func (a *App) renderProgress() {
+    if a.progress == nil || a.agg == nil {
+        return
+    }
+    t := a.agg.Snapshot().Targets
+    a.progress.SetText(a.progressText(t.Total, a.expectedTotal(t), getWidth(a.progress), time.Now()))
+}
+
+func (a *App) progressText(done, total, width int, now time.Time) string {
+    if total <= 0 {
+        return fmt.Sprintf(" %s targets done, total unknown (no total_targets in metrics; set --expected-total)", a.num(done))
+    }
+    pct := 100 * float64(done) / float64(total)
+    var note string
+    color := "green"
+    switch {
+    case done > total:
+        color = "yellow"
+        note = fmt.Sprintf("%s over the expected total", a.num(done-total))
+    case done == total:
+        note = "complete"
+    default:
+        if left, ok := a.progRate.eta(done, total, now); ok {
+            note = fmt.Sprintf("ETA %s (%s)", left, now.Add(left).Format("15:04:05"))
+        } else {
+            note = "ETA --"
+        }
+    }
+    label := fmt.Sprintf(" %5.1f%%  %s/%s  ", pct, a.num(done), a.num(total))
+    bar := width - 2 - len(label) - len(note)
+    if bar > 60 { bar = 60 }
+    b := &strings.Builder{}
+    if bar >= 10 {
+        fill := bar
+        if done < total {
+            fill = int(float64(bar) * float64(done) / float64(total))
+        }
+        fmt.Fprintf(b, " [%s]%s[-]%s", color, strings.Repeat("█", fill), strings.Repeat("░", bar-fill))
+    }
+    b.WriteString(label)
+    if done > total {
+        fmt.Fprintf(b, "[%s]%s[-]", color, note)
+    } else {
+        b.WriteString(note)
+    }
+    return b.String()
+}
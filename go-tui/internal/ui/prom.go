@@ -0,0 +1,43 @@
+package ui
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+
+    "secmon/internal/prom"
+    "secmon/internal/snapshot"
+)
+
+// startProm serves /metrics and /state on --prom-addr, if set; the returned
+// func stops it.
+func (a *App) startProm() (func(), error) {
+    if a.cfg.PromAddr == "" {
+        return func() {}, nil
+    }
+    if a.agg == nil {
+        return nil, fmt.Errorf("--prom-addr needs metrics, not --tail-only")
+    }
+    ln, err := net.Listen("tcp", a.cfg.PromAddr)
+    if err != nil {
+        return nil, fmt.Errorf("prom addr: %w", err)
+    }
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", prom.Handler(a.agg))
+    mux.HandleFunc("/state", a.serveState)
+    srv := &http.Server{Handler: mux}
+    go srv.Serve(ln)
+    return func() { srv.Close() }, nil
+}
+
+// serveState serves the current state as the snapshot.json document (health
+// score included), for tools that would rather poll than read files.
+func (a *App) serveState(w http.ResponseWriter, r *http.Request) {
+    b, err := snapshot.Marshal(a.buildSnapshot(a.agg.Snapshot()))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(b)
+}
@@ -0,0 +1,31 @@
+package ui
+
+import "github.com/rivo/tview"
+
+// Prompts (Ctrl-F filter, L label, b bucket) are one-line inputs shown as
+// pages over the bottom of whatever is on screen, so changes they make are
+// visible while typing.
+
+// promptPage wraps input for pages.AddPage.
+func promptPage(input *tview.InputField) *tview.Flex {
+    return tview.NewFlex().SetDirection(tview.FlexRow).
+        AddItem(nil, 0, 1, false).
+        AddItem(input, 1, 0, true)
+}
+
+// openPrompt shows the named prompt page holding text and focuses it.
+func (a *App) openPrompt(page string, input *tview.InputField, text string) {
+    a.promptFocus = a.app.GetFocus()
+    input.SetText(text)
+    a.pages.ShowPage(page)
+    a.app.SetFocus(input)
+}
+
+// closePrompt hides the named prompt page and restores the focus.
+func (a *App) closePrompt(page string) {
+    a.pages.HidePage(page)
+    if a.promptFocus != nil {
+        a.app.SetFocus(a.promptFocus)
+    }
+    a.promptFocus = nil
+}
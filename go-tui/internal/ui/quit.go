@@ -0,0 +1,53 @@
+package ui
+
+import (
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+)
+
+// buildQuitModal is the --confirm-quit prompt, shown over the current page.
+func (a *App) buildQuitModal() *tview.Modal {
+    m := tview.NewModal().SetText("Quit? (y/n)").AddButtons([]string{"Quit", "Cancel"})
+    m.SetDoneFunc(func(i int, _ string) { a.answerQuit(i == 0) })
+    return m
+}
+
+// requestQuit handles q: exit, or with --confirm-quit ask first. Q and Ctrl-C
+// always exit at once.
+func (a *App) requestQuit() {
+    if !a.cfg.ConfirmQuit {
+        a.app.Stop()
+        return
+    }
+    a.quitPending = true
+    a.quitFocus = a.app.GetFocus()
+    a.pages.ShowPage("quit")
+    a.app.SetFocus(a.quitModal)
+}
+
+func (a *App) answerQuit(yes bool) {
+    a.quitPending = false
+    if yes {
+        a.app.Stop()
+        return
+    }
+    a.pages.HidePage("quit")
+    if a.quitFocus != nil {
+        a.app.SetFocus(a.quitFocus)
+    }
+    a.quitFocus = nil
+}
+
+// quitKey handles keys while the prompt is up: y/n/Esc answer it, Q still
+// exits, anything else goes to the modal's buttons.
+func (a *App) quitKey(ev *tcell.EventKey) *tcell.EventKey {
+    switch {
+    case ev.Rune() == 'y' || ev.Rune() == 'Y' || ev.Rune() == 'Q':
+        a.answerQuit(true)
+    case ev.Rune() == 'n' || ev.Rune() == 'N' || ev.Key() == tcell.KeyEscape:
+        a.answerQuit(false)
+    default:
+        return ev
+    }
+    return nil
+}
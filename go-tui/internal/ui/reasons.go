@@ -0,0 +1,24 @@
+package ui
+
+// toggleSuccessReasons shows or hides the success-reason table in the stats
+// panel (the r key); it needs --success-reasons, since nothing is tallied
+// without it.
+func (a *App) toggleSuccessReasons() {
+    if !a.cfg.OKReasons {
+        a.setNotice("success reasons need --success-reasons")
+        a.updateHeader()
+        return
+    }
+    a.reasons = !a.reasons
+    a.renderStats()
+}
+
+// successReasonCounts puts PerSuccessReason in the shape the stats tables
+// take, counts in the success column.
+func successReasonCounts(m map[string]int) map[string][2]int {
+    out := make(map[string][2]int, len(m))
+    for k, n := range m {
+        out[k] = [2]int{n, 0}
+    }
+    return out
+}
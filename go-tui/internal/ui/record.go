@@ -0,0 +1,30 @@
+package ui
+
+import (
+    "fmt"
+
+    "secmon/internal/record"
+)
+
+// startRecord opens --record, if set, and has the aggregator copy every
+// entry it reads into it; the returned func flushes and closes it. A replay
+// isn't recorded again.
+func (a *App) startRecord() (func(), error) {
+    if a.cfg.Record == "" || a.agg == nil || a.replay != nil {
+        return func() {}, nil
+    }
+    r, err := record.Create(a.cfg.Record)
+    if err != nil {
+        return nil, fmt.Errorf("record: %w", err)
+    }
+    a.agg.Record = r.Record
+    return func() {
+        if err := r.Close(); err != nil {
+            a.noteWriteFailure()
+            a.warnf("record: %v", err)
+        }
+        if n := r.Dropped(); n > 0 {
+            a.warnf("record: %d entries dropped (writer fell behind)", n)
+        }
+    }, nil
+}
@@ -0,0 +1,43 @@
+package ui
+
+import (
+    "fmt"
+    "time"
+)
+
+// Refresh bounds and step for + and - when AppConfig leaves them unset.
+const (
+    defaultRefreshMin  = 200 * time.Millisecond
+    defaultRefreshStep = 100 * time.Millisecond
+)
+
+// stepRefresh moves the refresh interval by one --refresh-step, shorter
+// (faster) for dir < 0, within --refresh-min and --refresh-max (the + and -
+// keys), and hands it to the update loop so the next tick uses it.
+func (a *App) stepRefresh(dir int) {
+    r := a.cfg.Refresh + time.Duration(dir)*a.cfg.RefreshStep
+    r = max(r, a.cfg.RefreshMin)
+    if a.cfg.RefreshMax > 0 {
+        r = min(r, a.cfg.RefreshMax)
+    }
+    if r == a.cfg.Refresh {
+        a.setNotice(fmt.Sprintf("refresh %s is at its limit (%s)", r, refreshBounds(a.cfg)))
+        a.updateHeader()
+        return
+    }
+    a.cfg.Refresh = r
+    select {
+    case <-a.refreshC: // superseded before the loop took it
+    default:
+    }
+    a.refreshC <- r
+    a.updateHeader()
+}
+
+// refreshBounds describes the + / - range, e.g. "200ms-5s".
+func refreshBounds(cfg AppConfig) string {
+    if cfg.RefreshMax <= 0 {
+        return fmt.Sprintf("min %s", cfg.RefreshMin)
+    }
+    return fmt.Sprintf("%s-%s", cfg.RefreshMin, cfg.RefreshMax)
+}
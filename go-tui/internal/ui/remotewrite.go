@@ -0,0 +1,57 @@
+package ui
+
+import (
+    "fmt"
+
+    "secmon/internal/metrics"
+    "secmon/internal/remotewrite"
+)
+
+// startRemoteWrite sets up --remote-write, if set; the returned func waits
+// for a push still in flight.
+func (a *App) startRemoteWrite() (func(), error) {
+    if a.cfg.RemoteWrite == "" || a.agg == nil {
+        return func() {}, nil
+    }
+    a.remote = remotewrite.New(a.cfg.RemoteWrite, a.remoteWriteError)
+    return func() { a.remote.Close() }, nil
+}
+
+// flushRemoteWrite pushes the completed buckets (all but the newest) not yet
+// delivered to --remote-write, or changed by late entries since; called per
+// tick on the update goroutine. A failed push is offered again on the next
+// tick.
+func (a *App) flushRemoteWrite(st metrics.Snapshot) {
+    if a.remote == nil {
+        return
+    }
+    bs := st.Buckets()
+    if len(bs) < 2 {
+        return
+    }
+    newest := bs[0].Start
+    for _, b := range bs {
+        if b.Start.After(newest) { newest = b.Start }
+    }
+    var done []remotewrite.Bucket
+    for _, b := range bs {
+        if !b.Start.Before(newest) {
+            continue
+        }
+        done = append(done, remotewrite.Bucket{Start: b.Start, Secs: st.BucketSecs, Success: b.Success, Fail: b.Fail, Regions: a.agg.BucketRegions(b.Start)})
+    }
+    push := a.remote.Pending(done)
+    if len(push) > 0 && a.remote.Push(push) {
+        a.debugf("remote write: pushing %d buckets", len(push))
+    }
+}
+
+// remoteWriteError reports a failed push; called from the sender goroutine.
+func (a *App) remoteWriteError(err error) {
+    err = fmt.Errorf("remote write: %w", err)
+    if a.cfg.Headless {
+        a.warnf("%v", err)
+        return
+    }
+    a.setWarning(err.Error())
+}
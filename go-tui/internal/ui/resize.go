@@ -0,0 +1,73 @@
+package ui
+
+import (
+    "github.com/gdamore/tcell/v2"
+    "github.com/rivo/tview"
+)
+
+// Smallest panel size the renderers lay out for. A terminal mid-resize, or
+// a multiplexer reattaching, can briefly report a tiny one.
+const (
+    minPanelWidth  = 20
+    minPanelHeight = 4
+)
+
+// screenState is what afterDraw remembers between frames; UI goroutine only.
+type screenState struct {
+    w, h   int
+    follow map[*tview.TextView]bool // log panes showing their last line at the last draw
+    scr    tcell.Screen             // what tview draws to, for OSC 52 between frames
+}
+
+// afterDraw runs after every frame. When the terminal size has changed
+// (a window resize, or reattaching to tmux or screen) the panels were just
+// laid out at the new size but still hold text rendered for the old one:
+// re-render them now rather than leaving a garbled frame until the next
+// tick, and keep log panes that were following their last line at the
+// bottom.
+func (a *App) afterDraw(screen tcell.Screen) {
+    a.screen.scr = screen
+    w, h := screen.Size()
+    panes := a.logPanes()
+    if a.screen.follow == nil {
+        a.screen.follow = make(map[*tview.TextView]bool)
+    }
+    if w == a.screen.w && h == a.screen.h || a.screen.w == 0 {
+        a.screen.w, a.screen.h = w, h
+        clear(a.screen.follow)
+        for _, tv := range panes {
+            a.screen.follow[tv] = atBottom(tv)
+        }
+        return
+    }
+    a.screen.w, a.screen.h = w, h
+    for _, tv := range panes {
+        if a.screen.follow[tv] {
+            tv.ScrollToEnd()
+        }
+    }
+    a.redraw()
+}
+
+// logPanes lists the text views showing log lines.
+func (a *App) logPanes() []*tview.TextView {
+    panes := []*tview.TextView{a.logs}
+    for _, tv := range a.splitPanes {
+        panes = append(panes, tv)
+    }
+    if a.splitOthers != nil {
+        panes = append(panes, a.splitOthers)
+    }
+    if a.focus.logs != nil {
+        panes = append(panes, a.focus.logs)
+    }
+    return panes
+}
+
+// atBottom reports whether tv shows its last line (or has nothing to
+// scroll).
+func atBottom(tv *tview.TextView) bool {
+    row, _ := tv.GetScrollOffset()
+    _, _, _, h := tv.GetInnerRect()
+    return row+h >= tv.GetWrappedLineCount()
+}
@@ -0,0 +1,27 @@
+package ui
+
+import (
+    "math/rand"
+    "strconv"
+)
+
+// Under --sample-rate the logs panes show a random fraction of the lines,
+// so a producer writing tens of thousands a second doesn't swamp the UI.
+// Only display is sampled: every line is still read, checked against
+// --restart-marker and checkpointed, and the metrics are counted in full.
+
+// sampleLog reports whether the next log line is shown.
+func (a *App) sampleLog() bool {
+    r := a.cfg.SampleRate
+    return r <= 0 || r >= 1 || rand.Float64() < r
+}
+
+// sampleTitle is the logs title's note of the rate, "" when every line
+// is shown.
+func (a *App) sampleTitle() string {
+    r := a.cfg.SampleRate
+    if r <= 0 || r >= 1 {
+        return ""
+    }
+    return " (sampled " + strconv.FormatFloat(100*r, 'g', 3, 64) + "%)"
+}
@@ -0,0 +1,164 @@
+package ui
+
+import (
+    "fmt"
+    "strings"
+
+    "secmon/internal/metrics"
+)
+
+// Timeline series: projections of the buckets that can be stacked as
+// labelled mini-rows (keys 1-4, --series) or drawn as the o overlay.
+const (
+    seriesVolume   = "volume"
+    seriesFails    = "fails"
+    seriesFailRate = "failrate"
+    seriesLatency  = "latency"
+)
+
+// Series lists the stackable series in key order (1 is volume, ...).
+var Series = [...]string{seriesVolume, seriesFails, seriesFailRate, seriesLatency}
+
+var seriesColors = map[string]string{
+    seriesVolume:   "white",
+    seriesFails:    "red",
+    seriesFailRate: "fuchsia",
+    seriesLatency:  "aqua",
+}
+
+// seriesLabelWidth is the room kept right of the stacked rows for captions.
+const seriesLabelWidth = 22
+
+// ParseSeries validates a comma-separated --series list.
+func ParseSeries(spec string) ([]string, error) {
+    var out []string
+    for _, s := range strings.Split(spec, ",") {
+        s = strings.TrimSpace(s)
+        if s == "" {
+            continue
+        }
+        if seriesIndex(s) < 0 {
+            return nil, fmt.Errorf("unknown series %q (want %s)", s, strings.Join(Series[:], ", "))
+        }
+        out = append(out, s)
+    }
+    return out, nil
+}
+
+func seriesIndex(name string) int {
+    for i, s := range Series {
+        if s == name {
+            return i
+        }
+    }
+    return -1
+}
+
+// projection is one series over the displayed buckets: a value per column
+// where have is set, drawn against scale.
+type projection struct {
+    vals    []float64
+    have    []bool
+    scale   float64
+    caption string
+}
+
+// project computes the named series over data. Latency isn't in the buckets:
+// it's averaged from retained events, so without --retain-events vals is nil
+// and the caption says so.
+func (a *App) project(name string, data []metrics.Bucket) projection {
+    p := projection{vals: make([]float64, len(data)), have: make([]bool, len(data))}
+    switch name {
+    case seriesVolume, seriesFails:
+        for i, b := range data {
+            v := b.Total()
+            if name == seriesFails { v = b.Fail }
+            p.vals[i], p.have[i] = float64(v), v > 0
+            if p.vals[i] > p.scale { p.scale = p.vals[i] }
+        }
+        p.caption = fmt.Sprintf("%s 0-%.0f", name, p.scale)
+    case seriesFailRate:
+        for i, b := range data {
+            if t := b.Total(); t > 0 {
+                p.vals[i], p.have[i] = float64(b.Fail)/float64(t), true
+            }
+        }
+        p.scale, p.caption = 1, "fail rate 0-100%"
+    case seriesLatency:
+        if len(data) == 0 {
+            return projection{}
+        }
+        evs := a.agg.EventsSince(data[0].Start)
+        if evs == nil {
+            return projection{caption: "latency needs --retain-events"}
+        }
+        col := columnOf(data, a.cfg.Bucket)
+        sums := make([]int, len(data))
+        ns := make([]int, len(data))
+        for _, ev := range evs {
+            if ev.ElapsedMS <= 0 { continue }
+            i, ok := col(ev.Time)
+            if !ok { continue }
+            sums[i] += ev.ElapsedMS
+            ns[i]++
+        }
+        for i := range data {
+            if ns[i] > 0 {
+                p.vals[i], p.have[i] = float64(sums[i])/float64(ns[i]), true
+                if p.vals[i] > p.scale { p.scale = p.vals[i] }
+            }
+        }
+        p.caption = fmt.Sprintf("mean latency 0-%.0fms", p.scale)
+    }
+    if p.scale <= 0 {
+        p.scale = 1
+    }
+    return p
+}
+
+// row draws the projection as sparkChars, one per column.
+func (p projection) row() string {
+    row := make([]rune, len(p.vals))
+    for i := range p.vals {
+        row[i] = ' '
+        if p.have[i] {
+            row[i] = sparkChars[int(float64(len(sparkChars)-1)*p.vals[i]/p.scale)]
+        }
+    }
+    return string(row)
+}
+
+// stacked reports the series toggled on, in Series order.
+func (a *App) stacked() []string {
+    var out []string
+    for i, on := range a.stack {
+        if on { out = append(out, Series[i]) }
+    }
+    return out
+}
+
+// toggleSeries flips the i'th stacked series.
+func (a *App) toggleSeries(i int) {
+    if i < 0 || i >= len(Series) {
+        return
+    }
+    a.stack[i] = !a.stack[i]
+    a.setTitles()
+    a.renderTimeline()
+}
+
+// stackedRows renders each stacked series as a row of columns with its
+// caption to the right.
+func (a *App) stackedRows(names []string, data []metrics.Bucket) string {
+    b := &strings.Builder{}
+    for i, name := range names {
+        if i > 0 { b.WriteByte('\n') }
+        p := a.project(name, data)
+        row := strings.Repeat(" ", len(data))
+        if p.vals != nil {
+            row = p.row()
+        }
+        fmt.Fprintf(b, "[%s]%s[-] %s", seriesColors[name], row, p.caption)
+    }
+    return b.String()
+}
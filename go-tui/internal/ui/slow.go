@@ -0,0 +1,41 @@
+package ui
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/rivo/tview"
+)
+
+// buildSlowView is the "slow" page: the slowest individual requests kept by
+// --slowest, slowest first.
+func (a *App) buildSlowView() *tview.TextView {
+    a.slow = tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+    a.slow.SetBorder(true).SetTitle("Slowest requests (w or Esc to close)")
+    return a.slow
+}
+
+func (a *App) renderSlow() {
+    if a.agg == nil {
+        return
+    }
+    if name, _ := a.pages.GetFrontPage(); name != "slow" {
+        return
+    }
+    evs := a.agg.SlowestEvents(0)
+    if evs == nil {
+        a.slow.SetText("(disabled; start with --slowest N)")
+        return
+    }
+    b := &strings.Builder{}
+    fmt.Fprintf(b, "%8s  %-8s  %-18s  %-10s  %-6s  %s\n", "ms", "time", "instance", "region", "result", "url / reason")
+    for _, ev := range evs {
+        result, detail := "[green]ok[-]    ", ev.URL
+        if !ev.Success {
+            result = "[red]fail[-]  "
+            if ev.Reason != "" { detail = strings.TrimSpace(ev.URL + " " + ev.Reason) }
+        }
+        fmt.Fprintf(b, "%8d  %-8s  %-18s  %-10s  %s  %s\n", ev.ElapsedMS, ev.Time.Format("15:04:05"), tview.Escape(ev.InstanceID), tview.Escape(ev.BatchRegion), result, tview.Escape(detail))
+    }
+    a.slow.SetText(b.String())
+}
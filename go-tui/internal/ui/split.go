@@ -0,0 +1,125 @@
+package ui
+
+import (
+    "fmt"
+    "hash/fnv"
+    "math"
+
+    "github.com/rivo/tview"
+)
+
+// appendLog writes one rendered line from path; call from the tview
+// goroutine. Lines always go to the combined logs pane and, when SplitMax is
+// set, also to that file's own pane (or the shared "others" pane once
+// SplitMax panes exist), so toggling the split view keeps history. With f
+// on, only failures (fail) reach these panes; the i view still gets all,
+// filed under inst (see logInstance).
+func (a *App) appendLog(path, inst, line string, fail bool) {
+    a.focusLog(inst, line)
+    if a.failOnly && !fail {
+        return
+    }
+    line = a.numberLog(line)
+    fmt.Fprintln(a.logs, line)
+    if a.cfg.SplitMax <= 0 {
+        return
+    }
+    tv, created := a.splitPane(path)
+    fmt.Fprintln(tv, line)
+    if created && a.split {
+        a.layoutLogs()
+    }
+}
+
+// prefixPalette holds distinguishable colors for instance prefixes; instances
+// beyond its size share colors.
+var prefixPalette = []string{
+    "aqua", "fuchsia", "yellow", "lime", "orange", "dodgerblue",
+    "violet", "gold", "springgreen", "tomato", "turquoise", "hotpink",
+}
+
+// formatLog renders one log line for the panes: "[name] text", with the
+// prefix in the instance's stable palette color under --prefix-colors and
+// the text's own ANSI colors under --ansi-logs (the panes then parse color
+// tags, so the rest is escaped). A line's colors are its own: they start
+// after the prefix and are reset at its end.
+func (a *App) formatLog(path, text string) string {
+    name := a.instanceName(path)
+    if !a.logColors() {
+        return fmt.Sprintf("[%s] %s", name, text)
+    }
+    prefix := tview.Escape("[" + name + "]")
+    if a.cfg.PrefixColors {
+        h := fnv.New32a()
+        h.Write([]byte(name))
+        prefix = "[" + prefixPalette[h.Sum32()%uint32(len(prefixPalette))] + "]" + prefix + "[-]"
+    }
+    if a.cfg.ANSILogs {
+        return prefix + " " + ansiTags(text)
+    }
+    return prefix + " " + tview.Escape(text)
+}
+
+// logColors reports whether the log panes parse color tags.
+func (a *App) logColors() bool {
+    return a.cfg.PrefixColors || a.cfg.ANSILogs
+}
+
+// splitPane returns the pane for path, creating it if there's room.
+func (a *App) splitPane(path string) (*tview.TextView, bool) {
+    if tv, ok := a.splitPanes[path]; ok {
+        return tv, false
+    }
+    if len(a.splitOrder) < a.cfg.SplitMax {
+        tv := tview.NewTextView().SetDynamicColors(a.logColors()).SetScrollable(true).SetMaxLines(a.cfg.MaxLogLines)
+        tv.SetBorder(true).SetTitle(a.instanceName(path))
+        a.splitPanes[path] = tv
+        a.splitOrder = append(a.splitOrder, path)
+        return tv, true
+    }
+    if a.splitOthers == nil {
+        a.splitOthers = tview.NewTextView().SetDynamicColors(a.logColors()).SetScrollable(true).SetMaxLines(a.cfg.MaxLogLines)
+        a.splitOthers.SetBorder(true).SetTitle("others")
+        return a.splitOthers, true
+    }
+    return a.splitOthers, false
+}
+
+// layoutLogs fills the left column with either the combined logs pane or a
+// grid of per-instance panes.
+func (a *App) layoutLogs() {
+    a.logsBox.Clear()
+    if !a.split || len(a.splitOrder) == 0 {
+        a.logsBox.AddItem(a.logs, 0, 1, false)
+        return
+    }
+    panes := make([]tview.Primitive, 0, len(a.splitOrder)+1)
+    for _, p := range a.splitOrder {
+        panes = append(panes, a.splitPanes[p])
+    }
+    if a.splitOthers != nil {
+        panes = append(panes, a.splitOthers)
+    }
+    cols := int(math.Ceil(math.Sqrt(float64(len(panes)))))
+    for i := 0; i < len(panes); i += cols {
+        row := tview.NewFlex().SetDirection(tview.FlexColumn)
+        for j := i; j < i+cols; j++ {
+            if j < len(panes) {
+                row.AddItem(panes[j], 0, 1, false)
+            } else {
+                row.AddItem(tview.NewBox(), 0, 1, false)
+            }
+        }
+        a.logsBox.AddItem(row, 0, 1, false)
+    }
+}
+
+func (a *App) clearLogs() {
+    a.logs.Clear()
+    for _, tv := range a.splitPanes {
+        tv.Clear()
+    }
+    if a.splitOthers != nil {
+        a.splitOthers.Clear()
+    }
+}
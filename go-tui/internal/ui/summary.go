@@ -0,0 +1,40 @@
+package ui
+
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// writeSummary prints the --summary-on-exit report once the UI has stopped,
+// so the run's outcome stays in the terminal's scrollback: how long the
+// session ran, the stats panel as exported to stats.txt (totals, rate, top
+// regions) and the alert state at exit.
+func (a *App) writeSummary(w io.Writer) {
+    if a.agg == nil {
+        return
+    }
+    now := time.Now()
+    b := &strings.Builder{}
+    fmt.Fprintf(b, "secmon: ran %s (%s to %s)", now.Sub(a.start).Round(time.Second), a.start.Format("15:04:05"), now.Format("15:04:05"))
+    if label := a.runLabel(); label != "" {
+        fmt.Fprintf(b, ", label %s", label)
+    }
+    b.WriteByte('\n')
+    b.WriteString(a.statsText(a.agg.Snapshot(), strconv.Itoa, nil, false, a.clockNow()))
+    a.mu.Lock()
+    as := a.alertStatus
+    a.mu.Unlock()
+    if as.Level {
+        fmt.Fprintf(b, "Alert: fail %.1f%% since %s\n", as.LevelValue, as.LevelSince.Format("15:04:05"))
+    }
+    if as.Slope {
+        fmt.Fprintf(b, "Alert: fail rate %+.1fpp since %s\n", as.SlopeValue, as.SlopeSince.Format("15:04:05"))
+    }
+    if !as.Level && !as.Slope && (a.cfg.AlertRate > 0 || a.cfg.AlertSlope > 0) {
+        b.WriteString("Alerts: ok\n")
+    }
+    io.WriteString(w, b.String())
+}
@@ -0,0 +1,67 @@
+package ui
+
+import "unicode/utf8"
+
+// Stats table sizes without a panel to fit (snapshots, clipboard copies),
+// and the bounds of the fitted name column.
+const (
+    defaultTableRows = 6
+    defaultNameWidth = 18
+    minNameWidth     = 8
+    maxNameWidth     = 48
+)
+
+// tableCols is the width of a table row besides the name: the pin column,
+// "S:%5s F:%5s" and the spaces between.
+const tableCols = 2 + 16
+
+// tableFit sizes the stats panel tables to the panel: as many rows as its
+// height leaves room for, and a name column as wide as the widest name shown
+// (capped, and clipped to the panel width). A nil *tableFit gives the fixed
+// defaultTableRows rows and %-18s names, so exported text stays stable.
+type tableFit struct {
+    width, height int
+}
+
+// statsFit measures the stats panel for renderStats.
+func (a *App) statsFit() *tableFit {
+    return &tableFit{width: getWidth(a.stats), height: getHeight(a.stats)}
+}
+
+// rows returns the unpinned rows each of n tables gets when used lines of the
+// panel are already taken; every table keeps its title and pinned rows.
+func (f *tableFit) rows(used, n, pinned int) int {
+    if f == nil {
+        return defaultTableRows
+    }
+    per := (f.height - used - n) / n
+    if per -= pinned; per < 1 {
+        per = 1
+    }
+    return per
+}
+
+// nameWidth returns the name column width for the rows about to be shown.
+func (f *tableFit) nameWidth(tables ...[]countRow) int {
+    if f == nil {
+        return defaultNameWidth
+    }
+    w := minNameWidth
+    for _, rows := range tables {
+        for _, r := range rows {
+            if n := utf8.RuneCountInString(r.key); n > w { w = n }
+        }
+    }
+    if w > maxNameWidth { w = maxNameWidth }
+    if room := f.width - tableCols; w > room { w = room }
+    if w < minNameWidth { w = minNameWidth }
+    return w
+}
+
+// name pads key to w, clipping it with "…" when fitting to a panel.
+func (f *tableFit) name(key string, w int) string {
+    if f != nil && utf8.RuneCountInString(key) > w {
+        key = string([]rune(key)[:w-1]) + "…"
+    }
+    return key
+}
@@ -0,0 +1,49 @@
+package ui
+
+import "github.com/rivo/tview"
+
+// Until a glob first matches, the logs and stats panels say what they're
+// waiting for instead of sitting empty. New files are read from their
+// start, so nothing written before the first poll that saw them is lost.
+
+// startWaiting puts the logs panel in the waiting state; Run calls it
+// before the first read.
+func (a *App) startWaiting() {
+    a.mu.Lock()
+    a.waitLogs = true
+    a.waitMetrics = a.agg != nil && a.replay == nil
+    a.mu.Unlock()
+    a.logs.SetText(a.waitingText(a.cfg.LogsGlob, a.logColors()))
+}
+
+// noteMatches ends the waiting state for each glob that matched something
+// this tick; called on the update goroutine after reading.
+func (a *App) noteMatches(logs, metrics int) {
+    a.mu.Lock()
+    clearLogs := a.waitLogs && logs > 0
+    if clearLogs {
+        a.waitLogs = false
+    }
+    if metrics > 0 {
+        a.waitMetrics = false
+    }
+    a.mu.Unlock()
+    if clearLogs {
+        // queued ahead of the tick's appendLog calls
+        a.app.QueueUpdateDraw(func() { a.logs.Clear() })
+    }
+}
+
+// waitingMetrics reports whether the metrics glob hasn't matched yet.
+func (a *App) waitingMetrics() bool {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+    return a.waitMetrics
+}
+
+func (a *App) waitingText(glob string, colors bool) string {
+    if colors {
+        glob = tview.Escape(glob)
+    }
+    return "waiting for files matching " + glob + "…"
+}
@@ -0,0 +1,171 @@
+package webhook
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "sync"
+    "text/template"
+    "time"
+)
+
+// DefaultTemplate renders the message text when --webhook-template is unset.
+const DefaultTemplate = `secmon {{.Kind}} alert {{.State}}: {{.ValueText}} over {{.Window}} (threshold {{.ThresholdText}}){{if .TopReason}}, top reason: {{.TopReason}}{{end}}`
+
+// Alert is the data available to the message template and sent alongside the
+// rendered text.
+type Alert struct {
+    Kind      string        `json:"kind"`  // "level" or "slope"
+    State     string        `json:"state"` // "fired" or "cleared"
+    Value     float64       `json:"value"`
+    Threshold float64       `json:"threshold"`
+    Window    time.Duration `json:"-"`
+    TopReason string        `json:"top_reason,omitempty"`
+}
+
+// ValueText formats Value as a percentage (level) or percentage points (slope).
+func (a Alert) ValueText() string { return a.unit(a.Value) }
+
+// ThresholdText formats Threshold like ValueText.
+func (a Alert) ThresholdText() string { return a.unit(a.Threshold) }
+
+func (a Alert) unit(v float64) string {
+    if a.Kind == "slope" {
+        return fmt.Sprintf("%+.1fpp", v)
+    }
+    return fmt.Sprintf("%.1f%%", v)
+}
+
+// Notifier POSTs alerts to a URL from a background goroutine, so a slow or
+// failing endpoint never blocks the caller. The body is a JSON object whose
+// "text" field makes it a valid Slack incoming-webhook payload; the alert
+// fields ride along for generic receivers.
+type Notifier struct {
+    URL     string
+    Retries int           // attempts after the first
+    Backoff time.Duration // first retry delay, doubled each attempt
+    tmpl    *template.Template
+    client  *http.Client
+    mu      sync.RWMutex // guards closed against Send racing Close
+    closed  bool
+    queue   chan Alert
+    done    chan struct{}
+    errf    func(error)
+}
+
+// New parses tmpl (DefaultTemplate if empty) and starts the sender. errf, if
+// set, is called from the sender goroutine for deliveries that gave up.
+func New(url, tmpl string, errf func(error)) (*Notifier, error) {
+    if tmpl == "" {
+        tmpl = DefaultTemplate
+    }
+    t, err := template.New("webhook").Parse(tmpl)
+    if err != nil {
+        return nil, fmt.Errorf("webhook template: %w", err)
+    }
+    n := &Notifier{
+        URL:     url,
+        Retries: 3,
+        Backoff: time.Second,
+        tmpl:    t,
+        client:  &http.Client{Timeout: 10 * time.Second},
+        queue:   make(chan Alert, 32),
+        done:    make(chan struct{}),
+        errf:    errf,
+    }
+    go n.run()
+    return n, nil
+}
+
+// Send queues an alert. It never blocks; if the queue is full the alert is
+// dropped and reported through errf. After Close it does nothing.
+func (n *Notifier) Send(a Alert) {
+    if n == nil {
+        return
+    }
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    if n.closed {
+        return
+    }
+    select {
+    case n.queue <- a:
+    default:
+        n.report(fmt.Errorf("webhook queue full, dropped %s %s alert", a.Kind, a.State))
+    }
+}
+
+// Close stops accepting alerts and waits up to timeout for queued ones to be
+// delivered.
+func (n *Notifier) Close(timeout time.Duration) {
+    if n == nil {
+        return
+    }
+    n.mu.Lock()
+    if n.closed {
+        n.mu.Unlock()
+        return
+    }
+    n.closed = true
+    close(n.queue)
+    n.mu.Unlock()
+    select {
+    case <-n.done:
+    case <-time.After(timeout):
+    }
+}
+
+func (n *Notifier) run() {
+    defer close(n.done)
+    for a := range n.queue {
+        body, err := n.body(a)
+        if err != nil {
+            n.report(err)
+            continue
+        }
+        delay := n.Backoff
+        for attempt := 0; ; attempt++ {
+            if err = n.post(body); err == nil {
+                break
+            }
+            if attempt >= n.Retries {
+                n.report(fmt.Errorf("webhook: giving up after %d attempts: %w", attempt+1, err))
+                break
+            }
+            time.Sleep(delay)
+            delay *= 2
+        }
+    }
+}
+
+func (n *Notifier) body(a Alert) ([]byte, error) {
+    text := &strings.Builder{}
+    if err := n.tmpl.Execute(text, a); err != nil {
+        return nil, fmt.Errorf("webhook template: %w", err)
+    }
+    return json.Marshal(struct {
+        Text string `json:"text"`
+        Alert
+        Window string `json:"window"`
+    }{text.String(), a, a.Window.String()})
+}
+
+func (n *Notifier) post(body []byte) error {
+    resp, err := n.client.Post(n.URL, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    resp.Body.Close()
+    if resp.StatusCode/100 != 2 {
+        return fmt.Errorf("%s: %s", n.URL, resp.Status)
+    }
+    return nil
+}
+
+func (n *Notifier) report(err error) {
+    if n.errf != nil {
+        n.errf(err)
+    }
+}